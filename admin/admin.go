@@ -0,0 +1,337 @@
+// Package admin serves an authenticated JSON API for operating Mantis: the
+// paper account, queued signals, market subscriptions and live books. It
+// wraps the same Redis keys the executor and engine use.
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arjunprakash027/Mantis/executor"
+	"github.com/arjunprakash027/Mantis/market"
+	"github.com/arjunprakash027/Mantis/pkg/logging"
+	"github.com/arjunprakash027/Mantis/pkg/wire"
+	"github.com/arjunprakash027/Mantis/streamer"
+)
+
+var (
+	ErrUnknownMarket     = errors.New("unknown market")
+	ErrAlreadySubscribed = errors.New("already subscribed")
+	ErrNotSubscribed     = errors.New("not subscribed")
+)
+
+// Subscription is one orderbook feed. Source is "config", "api" or
+// "series".
+type Subscription struct {
+	Slug      string   `json:"slug"`
+	Source    string   `json:"source"`
+	Streaming bool     `json:"streaming"`
+	Connected bool     `json:"connected"`
+	LastError string   `json:"last_error,omitempty"`
+	Assets    []string `json:"assets"`
+}
+
+// Subscriptions is implemented by the pipeline manager in main.
+type Subscriptions interface {
+	List() []Subscription
+	Add(ctx context.Context, slug string) error
+	Remove(slug string) error
+}
+
+type Server struct {
+	token  string
+	exec   *executor.Executor
+	engine *streamer.Engine
+	subs   Subscriptions
+	logger *slog.Logger
+}
+
+func New(token string, exec *executor.Executor, engine *streamer.Engine, subs Subscriptions) *Server {
+	return &Server{
+		token:  token,
+		exec:   exec,
+		engine: engine,
+		subs:   subs,
+		logger: logging.For("admin"),
+	}
+}
+
+// Register mounts the API under /api/.
+func (s *Server) Register(mux *http.ServeMux) {
+	handle := func(pattern string, h http.HandlerFunc) {
+		mux.Handle(pattern, s.auth(h))
+	}
+	handle("GET /api/portfolio", s.getPortfolio)
+	handle("POST /api/portfolio/deposit", s.deposit)
+	handle("POST /api/portfolio/withdraw", s.withdraw)
+	handle("POST /api/portfolio/reset", s.reset)
+	handle("GET /api/orders", s.listOrders)
+	handle("DELETE /api/orders/{id}", s.cancelOrder)
+	handle("GET /api/subscriptions", s.listSubscriptions)
+	handle("POST /api/subscriptions", s.addSubscription)
+	handle("DELETE /api/subscriptions/{slug}", s.removeSubscription)
+	handle("GET /api/books/{asset_id}", s.getBook)
+	handle("GET /api/candles/{asset_id}", s.getCandles)
+}
+
+func (s *Server) auth(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		next(w, r)
+	})
+}
+
+func (s *Server) getPortfolio(w http.ResponseWriter, r *http.Request) {
+	p, err := s.exec.Portfolio()
+	if err != nil {
+		s.internal(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+type amountRequest struct {
+	Amount float64 `json:"amount"`
+}
+
+func (s *Server) deposit(w http.ResponseWriter, r *http.Request) {
+	var req amountRequest
+	if !decode(w, r, &req) || !positive(w, "amount", req.Amount) {
+		return
+	}
+	bal, err := s.exec.Deposit(req.Amount)
+	if err != nil {
+		s.internal(w, err)
+		return
+	}
+	s.logger.Info("deposit", "amount", req.Amount, "balance", bal)
+	writeJSON(w, http.StatusOK, map[string]float64{"cash": bal})
+}
+
+func (s *Server) withdraw(w http.ResponseWriter, r *http.Request) {
+	var req amountRequest
+	if !decode(w, r, &req) || !positive(w, "amount", req.Amount) {
+		return
+	}
+	bal, err := s.exec.Withdraw(req.Amount)
+	if errors.Is(err, executor.ErrInsufficientFunds) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		s.internal(w, err)
+		return
+	}
+	s.logger.Info("withdraw", "amount", req.Amount, "balance", bal)
+	writeJSON(w, http.StatusOK, map[string]float64{"cash": bal})
+}
+
+func (s *Server) reset(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Cash float64 `json:"cash"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.Cash < 0 || math.IsNaN(req.Cash) || math.IsInf(req.Cash, 0) {
+		writeError(w, http.StatusBadRequest, "cash must be a non-negative number")
+		return
+	}
+	if err := s.exec.Reset(req.Cash); err != nil {
+		s.internal(w, err)
+		return
+	}
+	s.logger.Warn("portfolio reset", "cash", req.Cash)
+	writeJSON(w, http.StatusOK, map[string]float64{"cash": req.Cash})
+}
+
+func (s *Server) listOrders(w http.ResponseWriter, r *http.Request) {
+	q, err := s.exec.Queued()
+	if err != nil {
+		s.internal(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, q)
+}
+
+func (s *Server) cancelOrder(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	err := s.exec.Cancel(id)
+	if errors.Is(err, executor.ErrNotQueued) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		s.internal(w, err)
+		return
+	}
+	s.logger.Info("signal cancelled", "id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listSubscriptions(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.subs.List())
+}
+
+func (s *Server) addSubscription(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Slug string `json:"slug"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	req.Slug = strings.TrimSpace(req.Slug)
+	if req.Slug == "" {
+		writeError(w, http.StatusBadRequest, "slug is required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)
+	defer cancel()
+	err := s.subs.Add(ctx, req.Slug)
+	switch {
+	case errors.Is(err, ErrUnknownMarket):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrAlreadySubscribed):
+		writeError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		s.internal(w, err)
+	default:
+		s.logger.Info("subscription added", "slug", req.Slug)
+		writeJSON(w, http.StatusCreated, map[string]string{"slug": req.Slug})
+	}
+}
+
+func (s *Server) removeSubscription(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+	err := s.subs.Remove(slug)
+	if errors.Is(err, ErrNotSubscribed) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		s.internal(w, err)
+		return
+	}
+	s.logger.Info("subscription removed", "slug", slug)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type book struct {
+	AssetID     string                `json:"asset_id"`
+	Outcome     string                `json:"outcome,omitempty"`
+	Market      string                `json:"market,omitempty"`
+	Status      market.MarketStatus   `json:"status,omitempty"`
+	BestBid     float64               `json:"best_bid"`
+	BestAsk     float64               `json:"best_ask"`
+	LastUpdated int64                 `json:"last_updated"`
+	Bids        []streamer.PriceLevel `json:"bids"`
+	Asks        []streamer.PriceLevel `json:"asks"`
+}
+
+func (s *Server) getBook(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("asset_id")
+	state, ok := s.engine.GetPrice(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "asset not streamed")
+		return
+	}
+	b := book{
+		AssetID:     id,
+		BestBid:     state.BestBid,
+		BestAsk:     state.BestAsk,
+		LastUpdated: state.LastUpdated,
+		Bids:        []streamer.PriceLevel{},
+		Asks:        []streamer.PriceLevel{},
+	}
+	if meta, ok := s.engine.GetMetadata(id); ok {
+		b.Outcome, b.Market, b.Status = meta.Outcome, meta.Market, meta.Status
+	}
+	if d, ok := s.engine.GetDepth(id); ok {
+		b.Bids, b.Asks = d.Bids, d.Asks
+	}
+	writeJSON(w, http.StatusOK, b)
+}
+
+// getCandles returns the last closed bars, oldest first. interval defaults to
+// the shortest aggregated one and limit to 100.
+func (s *Server) getCandles(w http.ResponseWriter, r *http.Request) {
+	intervals := s.engine.CandleIntervals()
+	if len(intervals) == 0 {
+		writeError(w, http.StatusNotFound, "candles are not enabled")
+		return
+	}
+	interval := intervals[0]
+	if q := r.URL.Query().Get("interval"); q != "" {
+		interval = 0
+		for _, d := range intervals {
+			if wire.IntervalLabel(d) == q {
+				interval = d
+			}
+		}
+		if interval == 0 {
+			writeError(w, http.StatusBadRequest, "interval not aggregated: "+q)
+			return
+		}
+	}
+	limit := 100
+	if q := r.URL.Query().Get("limit"); q != "" {
+		n, err := strconv.Atoi(q)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = n
+	}
+	bars := s.engine.Candles(r.PathValue("asset_id"), interval, limit)
+	if bars == nil {
+		bars = []streamer.Candle{}
+	}
+	writeJSON(w, http.StatusOK, bars)
+}
+
+func (s *Server) internal(w http.ResponseWriter, err error) {
+	s.logger.Error("admin request failed", "err", err)
+	writeError(w, http.StatusInternalServerError, "internal error")
+}
+
+// decode reads a JSON body, rejecting unknown fields.
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func positive(w http.ResponseWriter, field string, v float64) bool {
+	if v <= 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		writeError(w, http.StatusBadRequest, field+" must be a positive number")
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
@@ -0,0 +1,220 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/arjunprakash027/Mantis/executor"
+	"github.com/arjunprakash027/Mantis/pkg/redismantis"
+	"github.com/arjunprakash027/Mantis/streamer"
+	"github.com/redis/go-redis/v9"
+)
+
+type fakeSubs struct {
+	subs []Subscription
+}
+
+func (f *fakeSubs) List() []Subscription { return f.subs }
+
+func (f *fakeSubs) Add(ctx context.Context, slug string) error {
+	if slug == "missing" {
+		return ErrUnknownMarket
+	}
+	for _, s := range f.subs {
+		if s.Slug == slug {
+			return ErrAlreadySubscribed
+		}
+	}
+	f.subs = append(f.subs, Subscription{Slug: slug, Source: "api"})
+	return nil
+}
+
+func (f *fakeSubs) Remove(slug string) error {
+	for i, s := range f.subs {
+		if s.Slug == slug {
+			f.subs = append(f.subs[:i], f.subs[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotSubscribed
+}
+
+func setup(t *testing.T) (*http.ServeMux, *redis.Client, chan []byte) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	engine := streamer.NewEngine(ctx, rdb)
+	feed := make(chan []byte)
+	engine.StartCandles(streamer.CandleOptions{Intervals: []time.Duration{time.Second, time.Minute}})
+	engine.ProcessStream("orderbook", feed)
+	t.Cleanup(func() {
+		close(feed)
+		engine.Drain(context.Background())
+	})
+
+	mux := http.NewServeMux()
+	New("secret", executor.NewExecutor(ctx, rdb, engine), engine, &fakeSubs{}).Register(mux)
+	return mux, rdb, feed
+}
+
+func do(mux *http.ServeMux, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuth(t *testing.T) {
+	mux, _, _ := setup(t)
+	for _, token := range []string{"", "wrong"} {
+		if rec := do(mux, "GET", "/api/portfolio", token, ""); rec.Code != http.StatusUnauthorized {
+			t.Errorf("token %q: got %d, want 401", token, rec.Code)
+		}
+	}
+	if rec := do(mux, "GET", "/api/portfolio", "secret", ""); rec.Code != http.StatusOK {
+		t.Errorf("valid token: got %d", rec.Code)
+	}
+}
+
+func TestAccountEndpoints(t *testing.T) {
+	mux, rdb, _ := setup(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		path, body string
+		code       int
+	}{
+		{"/api/portfolio/deposit", `{"amount":100}`, http.StatusOK},
+		{"/api/portfolio/deposit", `{"amount":-5}`, http.StatusBadRequest},
+		{"/api/portfolio/deposit", `{"amount":"5"}`, http.StatusBadRequest},
+		{"/api/portfolio/deposit", `{"amt":5}`, http.StatusBadRequest},
+		{"/api/portfolio/withdraw", `{"amount":30}`, http.StatusOK},
+		{"/api/portfolio/withdraw", `{"amount":500}`, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		if rec := do(mux, "POST", tt.path, "secret", tt.body); rec.Code != tt.code {
+			t.Errorf("%s %s: got %d, want %d (%s)", tt.path, tt.body, rec.Code, tt.code, rec.Body)
+		}
+	}
+	if usd, _ := rdb.HGet(ctx, redismantis.HashPortfolioBalance, "USD").Float64(); usd != 70 {
+		t.Errorf("balance = %v, want 70", usd)
+	}
+
+	rdb.HSet(ctx, redismantis.HashPortfolioBalance, "Asset_A", 10)
+	if rec := do(mux, "POST", "/api/portfolio/reset", "secret", `{"cash":1000}`); rec.Code != http.StatusOK {
+		t.Fatalf("reset: got %d", rec.Code)
+	}
+	rec := do(mux, "GET", "/api/portfolio", "secret", "")
+	var p executor.Portfolio
+	json.Unmarshal(rec.Body.Bytes(), &p)
+	if p.Cash != 1000 || len(p.Holdings) != 0 {
+		t.Errorf("after reset: %+v", p)
+	}
+}
+
+func TestOrders(t *testing.T) {
+	mux, rdb, _ := setup(t)
+	ctx := context.Background()
+	id := rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: redismantis.StreamSignalsInbound,
+		Values: map[string]interface{}{"data": `{"action":"BUY","asset":"Asset_A","amount":5}`},
+	}).Val()
+
+	rec := do(mux, "GET", "/api/orders", "secret", "")
+	var queued []executor.QueuedSignal
+	json.Unmarshal(rec.Body.Bytes(), &queued)
+	if len(queued) != 1 || queued[0].ID != id || queued[0].Signal.Asset != "Asset_A" {
+		t.Fatalf("queued = %+v", queued)
+	}
+
+	if rec := do(mux, "DELETE", "/api/orders/"+id, "secret", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("cancel: got %d", rec.Code)
+	}
+	if rec := do(mux, "DELETE", "/api/orders/"+id, "secret", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("second cancel: got %d", rec.Code)
+	}
+}
+
+func TestSubscriptions(t *testing.T) {
+	mux, _, _ := setup(t)
+	tests := []struct {
+		method, path, body string
+		code               int
+	}{
+		{"POST", "/api/subscriptions", `{"slug":"btc-100k"}`, http.StatusCreated},
+		{"POST", "/api/subscriptions", `{"slug":"btc-100k"}`, http.StatusConflict},
+		{"POST", "/api/subscriptions", `{"slug":"missing"}`, http.StatusNotFound},
+		{"POST", "/api/subscriptions", `{"slug":" "}`, http.StatusBadRequest},
+		{"DELETE", "/api/subscriptions/btc-100k", "", http.StatusNoContent},
+		{"DELETE", "/api/subscriptions/btc-100k", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if rec := do(mux, tt.method, tt.path, "secret", tt.body); rec.Code != tt.code {
+			t.Errorf("%s %s %s: got %d, want %d", tt.method, tt.path, tt.body, rec.Code, tt.code)
+		}
+	}
+}
+
+func TestBook(t *testing.T) {
+	mux, _, feed := setup(t)
+	if rec := do(mux, "GET", "/api/books/Asset_A", "secret", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown asset: got %d", rec.Code)
+	}
+
+	feed <- []byte(`{"event_type":"book","asset_id":"Asset_A","bids":[{"price":"0.48","size":"100"}],"asks":[{"price":"0.52","size":"40"}]}`)
+	time.Sleep(10 * time.Millisecond)
+
+	rec := do(mux, "GET", "/api/books/Asset_A", "secret", "")
+	var b book
+	json.Unmarshal(rec.Body.Bytes(), &b)
+	if b.BestBid != 0.48 || b.BestAsk != 0.52 || len(b.Bids) != 1 || b.Asks[0].Size != "40" {
+		t.Errorf("book = %+v", b)
+	}
+	// Price changes update the served book, not just the best prices.
+	feed <- []byte(`{"event_type":"price_change","asset_id":"Asset_A","bids":[{"price":"0.50","size":"5"}],"asks":[{"price":"0.52","size":"0"},{"price":"0.55","size":"7"}]}`)
+	time.Sleep(10 * time.Millisecond)
+
+	rec = do(mux, "GET", "/api/books/Asset_A", "secret", "")
+	b = book{}
+	json.Unmarshal(rec.Body.Bytes(), &b)
+	if b.BestBid != 0.5 || b.BestAsk != 0.55 || len(b.Bids) != 2 || b.Bids[1].Price != "0.5" || len(b.Asks) != 1 || b.Asks[0].Size != "7" {
+		t.Errorf("book after price change = %+v", b)
+	}
+}
+
+func TestCandles(t *testing.T) {
+	mux, _, feed := setup(t)
+	for path, code := range map[string]int{
+		"/api/candles/Asset_A?interval=5m": http.StatusBadRequest,
+		"/api/candles/Asset_A?limit=0":     http.StatusBadRequest,
+		"/api/candles/Asset_A?interval=1m": http.StatusOK,
+	} {
+		if rec := do(mux, "GET", path, "secret", ""); rec.Code != code {
+			t.Errorf("%s: got %d, want %d", path, rec.Code, code)
+		}
+	}
+
+	// The first event of the next second closes the bar.
+	feed <- []byte(`{"event_type":"book","asset_id":"Asset_A","bids":[{"price":"0.48","size":"100"}],"asks":[{"price":"0.52","size":"40"}]}`)
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	feed <- []byte(`{"event_type":"price_change","asset_id":"Asset_A","bids":[{"price":"0.50","size":"1"}]}`)
+	time.Sleep(10 * time.Millisecond)
+
+	rec := do(mux, "GET", "/api/candles/Asset_A?interval=1s&limit=5", "secret", "")
+	var bars []streamer.Candle
+	json.Unmarshal(rec.Body.Bytes(), &bars)
+	if len(bars) != 1 || bars[0].Open != 0.5 || bars[0].Interval != "1s" {
+		t.Errorf("bars = %+v", bars)
+	}
+}
@@ -0,0 +1,296 @@
+// Package backtest replays recorded market data and strategy signals through
+// the real streamer engine and executor on a simulated clock.
+package backtest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/arjunprakash027/Mantis/executor"
+	"github.com/arjunprakash027/Mantis/pkg/clock"
+	"github.com/arjunprakash027/Mantis/pkg/redismantis"
+	"github.com/arjunprakash027/Mantis/recorder"
+	"github.com/arjunprakash027/Mantis/strategy"
+	"github.com/arjunprakash027/Mantis/streamer"
+	"github.com/redis/go-redis/v9"
+)
+
+// TimedSignal is a signal submitted at a point in simulated time.
+type TimedSignal struct {
+	At     time.Time
+	Signal executor.Signal
+}
+
+type Config struct {
+	// Redis holds the simulated portfolio. It must point at an empty scratch
+	// database, which the runner flushes on Close.
+	Redis        redis.UniversalClient
+	Files        []string
+	Signals      []TimedSignal
+	StartingCash float64
+	// EquityEvery is the sampling interval of the equity curve in simulated
+	// time. Every fill is sampled as well.
+	EquityEvery time.Duration
+}
+
+type Trade struct {
+	Time       time.Time `json:"time"`
+	StrategyID string    `json:"strategy_id"`
+	Action     string    `json:"action"`
+	Asset      string    `json:"asset"`
+	Amount     float64   `json:"amount"`
+	Price      float64   `json:"price"`
+	Success    bool      `json:"success"`
+	ErrorCode  string    `json:"error_code,omitempty"`
+}
+
+type EquityPoint struct {
+	Time   time.Time `json:"time"`
+	Equity float64   `json:"equity"`
+}
+
+type Summary struct {
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	StartEquity float64   `json:"start_equity"`
+	EndEquity   float64   `json:"end_equity"`
+	PnL         float64   `json:"pnl"`
+	Return      float64   `json:"return"`
+	MaxDrawdown float64   `json:"max_drawdown"`
+	Fills       int       `json:"fills"`
+	Rejected    int       `json:"rejected"`
+	Volume      float64   `json:"volume"`
+	Frames      int       `json:"frames"`
+}
+
+type Report struct {
+	Trades  []Trade       `json:"trades"`
+	Equity  []EquityPoint `json:"equity"`
+	Summary Summary       `json:"summary"`
+}
+
+// Runner keeps its state in a scratch Redis database so a backtest never
+// touches the live portfolio.
+type Runner struct {
+	cfg    Config
+	ctx    context.Context
+	rdb    redis.UniversalClient
+	clock  *clock.Sim
+	engine *streamer.Engine
+	exec   *executor.Executor
+
+	host       *strategy.Host
+	pending    []TimedSignal
+	report     Report
+	nextSample time.Time
+}
+
+func NewRunner(ctx context.Context, cfg Config) (*Runner, error) {
+	if cfg.EquityEvery <= 0 {
+		cfg.EquityEvery = time.Minute
+	}
+	rdb := cfg.Redis
+	if rdb == nil {
+		return nil, fmt.Errorf("backtest: no scratch redis database")
+	}
+	n, err := rdb.DBSize(ctx).Result()
+	if err != nil {
+		return nil, err
+	}
+	if n != 0 {
+		return nil, fmt.Errorf("backtest: scratch redis database is not empty (%d keys)", n)
+	}
+	if err := rdb.HSet(ctx, redismantis.HashPortfolioBalance, "USD", cfg.StartingCash).Err(); err != nil {
+		return nil, err
+	}
+
+	sim := clock.NewSim(time.Time{})
+	engine := streamer.NewEngine(ctx, rdb)
+	engine.SetClock(sim)
+	exec := executor.NewExecutor(ctx, rdb, engine)
+	engine.SetSettler(exec)
+
+	pending := append([]TimedSignal(nil), cfg.Signals...)
+	sort.SliceStable(pending, func(i, j int) bool { return pending[i].At.Before(pending[j].At) })
+
+	return &Runner{
+		cfg:     cfg,
+		ctx:     ctx,
+		rdb:     rdb,
+		clock:   sim,
+		engine:  engine,
+		exec:    exec,
+		pending: pending,
+	}, nil
+}
+
+// Close empties the scratch database. The caller still owns the client.
+func (r *Runner) Close() {
+	r.rdb.FlushDB(r.ctx)
+}
+
+// AddStrategy runs an in-process strategy against the replay. Its orders go
+// through Submit, so they land in the report like file-based signals.
+func (r *Runner) AddStrategy(s strategy.Strategy) {
+	if r.host == nil {
+		r.host = strategy.NewHost(r.engine, r)
+		r.host.SetQueueSize(0)
+	}
+	r.host.Register(s)
+}
+
+func (r *Runner) Engine() *streamer.Engine     { return r.engine }
+func (r *Runner) Executor() *executor.Executor { return r.exec }
+func (r *Runner) Clock() clock.Clock           { return r.clock }
+
+// Run replays every frame, executing signals as simulated time passes them,
+// and returns the resulting report.
+func (r *Runner) Run() (*Report, error) {
+	player := &recorder.Player{Files: r.cfg.Files}
+	err := player.Play(r.ctx, func(rec recorder.Record) error {
+		at := time.Unix(0, rec.ReceivedAt)
+		r.advance(at)
+		r.engine.Process(rec.Namespace, []byte(rec.Data))
+		r.report.Summary.Frames++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Signals after the last frame still run, against whatever is cached.
+	for len(r.pending) > 0 {
+		r.advance(r.pending[len(r.pending)-1].At)
+	}
+	r.sample(r.clock.Now())
+	r.summarise()
+	return &r.report, nil
+}
+
+// Submit executes a signal at the current simulated time and records it.
+func (r *Runner) Submit(sig executor.Signal) executor.ExecutionResult {
+	now := r.clock.Now()
+	res := r.exec.Execute(sig)
+	r.report.Trades = append(r.report.Trades, Trade{
+		Time:       now,
+		StrategyID: sig.StrategyID,
+		Action:     sig.Action,
+		Asset:      sig.Asset,
+		Amount:     sig.Amount,
+		Price:      res.FilledPrice,
+		Success:    res.Success,
+		ErrorCode:  res.ErrorCode,
+	})
+	if res.Success {
+		r.sample(now)
+	}
+	return res
+}
+
+func (r *Runner) advance(to time.Time) {
+	for len(r.pending) > 0 && !r.pending[0].At.After(to) {
+		next := r.pending[0]
+		r.pending = r.pending[1:]
+		r.moveClock(next.At)
+		r.Submit(next.Signal)
+	}
+	r.moveClock(to)
+}
+
+func (r *Runner) moveClock(to time.Time) {
+	if r.report.Summary.Start.IsZero() {
+		r.report.Summary.Start = to
+		r.nextSample = to
+	}
+	r.clock.Set(to)
+	if r.host != nil {
+		r.host.Tick(to)
+	}
+	for !r.nextSample.After(to) {
+		r.sample(r.nextSample)
+		r.nextSample = r.nextSample.Add(r.cfg.EquityEvery)
+	}
+}
+
+func (r *Runner) sample(at time.Time) {
+	r.report.Equity = append(r.report.Equity, EquityPoint{Time: at, Equity: r.equity()})
+}
+
+// equity marks every position at its mid (or the one-sided quote).
+func (r *Runner) equity() float64 {
+	p, err := r.exec.Portfolio()
+	if err != nil {
+		return math.NaN()
+	}
+	return p.Equity
+}
+
+func (r *Runner) summarise() {
+	sum := &r.report.Summary
+	sum.End = r.clock.Now()
+	sum.StartEquity = r.cfg.StartingCash
+	if n := len(r.report.Equity); n > 0 {
+		sum.EndEquity = r.report.Equity[n-1].Equity
+	}
+	sum.PnL = sum.EndEquity - sum.StartEquity
+	if sum.StartEquity != 0 {
+		sum.Return = sum.PnL / sum.StartEquity
+	}
+
+	peak := sum.StartEquity
+	for _, p := range r.report.Equity {
+		if p.Equity > peak {
+			peak = p.Equity
+		}
+		if peak > 0 {
+			if dd := (peak - p.Equity) / peak; dd > sum.MaxDrawdown {
+				sum.MaxDrawdown = dd
+			}
+		}
+	}
+
+	for _, t := range r.report.Trades {
+		if t.Success {
+			sum.Fills++
+			sum.Volume += t.Price * t.Amount
+		} else {
+			sum.Rejected++
+		}
+	}
+}
+
+// LoadSignals reads a JSON-lines file of signals, each with a "ts" field in
+// unix seconds alongside the usual signal fields.
+func LoadSignals(path string) ([]TimedSignal, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []TimedSignal
+	sc := bufio.NewScanner(f)
+	line := 0
+	for sc.Scan() {
+		line++
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var row struct {
+			TS float64 `json:"ts"`
+			executor.Signal
+		}
+		if err := json.Unmarshal(sc.Bytes(), &row); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		sec, frac := math.Modf(row.TS)
+		out = append(out, TimedSignal{At: time.Unix(int64(sec), int64(frac*1e9)), Signal: row.Signal})
+	}
+	return out, sc.Err()
+}
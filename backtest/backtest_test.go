@@ -0,0 +1,155 @@
+package backtest
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/arjunprakash027/Mantis/executor"
+	"github.com/arjunprakash027/Mantis/recorder"
+	"github.com/arjunprakash027/Mantis/strategy"
+	"github.com/redis/go-redis/v9"
+)
+
+func scratch(t *testing.T) *redis.Client {
+	return redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+}
+
+func writeCapture(t *testing.T, dir string, recs []recorder.Record) []string {
+	path := filepath.Join(dir, "mantis-test.jsonl.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	enc := json.NewEncoder(gz)
+	for _, r := range recs {
+		enc.Encode(r)
+	}
+	gz.Close()
+	f.Close()
+	return []string{path}
+}
+
+func TestBacktestUsesSimulatedClock(t *testing.T) {
+	t0 := time.Date(2026, 3, 19, 16, 0, 0, 0, time.UTC)
+	files := writeCapture(t, t.TempDir(), []recorder.Record{
+		{ReceivedAt: t0.UnixNano(), Namespace: "orderbook", Data: `{"event_type":"book","asset_id":"A","bids":[{"price":"0.40","size":"100"}],"asks":[{"price":"0.50","size":"100"}]}`},
+		{ReceivedAt: t0.Add(30 * time.Second).UnixNano(), Namespace: "orderbook", Data: `{"event_type":"book","asset_id":"A","bids":[{"price":"0.60","size":"100"}],"asks":[{"price":"0.70","size":"100"}]}`},
+	})
+
+	runner, err := NewRunner(context.Background(), Config{
+		Redis:        scratch(t),
+		Files:        files,
+		StartingCash: 1000,
+		Signals: []TimedSignal{
+			{At: t0.Add(time.Second), Signal: executor.Signal{Action: "BUY", Asset: "A", Amount: 10, StrategyID: "bt"}},
+			// 90s after the last update: the stale guard must trip on simulated time.
+			{At: t0.Add(2 * time.Minute), Signal: executor.Signal{Action: "SELL", Asset: "A", Amount: 10, StrategyID: "bt"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer runner.Close()
+
+	report, err := runner.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Trades) != 2 {
+		t.Fatalf("expected 2 trades, got %d", len(report.Trades))
+	}
+	if !report.Trades[0].Success || report.Trades[0].Price != 0.50 {
+		t.Errorf("buy should fill at the ask: %+v", report.Trades[0])
+	}
+	if report.Trades[1].ErrorCode != executor.CodeStalePrice {
+		t.Errorf("sell should be rejected as stale, got %+v", report.Trades[1])
+	}
+
+	sum := report.Summary
+	if sum.Fills != 1 || sum.Rejected != 1 || sum.Frames != 2 {
+		t.Errorf("unexpected summary %+v", sum)
+	}
+	if diff := sum.EndEquity - 1001.5; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("expected end equity 1001.5, got %v", sum.EndEquity)
+	}
+	if len(report.Equity) < 3 {
+		t.Errorf("expected a sampled equity curve, got %d points", len(report.Equity))
+	}
+
+	if err := report.WriteFiles(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type buyOnce struct {
+	strategy.Base
+	bought bool
+	fills  []strategy.Fill
+	timers int
+}
+
+func (b *buyOnce) ID() string                          { return "buy_once" }
+func (b *buyOnce) TimerInterval() time.Duration        { return 10 * time.Second }
+func (b *buyOnce) OnTimer(strategy.Context, time.Time) { b.timers++ }
+func (b *buyOnce) OnFill(_ strategy.Context, f strategy.Fill) {
+	b.fills = append(b.fills, f)
+}
+
+func (b *buyOnce) OnBook(ctx strategy.Context, book strategy.Book) {
+	if !b.bought && book.BestAsk > 0 {
+		b.bought = true
+		ctx.Buy(book.AssetID, 10)
+	}
+}
+
+func TestBacktestRunsStrategy(t *testing.T) {
+	t0 := time.Date(2026, 3, 19, 16, 0, 0, 0, time.UTC)
+	files := writeCapture(t, t.TempDir(), []recorder.Record{
+		{ReceivedAt: t0.UnixNano(), Namespace: "orderbook", Data: `{"event_type":"book","asset_id":"A","bids":[{"price":"0.40","size":"5"}],"asks":[{"price":"0.50","size":"5"}]}`},
+		{ReceivedAt: t0.Add(time.Minute).UnixNano(), Namespace: "orderbook", Data: `{"event_type":"book","asset_id":"A","bids":[{"price":"0.45","size":"5"}],"asks":[{"price":"0.55","size":"5"}]}`},
+	})
+
+	runner, err := NewRunner(context.Background(), Config{Redis: scratch(t), Files: files, StartingCash: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer runner.Close()
+
+	bot := &buyOnce{}
+	runner.AddStrategy(bot)
+
+	report, err := runner.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bot.fills) != 1 || !bot.fills[0].Result.Success || bot.fills[0].Result.FilledPrice != 0.50 {
+		t.Fatalf("unexpected fills %+v", bot.fills)
+	}
+	if len(report.Trades) != 1 || report.Trades[0].StrategyID != "buy_once" {
+		t.Errorf("strategy order missing from trade log: %+v", report.Trades)
+	}
+	if bot.timers == 0 {
+		t.Error("OnTimer never fired on simulated time")
+	}
+}
+
+func TestBacktestRefusesNonEmptyDatabase(t *testing.T) {
+	rdb := scratch(t)
+	rdb.Set(context.Background(), "live", "1", 0)
+
+	if _, err := NewRunner(context.Background(), Config{Redis: rdb}); err == nil {
+		t.Fatal("runner started on a database that already holds keys")
+	}
+	if rdb.Exists(context.Background(), "live").Val() != 1 {
+		t.Fatal("existing key was touched")
+	}
+}
@@ -0,0 +1,73 @@
+package backtest
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// WriteFiles writes trades.csv, equity.csv and summary.json into dir.
+func (r *Report) WriteFiles(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	trades := [][]string{{"time", "strategy_id", "action", "asset", "amount", "price", "success", "error_code"}}
+	for _, t := range r.Trades {
+		trades = append(trades, []string{
+			t.Time.UTC().Format(time.RFC3339Nano), t.StrategyID, t.Action, t.Asset,
+			ftoa(t.Amount), ftoa(t.Price), strconv.FormatBool(t.Success), t.ErrorCode,
+		})
+	}
+	if err := writeCSV(filepath.Join(dir, "trades.csv"), trades); err != nil {
+		return err
+	}
+
+	equity := [][]string{{"time", "equity"}}
+	for _, p := range r.Equity {
+		equity = append(equity, []string{p.Time.UTC().Format(time.RFC3339Nano), ftoa(p.Equity)})
+	}
+	if err := writeCSV(filepath.Join(dir, "equity.csv"), equity); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(r.Summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "summary.json"), data, 0o644)
+}
+
+// Print writes a human-readable summary.
+func (s Summary) Print(w io.Writer) {
+	fmt.Fprintf(w, "Period:       %s -> %s\n", s.Start.UTC().Format(time.RFC3339), s.End.UTC().Format(time.RFC3339))
+	fmt.Fprintf(w, "Frames:       %d\n", s.Frames)
+	fmt.Fprintf(w, "Equity:       %.2f -> %.2f\n", s.StartEquity, s.EndEquity)
+	fmt.Fprintf(w, "PnL:          %.2f (%.2f%%)\n", s.PnL, s.Return*100)
+	fmt.Fprintf(w, "Max Drawdown: %.2f%%\n", s.MaxDrawdown*100)
+	fmt.Fprintf(w, "Fills:        %d (rejected %d)\n", s.Fills, s.Rejected)
+	fmt.Fprintf(w, "Volume:       %.2f\n", s.Volume)
+}
+
+func writeCSV(path string, rows [][]string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.WriteAll(rows)
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func ftoa(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,521 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/arjunprakash027/Mantis/client"
+	"github.com/arjunprakash027/Mantis/config"
+	"github.com/arjunprakash027/Mantis/executor"
+	"github.com/arjunprakash027/Mantis/export"
+	"github.com/arjunprakash027/Mantis/pkg/redisclient"
+	"github.com/arjunprakash027/Mantis/pkg/redismantis"
+	"github.com/arjunprakash027/Mantis/recorder"
+	"github.com/redis/go-redis/v9"
+)
+
+type command struct {
+	usage   string
+	summary string
+	run     func(args []string) error
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"run":       {"run [-replay dir] [-backtest ...]", "start the data engine and executor (default)", runEngine},
+		"markets":   {"markets", "list tracked slugs and their tokens", cmdMarkets},
+		"book":      {"book [-depth n] [-follow] <token>", "show the best bid/ask and depth of a token", cmdBook},
+		"order":     {"order [-strategy id] [-timeout d] buy|sell <token> <amount>", "submit a signal and wait for its result", cmdOrder},
+		"portfolio": {"portfolio", "show cash, positions and PnL", cmdPortfolio},
+		"trades":    {"trades [-since 24h|2006-01-02|RFC3339] [-limit n]", "list executed trades", cmdTrades},
+		"fund":      {"fund [-withdraw|-reset] <amount>", "deposit, withdraw or reset USD", cmdFund},
+		"export":    {"export [-format parquet|csv] [-out dir] [-since ...] [-tables list] [-replay dir]", "write books, trades and fills to partitioned files", cmdExport},
+		"migrate":   {"migrate", "rename legacy Redis keys to their {mantis} names", cmdMigrate},
+		"help":      {"help", "show this help", func([]string) error { usage(); return nil }},
+	}
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "Usage: mantis <command> [-config config.yaml] [flags]")
+	fmt.Fprintln(os.Stderr)
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 3, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\t%s\n", commands[name].usage, commands[name].summary)
+	}
+	w.Flush()
+}
+
+// newFlagSet returns a flag set with the shared -config flag.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("mantis "+name, flag.ContinueOnError)
+	fs.StringVar(&configPath, "config", "config.yaml", "path to the configuration file")
+	return fs
+}
+
+// parseArgs parses flags that appear before, between or after the positional
+// arguments and checks the positional count.
+func parseArgs(fs *flag.FlagSet, args []string, want int) ([]string, error) {
+	var pos []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		pos = append(pos, args[0])
+		args = args[1:]
+	}
+	if len(pos) != want {
+		return nil, fmt.Errorf("expected %d argument(s), got %d (see mantis help)", want, len(pos))
+	}
+	return pos, nil
+}
+
+// connect opens the Redis deployment from the config file, for commands that
+// inspect a running engine.
+var connect = func() (redis.UniversalClient, error) {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	rdb, err := redisclient.New(cfg.Redis)
+	if err != nil {
+		return nil, err
+	}
+	return rdb, nil
+}
+
+// dial wraps connect in an SDK client that owns the connection.
+func dial(strategyID string) (*ownedClient, error) {
+	rdb, err := connect()
+	if err != nil {
+		return nil, err
+	}
+	c, err := client.New(context.Background(), rdb, client.Options{StrategyID: strategyID})
+	if err != nil {
+		rdb.Close()
+		return nil, err
+	}
+	return &ownedClient{c, rdb}, nil
+}
+
+type ownedClient struct {
+	*client.Client
+	rdb redis.UniversalClient
+}
+
+func (c *ownedClient) Close() error {
+	c.Client.Close()
+	return c.rdb.Close()
+}
+
+func cmdMarkets(args []string) error {
+	fs := newFlagSet("markets")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	c, err := dial("cli")
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	markets, err := c.Markets(context.Background())
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SLUG\tTOKEN\tOUTCOME\tSTATUS\tMARKET")
+	for _, m := range markets {
+		for _, t := range m.Tokens {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", m.Slug, t.TokenID, str(t.Outcome), str(string(t.Status)), str(t.Market))
+		}
+	}
+	return w.Flush()
+}
+
+func cmdBook(args []string) error {
+	fs := newFlagSet("book")
+	depth := fs.Int("depth", 10, "price levels to show per side")
+	follow := fs.Bool("follow", false, "keep printing as updates arrive")
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	asset := pos[0]
+	c, err := dial("cli")
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	t, err := c.Token(ctx, asset)
+	if err != nil {
+		return err
+	}
+	header := func() {
+		fmt.Printf("%s | %s [%s]\n", str(t.Market), str(t.Outcome), str(string(t.Status)))
+	}
+	if !*follow {
+		b, err := c.Book(ctx, asset)
+		if err != nil {
+			return err
+		}
+		header()
+		printBook(os.Stdout, b, *depth)
+		return nil
+	}
+
+	// Subscribe first so nothing written while the book is read is missed;
+	// reapplying an update the read already saw is harmless.
+	updates, err := c.SubscribeBook(ctx, asset)
+	if err != nil {
+		return err
+	}
+	b, err := c.Book(ctx, asset)
+	if err != nil && !errors.Is(err, client.ErrNoBook) {
+		return err
+	}
+	b.Asset = asset
+	if err == nil {
+		header()
+		printBook(os.Stdout, b, *depth)
+	}
+	for u := range updates {
+		b.Apply(u)
+		// Updates arrive in bursts; redraw once per burst.
+		for len(updates) > 0 {
+			b.Apply(<-updates)
+		}
+		fmt.Println()
+		header()
+		printBook(os.Stdout, b, *depth)
+	}
+	return nil
+}
+
+func printBook(out io.Writer, b client.Book, depth int) {
+	fmt.Fprintf(out, "bid %.4f  ask %.4f  spread %.4f  updated %s ago\n",
+		b.BestBid, b.BestAsk, b.BestAsk-b.BestBid, time.Since(b.Updated).Round(time.Second))
+	if len(b.Bids) == 0 && len(b.Asks) == 0 {
+		return
+	}
+
+	// Both sides are ordered best price last.
+	bids, asks := b.Bids, b.Asks
+	if len(bids) > depth {
+		bids = bids[len(bids)-depth:]
+	}
+	if len(asks) > depth {
+		asks = asks[len(asks)-depth:]
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "\tPRICE\tSIZE\t")
+	for _, l := range asks {
+		fmt.Fprintf(w, "ask\t%s\t%s\t\n", l.Price, l.Size)
+	}
+	for i := len(bids) - 1; i >= 0; i-- {
+		fmt.Fprintf(w, "bid\t%s\t%s\t\n", bids[i].Price, bids[i].Size)
+	}
+	w.Flush()
+}
+
+func cmdOrder(args []string) error {
+	fs := newFlagSet("order")
+	strategyID := fs.String("strategy", "cli", "strategy_id to tag the signal with")
+	timeout := fs.Duration("timeout", 15*time.Second, "how long to wait for the executor")
+	pos, err := parseArgs(fs, args, 3)
+	if err != nil {
+		return err
+	}
+	side := client.Side(strings.ToUpper(pos[0]))
+	amount, err := parseAmount(pos[2])
+	if err != nil || amount == 0 {
+		return fmt.Errorf("amount must be a positive number, got %q", pos[2])
+	}
+
+	c, err := dial(*strategyID)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	o, err := c.SubmitOrder(ctx, side, pos[1], amount)
+	if err != nil {
+		return err
+	}
+	res, err := o.Wait(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("no result within %s; signal %s may still be queued", *timeout, o.ID)
+	}
+	if err != nil {
+		return err
+	}
+	if !res.Success {
+		return fmt.Errorf("rejected: %s: %s", res.ErrorCode, res.ErrorMsg)
+	}
+	fmt.Printf("filled %s %g @ %.4f (total %.2f) signal %s\n",
+		side, res.FilledAmount, res.FilledPrice, res.FilledAmount*res.FilledPrice, o.ID)
+	return nil
+}
+
+func cmdPortfolio(args []string) error {
+	fs := newFlagSet("portfolio")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	c, err := dial("cli")
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	p, err := c.Portfolio(context.Background())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("cash %.2f  equity %.2f  pnl %+.2f\n", p.Cash, p.Equity, p.PnL)
+	if len(p.Holdings) == 0 {
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\nASSET\tQTY\tAVG\tMARK\tVALUE\tPNL")
+	for _, h := range p.Holdings {
+		fmt.Fprintf(w, "%s\t%g\t%.4f\t%.4f\t%.2f\t%+.2f\n", h.Asset, h.Quantity, h.AvgPrice, h.Mark, h.Value, h.PnL)
+	}
+	return w.Flush()
+}
+
+func cmdTrades(args []string) error {
+	fs := newFlagSet("trades")
+	since := fs.String("since", "", "only trades after this: a duration (24h), a date (2006-01-02) or RFC3339 time")
+	limit := fs.Int64("limit", 0, "show at most this many of the newest trades (0 = all)")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	start := "-"
+	if *since != "" {
+		t, err := parseSince(*since, time.Now())
+		if err != nil {
+			return err
+		}
+		start = strconv.FormatInt(t.UnixMilli(), 10)
+	}
+
+	rdb, err := connect()
+	if err != nil {
+		return err
+	}
+	defer rdb.Close()
+
+	msgs, err := rdb.XRange(context.Background(), redismantis.HashTradeLog, start, "+").Result()
+	if err != nil {
+		return err
+	}
+	if *limit > 0 && int64(len(msgs)) > *limit {
+		msgs = msgs[int64(len(msgs))-*limit:]
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tACTION\tAMOUNT\tPRICE\tTOTAL\tOUTCOME\tMARKET\tSTRATEGY")
+	for _, m := range msgs {
+		v := m.Values
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			redismantis.StreamTime(m.ID).Format(time.DateTime), str(v["action"]), str(v["amount"]), str(v["price"]),
+			str(v["total"]), str(v["outcome"]), str(v["market"]), str(v["strategy"]))
+	}
+	return w.Flush()
+}
+
+func cmdExport(args []string) error {
+	fs := newFlagSet("export")
+	format := fs.String("format", "parquet", "parquet or csv")
+	out := fs.String("out", "export", "output directory")
+	since := fs.String("since", "", "only data after this: a duration (24h), a date (2006-01-02) or RFC3339 time")
+	tables := fs.String("tables", strings.Join(export.Tables, ","), "comma separated tables to write")
+	replay := fs.String("replay", "", "read market data from the capture files in this directory instead of Redis")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	var from time.Time
+	if *since != "" {
+		var err error
+		if from, err = parseSince(*since, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	x, err := export.New(export.Options{
+		Dir:    *out,
+		Format: export.Format(*format),
+		Tables: strings.Split(*tables, ","),
+	})
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	err = func() error {
+		market := x.Wants(export.TableTopOfBook) || x.Wants(export.TableDepth) || x.Wants(export.TableTrades)
+		if market && *replay != "" {
+			files, err := recorder.Files(*replay)
+			if err != nil {
+				return err
+			}
+			if len(files) == 0 {
+				return fmt.Errorf("no capture files in %q", *replay)
+			}
+			if err := export.Capture(ctx, files, x, from); err != nil {
+				return err
+			}
+		}
+		fromStreams := market && *replay == ""
+		if !fromStreams && !x.Wants(export.TableFills) {
+			return nil
+		}
+
+		rdb, err := connect()
+		if err != nil {
+			return err
+		}
+		defer rdb.Close()
+		if fromStreams {
+			if err := export.Streams(ctx, rdb, x, from); err != nil {
+				return err
+			}
+		}
+		if x.Wants(export.TableFills) {
+			return export.TradeLog(ctx, rdb, x, from)
+		}
+		return nil
+	}()
+	counts, cerr := x.Close()
+	if err = errors.Join(err, cerr); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tROWS")
+	for _, name := range export.Tables {
+		if n, ok := counts[name]; ok {
+			fmt.Fprintf(w, "%s\t%d\n", name, n)
+		}
+	}
+	return w.Flush()
+}
+
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid -since %q: want a duration, a date or an RFC3339 time", s)
+}
+
+// parseAmount accepts a finite, non-negative number.
+func parseAmount(s string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("invalid amount %v", v)
+	}
+	return v, nil
+}
+
+func cmdFund(args []string) error {
+	fs := newFlagSet("fund")
+	withdraw := fs.Bool("withdraw", false, "remove amount instead of adding it")
+	reset := fs.Bool("reset", false, "drop all positions and set the USD balance to amount")
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *withdraw && *reset {
+		return errors.New("-withdraw and -reset are exclusive")
+	}
+	amount, err := parseAmount(pos[0])
+	if err != nil || (amount == 0 && !*reset) {
+		return fmt.Errorf("amount must be a positive number, got %q", pos[0])
+	}
+
+	rdb, err := connect()
+	if err != nil {
+		return err
+	}
+	defer rdb.Close()
+	ctx := context.Background()
+
+	balance := amount
+	switch {
+	case *reset:
+		err = executor.Reset(ctx, rdb, amount)
+	case *withdraw:
+		balance, err = executor.Withdraw(ctx, rdb, amount)
+	default:
+		balance, err = executor.Deposit(ctx, rdb, amount)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("cash %.2f\n", balance)
+	return nil
+}
+
+func cmdMigrate(args []string) error {
+	if _, err := parseArgs(newFlagSet("migrate"), args, 0); err != nil {
+		return err
+	}
+	rdb, err := connect()
+	if err != nil {
+		return err
+	}
+	defer rdb.Close()
+
+	moved, err := redismantis.MigrateKeys(context.Background(), rdb)
+	if err != nil {
+		return err
+	}
+	for _, key := range moved {
+		fmt.Println(key)
+	}
+	return nil
+}
+
+// str renders a Redis reply value, with "-" for missing fields.
+func str(v interface{}) string {
+	if s, ok := v.(string); ok && s != "" {
+		return s
+	}
+	return "-"
+}
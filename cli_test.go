@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/arjunprakash027/Mantis/executor"
+	"github.com/arjunprakash027/Mantis/pkg/redismantis"
+	"github.com/arjunprakash027/Mantis/pkg/wire"
+	"github.com/arjunprakash027/Mantis/streamer"
+	"github.com/redis/go-redis/v9"
+)
+
+func useMiniredis(t *testing.T) *redis.Client {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	prev := connect
+	connect = func() (redis.UniversalClient, error) {
+		return redis.NewClient(&redis.Options{Addr: s.Addr()}), nil
+	}
+	t.Cleanup(func() { connect = prev })
+	return rdb
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 3, 19, 12, 0, 0, 0, time.UTC)
+	if got, _ := parseSince("2h", now); !got.Equal(now.Add(-2 * time.Hour)) {
+		t.Errorf("duration: %v", got)
+	}
+	if got, _ := parseSince("2026-03-18T10:00:00Z", now); got.Hour() != 10 || got.Day() != 18 {
+		t.Errorf("rfc3339: %v", got)
+	}
+	if got, _ := parseSince("2026-03-01", now); got.Day() != 1 {
+		t.Errorf("date: %v", got)
+	}
+	if _, err := parseSince("yesterday", now); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestParseArgsInterspersed(t *testing.T) {
+	fs := newFlagSet("order")
+	timeout := fs.Duration("timeout", time.Second, "")
+	pos, err := parseArgs(fs, []string{"buy", "-timeout", "3s", "tok", "5"}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *timeout != 3*time.Second || strings.Join(pos, " ") != "buy tok 5" {
+		t.Errorf("got %v %v", pos, *timeout)
+	}
+}
+
+func TestFundRejectsNonFinite(t *testing.T) {
+	rdb := useMiniredis(t)
+	for _, amount := range []string{"NaN", "Inf", "-Inf", "-1", "0", "1e400"} {
+		if err := cmdFund([]string{amount}); err == nil {
+			t.Errorf("fund %s accepted", amount)
+		}
+	}
+	if n, _ := rdb.Exists(context.Background(), redismantis.HashPortfolioBalance).Result(); n != 0 {
+		t.Error("a rejected amount reached the portfolio")
+	}
+	if err := cmdFund([]string{"-reset", "0"}); err != nil {
+		t.Errorf("reset to 0: %v", err)
+	}
+}
+
+func TestOrderWaitsForResult(t *testing.T) {
+	rdb := useMiniredis(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	engine := streamer.NewEngine(ctx, rdb)
+	feed := make(chan []byte)
+	engine.ProcessStream("orderbook", feed)
+	feed <- []byte(`{"event_type":"book","asset_id":"A","bids":[{"price":"0.48","size":"10"}],"asks":[{"price":"0.50","size":"10"}]}`)
+
+	exec := executor.NewExecutor(ctx, rdb, engine)
+	go exec.Start()
+
+	if err := cmdFund([]string{"100"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmdOrder([]string{"buy", "A", "10"}); err != nil {
+		t.Fatalf("buy: %v", err)
+	}
+	err := cmdOrder([]string{"sell", "A", "50"})
+	if err == nil || !strings.Contains(err.Error(), executor.CodeRejected) {
+		t.Errorf("oversized sell: %v", err)
+	}
+	if err := cmdFund([]string{"-withdraw", "1000"}); !errors.Is(err, executor.ErrInsufficientFunds) {
+		t.Errorf("withdraw: %v", err)
+	}
+
+	p, err := wire.ReadPortfolio(ctx, rdb, engine.GetPrice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Cash != 95 || p.Positions["A"] != 10 {
+		t.Errorf("portfolio = %+v", p)
+	}
+}
+
+func TestExportCommand(t *testing.T) {
+	rdb := useMiniredis(t)
+	rdb.XAdd(context.Background(), &redis.XAddArgs{
+		Stream: "orderbook:stream:A",
+		ID:     "1735689600000-0",
+		Values: map[string]interface{}{"data": `{"event_type":"last_trade_price","asset_id":"A","price":"0.5","size":"1","side":"SELL"}`},
+	})
+	dir := t.TempDir()
+	if err := cmdExport([]string{"-format", "csv", "-out", dir, "-tables", "trades,fills"}); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "trades/date=2025-01-01/asset=A/part-*.csv"))
+	if len(files) != 1 {
+		t.Fatalf("exported %v", files)
+	}
+	if err := cmdExport([]string{"-out", dir, "-tables", "quotes"}); err == nil {
+		t.Error("expected unknown table error")
+	}
+}
+
+func TestOnlyMigrateRenamesLegacyKeys(t *testing.T) {
+	rdb := useMiniredis(t)
+	ctx := context.Background()
+	rdb.HSet(ctx, "portfolio:balance", "USD", 50)
+
+	if err := cmdPortfolio(nil); err != nil {
+		t.Fatal(err)
+	}
+	if rdb.Exists(ctx, "portfolio:balance").Val() != 1 {
+		t.Fatal("a read-only command renamed a legacy key")
+	}
+
+	if err := cmdMigrate(nil); err != nil {
+		t.Fatal(err)
+	}
+	if rdb.Exists(ctx, "portfolio:balance").Val() != 0 || rdb.HGet(ctx, redismantis.HashPortfolioBalance, "USD").Val() != "50" {
+		t.Fatal("migrate did not move the legacy balance")
+	}
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"github.com/arjunprakash027/Mantis/pkg/redismantis"
+	"github.com/arjunprakash027/Mantis/pkg/wire"
+)
+
+// Portfolio reads the paper account with positions marked to the latest
+// book on each stream, so it works whether or not the engine is running.
+func (c *Client) Portfolio(ctx context.Context) (wire.Portfolio, error) {
+	return wire.ReadPortfolio(ctx, c.rdb, func(asset string) (wire.MarketState, bool) {
+		b, err := c.Book(ctx, asset)
+		return b.State(), err == nil
+	})
+}
+
+// Market is a tracked slug and the tokens registered under it.
+type Market struct {
+	Slug   string
+	Tokens []wire.Token
+}
+
+// Markets lists every slug the engine has registered, sorted by slug.
+func (c *Client) Markets(ctx context.Context) ([]Market, error) {
+	slugs, err := c.rdb.SMembers(ctx, redismantis.SetSlugs).Result()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(slugs)
+	out := make([]Market, len(slugs))
+	for i, slug := range slugs {
+		out[i].Slug = slug
+	}
+
+	for i := range out {
+		ids, err := c.rdb.SMembers(ctx, redismantis.SetSlugAssets(out[i].Slug)).Result()
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			t, err := c.Token(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			out[i].Tokens = append(out[i].Tokens, t)
+		}
+	}
+	return out, nil
+}
+
+// Token reads an asset's token:meta hash. Unknown assets return a Token with
+// only TokenID set.
+func (c *Client) Token(ctx context.Context, id string) (wire.Token, error) {
+	h, err := c.rdb.HGetAll(ctx, redismantis.HashTokenMeta(id)).Result()
+	if err != nil {
+		return wire.Token{}, err
+	}
+	num := func(k string) float64 {
+		v, _ := strconv.ParseFloat(h[k], 64)
+		return v
+	}
+	negRisk, _ := strconv.ParseBool(h["neg_risk"])
+	return wire.Token{
+		TokenID:      id,
+		Outcome:      h["outcome"],
+		Market:       h["market"],
+		MarketSlug:   h["market_slug"],
+		ConditionID:  h["condition_id"],
+		EventID:      h["event_id"],
+		EventTitle:   h["event_title"],
+		NegRisk:      negRisk,
+		TickSize:     num("minimum_tick_size"),
+		MinOrderSize: num("minimum_order_size"),
+		EndDate:      h["end_date"],
+		Sibling:      h["sibling"],
+		Status:       wire.MarketStatus(h["status"]),
+	}, nil
+}
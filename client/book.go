@@ -0,0 +1,220 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/arjunprakash027/Mantis/pkg/redismantis"
+	"github.com/arjunprakash027/Mantis/pkg/wire"
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrNoBook = errors.New("no book for asset (is it streamed?)")
+
+// Book is a token's latest state rebuilt from its orderbook stream the way
+// the engine builds it: the newest "book" snapshot with every later price
+// change applied. BestBid and BestAsk are what the executor fills against;
+// Bids and Asks are the full book, best price last.
+type Book struct {
+	Asset   string
+	BestBid float64
+	BestAsk float64
+	Updated time.Time
+	Bids    []wire.PriceLevel
+	Asks    []wire.PriceLevel
+	// LastID is the newest stream entry, for resuming reads after it.
+	LastID string
+
+	l2 *wire.L2Book
+}
+
+// State converts the book to the engine's cache entry.
+func (b Book) State() wire.MarketState {
+	return wire.MarketState{BestBid: b.BestBid, BestAsk: b.BestAsk, LastUpdated: b.Updated.Unix()}
+}
+
+// Apply folds an update from SubscribeBook into the book, so a follower reads
+// the stream once instead of rebuilding the book for every update.
+func (b *Book) Apply(u wire.OrderbookUpdate) {
+	if u.AssetID != b.Asset || (u.EventType != "book" && len(u.Bids) == 0 && len(u.Asks) == 0) {
+		return
+	}
+	if b.l2 == nil {
+		b.l2 = wire.NewL2Book()
+	}
+	b.l2.Apply(u)
+	b.Updated = time.Now()
+	b.refresh()
+}
+
+func (b *Book) refresh() {
+	b.BestBid, _, b.BestAsk, _ = b.l2.Best()
+	d := b.l2.Depth()
+	b.Bids, b.Asks = d.Bids, d.Asks
+}
+
+// maxBookScan bounds how many entries Book reads back while looking for a
+// snapshot. Without one in range, the book is rebuilt from those entries.
+const maxBookScan = 1000
+
+// Book reads the asset's stream back to the newest "book" snapshot, at most
+// maxBookScan entries, and replays what follows it.
+func (c *Client) Book(ctx context.Context, asset string) (Book, error) {
+	return readBook(ctx, c.rdb, asset)
+}
+
+func readBook(ctx context.Context, rdb redis.UniversalClient, asset string) (Book, error) {
+	const page = 200
+	type entry struct {
+		id      string
+		updates []wire.OrderbookUpdate
+	}
+	b := Book{Asset: asset, l2: wire.NewL2Book()}
+	var entries []entry // newest first
+	end := "+"
+scan:
+	for len(entries) < maxBookScan {
+		msgs, err := rdb.XRevRangeN(ctx, redismantis.StreamOrderbook(asset), end, "-", page).Result()
+		if err != nil {
+			return b, err
+		}
+		for _, m := range msgs {
+			e := entry{id: m.ID, updates: decode(m, asset)}
+			entries = append(entries, e)
+			for _, u := range e.updates {
+				if u.EventType == "book" {
+					break scan
+				}
+			}
+		}
+		if len(msgs) < page {
+			break
+		}
+		end = "(" + msgs[len(msgs)-1].ID
+	}
+	if len(entries) > 0 {
+		b.LastID = entries[0].id
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		for _, u := range entries[i].updates {
+			if u.EventType != "book" && len(u.Bids) == 0 && len(u.Asks) == 0 {
+				continue
+			}
+			b.l2.Apply(u)
+			b.Updated = redismantis.StreamTime(entries[i].id)
+		}
+	}
+	if b.Updated.IsZero() {
+		return b, ErrNoBook
+	}
+	b.refresh()
+	return b, nil
+}
+
+// decode returns the updates in an entry that concern asset. Entries hold
+// one event of their own asset, but streams written by older versions carry
+// whole batches.
+func decode(m redis.XMessage, asset string) []wire.OrderbookUpdate {
+	data, _ := m.Values["data"].(string)
+	updates, _ := wire.DecodeUpdates([]byte(data))
+	var out []wire.OrderbookUpdate
+	for _, u := range updates {
+		if u.AssetID == asset {
+			out = append(out, u)
+		}
+	}
+	return out
+}
+
+// SubscribeBook delivers the asset's updates, oldest first, until ctx ends;
+// then the channel is closed. Without Options.Group it starts after the
+// newest entry. With a group it first replays entries this consumer read but
+// did not acknowledge, and acknowledges each entry once its updates are
+// delivered. Redis errors are retried.
+func (c *Client) SubscribeBook(ctx context.Context, asset string) (<-chan wire.OrderbookUpdate, error) {
+	stream := redismantis.StreamOrderbook(asset)
+	var start string
+	if c.opts.Group == "" {
+		last, err := tail(ctx, c.rdb, stream)
+		if err != nil {
+			return nil, err
+		}
+		start = last
+	} else {
+		err := c.rdb.XGroupCreateMkStream(ctx, stream, c.opts.Group, "$").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return nil, err
+		}
+		start = "0"
+	}
+
+	ch := make(chan wire.OrderbookUpdate, 64)
+	go c.readBook(ctx, stream, asset, start, ch)
+	return ch, nil
+}
+
+func (c *Client) readBook(ctx context.Context, stream, asset, last string, ch chan<- wire.OrderbookUpdate) {
+	defer close(ch)
+	logger := c.logger.With("asset_id", asset)
+	group := c.opts.Group != ""
+	backoff := newBackoff()
+
+	for {
+		var streams []redis.XStream
+		var err error
+		if group {
+			streams, err = c.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+				Group:    c.opts.Group,
+				Consumer: c.opts.Consumer,
+				Streams:  []string{stream, last},
+				Count:    100,
+				Block:    pollBlock,
+			}).Result()
+		} else {
+			streams, err = c.rdb.XRead(ctx, &redis.XReadArgs{
+				Streams: []string{stream, last},
+				Count:   100,
+				Block:   pollBlock,
+			}).Result()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			logger.Warn("book read failed, retrying", "err", err)
+			if !backoff.wait(ctx) {
+				return
+			}
+			continue
+		}
+		backoff.reset()
+
+		// Acknowledged entries leave the pending list, so re-reading "0"
+		// pages through it until it is empty.
+		msgs := streams[0].Messages
+		if group && last == "0" && len(msgs) == 0 {
+			last = ">"
+			continue
+		}
+		for _, m := range msgs {
+			for _, u := range decode(m, asset) {
+				select {
+				case ch <- u:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if group {
+				c.rdb.XAck(ctx, stream, c.opts.Group, m.ID)
+			} else {
+				last = m.ID
+			}
+		}
+	}
+}
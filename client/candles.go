@@ -0,0 +1,32 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/arjunprakash027/Mantis/pkg/redismantis"
+	"github.com/arjunprakash027/Mantis/pkg/wire"
+)
+
+// Candles reads up to n of the asset's most recent closed bars from its
+// candle stream, oldest first. The stream only exists when the engine runs
+// with candles enabled for that interval.
+func (c *Client) Candles(ctx context.Context, asset string, interval time.Duration, n int) ([]wire.Candle, error) {
+	stream := redismantis.StreamCandles(wire.IntervalLabel(interval), asset)
+	msgs, err := c.rdb.XRevRangeN(ctx, stream, "+", "-", int64(n)).Result()
+	if err != nil {
+		return nil, err
+	}
+	bars := make([]wire.Candle, 0, len(msgs))
+	for _, m := range msgs {
+		var k wire.Candle
+		data, _ := m.Values["data"].(string)
+		if json.Unmarshal([]byte(data), &k) == nil {
+			bars = append(bars, k)
+		}
+	}
+	slices.Reverse(bars)
+	return bars, nil
+}
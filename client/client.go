@@ -0,0 +1,244 @@
+// Package client is the Go SDK for strategies that run outside the Mantis
+// process. It speaks the same Redis protocol as any other bot: signals go to
+// signals:inbound, results are matched on signals:outbound by signal_id, and
+// books are read from the orderbook streams.
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/arjunprakash027/Mantis/pkg/logging"
+	"github.com/arjunprakash027/Mantis/pkg/redismantis"
+	"github.com/arjunprakash027/Mantis/pkg/wire"
+	"github.com/redis/go-redis/v9"
+)
+
+type Side string
+
+const (
+	Buy  Side = "BUY"
+	Sell Side = "SELL"
+)
+
+// Result is the executor's answer to an order.
+type Result = wire.ExecutionResult
+
+var ErrClosed = errors.New("client closed")
+
+// Options configures a Client. Group enables consumer-group reads in
+// SubscribeBook, so a restarted bot resumes where it stopped and several
+// instances share the updates; Consumer names this instance in the group.
+type Options struct {
+	StrategyID string
+	Group      string
+	Consumer   string
+}
+
+type Client struct {
+	rdb  redis.UniversalClient
+	opts Options
+	ctx  context.Context
+	stop context.CancelFunc
+
+	mu      sync.Mutex
+	pending map[string]*Order
+	closed  bool
+
+	logger *slog.Logger
+}
+
+// pollBlock bounds each blocking read so cancellation is noticed promptly.
+var pollBlock = 2 * time.Second
+
+// New starts a client on rdb. It does not own rdb; Close leaves it open.
+func New(ctx context.Context, rdb redis.UniversalClient, opts Options) (*Client, error) {
+	if opts.StrategyID == "" {
+		return nil, errors.New("client: StrategyID is required")
+	}
+	if opts.Consumer == "" {
+		opts.Consumer = opts.StrategyID
+	}
+	// Results published before New are never ours.
+	last, err := tail(ctx, rdb, redismantis.StreamSignalsOutbound)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, stop := context.WithCancel(ctx)
+	c := &Client{
+		rdb:     rdb,
+		opts:    opts,
+		ctx:     ctx,
+		stop:    stop,
+		pending: make(map[string]*Order),
+		logger:  logging.For("client").With("strategy_id", opts.StrategyID),
+	}
+	go c.dispatch(last)
+	return c, nil
+}
+
+// Close stops the client. Orders still waiting fail with ErrClosed. It does
+// not wait for a blocked read to return.
+func (c *Client) Close() error {
+	c.stop()
+	c.failPending(ErrClosed)
+	return nil
+}
+
+// Order is a submitted signal whose result arrives later.
+type Order struct {
+	ID   string
+	done chan struct{}
+	res  Result
+	err  error
+}
+
+// Done is closed once the result is known.
+func (o *Order) Done() <-chan struct{} { return o.done }
+
+// Wait blocks for the executor's result. A rejection is a Result with
+// Success false, not an error.
+func (o *Order) Wait(ctx context.Context) (Result, error) {
+	select {
+	case <-o.done:
+		return o.res, o.err
+	case <-ctx.Done():
+		return Result{}, ctx.Err()
+	}
+}
+
+// SubmitOrder publishes a signal and returns immediately.
+func (c *Client) SubmitOrder(ctx context.Context, side Side, asset string, amount float64) (*Order, error) {
+	if side != Buy && side != Sell {
+		return nil, fmt.Errorf("client: invalid side %q", side)
+	}
+	if amount <= 0 {
+		return nil, fmt.Errorf("client: amount must be positive, got %v", amount)
+	}
+
+	o := &Order{ID: c.opts.StrategyID + "-" + randomID(), done: make(chan struct{})}
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClosed
+	}
+	c.pending[o.ID] = o
+	c.mu.Unlock()
+
+	data, _ := json.Marshal(wire.Signal{
+		ID:         o.ID,
+		Action:     string(side),
+		Asset:      asset,
+		Amount:     amount,
+		StrategyID: c.opts.StrategyID,
+	})
+	err := c.rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: redismantis.StreamSignalsInbound,
+		Values: map[string]interface{}{"data": data},
+	}).Err()
+	if err != nil {
+		c.mu.Lock()
+		delete(c.pending, o.ID)
+		c.mu.Unlock()
+		return nil, err
+	}
+	return o, nil
+}
+
+// dispatch matches every result on signals:outbound to a pending order. It
+// reads without a consumer group because each client must see all results.
+func (c *Client) dispatch(last string) {
+	defer c.failPending(ErrClosed)
+
+	backoff := newBackoff()
+	for {
+		streams, err := c.rdb.XRead(c.ctx, &redis.XReadArgs{
+			Streams: []string{redismantis.StreamSignalsOutbound, last},
+			Block:   pollBlock,
+		}).Result()
+		if c.ctx.Err() != nil {
+			return
+		}
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			c.logger.Warn("result read failed, retrying", "err", err)
+			if !backoff.wait(c.ctx) {
+				return
+			}
+			continue
+		}
+		backoff.reset()
+
+		for _, m := range streams[0].Messages {
+			last = m.ID
+			id, _ := m.Values["signal_id"].(string)
+			c.mu.Lock()
+			o, ok := c.pending[id]
+			delete(c.pending, id)
+			c.mu.Unlock()
+			if !ok {
+				continue
+			}
+			data, _ := m.Values["data"].(string)
+			o.err = json.Unmarshal([]byte(data), &o.res)
+			close(o.done)
+		}
+	}
+}
+
+func (c *Client) failPending(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	for id, o := range c.pending {
+		o.err = err
+		close(o.done)
+		delete(c.pending, id)
+	}
+}
+
+// tail returns the newest entry ID of a stream, so reads can start after it
+// without missing anything added in between.
+func tail(ctx context.Context, rdb redis.UniversalClient, stream string) (string, error) {
+	msgs, err := rdb.XRevRangeN(ctx, stream, "+", "-", 1).Result()
+	if err != nil {
+		return "", err
+	}
+	if len(msgs) == 0 {
+		return "0-0", nil
+	}
+	return msgs[0].ID, nil
+}
+
+func randomID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// backoff spaces out retries while Redis is unreachable, from 100ms to 5s.
+type backoff struct{ d time.Duration }
+
+func newBackoff() *backoff { return &backoff{d: 100 * time.Millisecond} }
+
+func (b *backoff) reset() { b.d = 100 * time.Millisecond }
+
+func (b *backoff) wait(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(b.d):
+	}
+	b.d = min(2*b.d, 5*time.Second)
+	return true
+}
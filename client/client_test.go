@@ -0,0 +1,232 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/arjunprakash027/Mantis/executor"
+	"github.com/arjunprakash027/Mantis/market"
+	"github.com/arjunprakash027/Mantis/pkg/redismantis"
+	"github.com/arjunprakash027/Mantis/streamer"
+	"github.com/redis/go-redis/v9"
+)
+
+func init() {
+	pollBlock = 50 * time.Millisecond
+}
+
+func setup(t *testing.T, opts Options) (*Client, *redis.Client) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	if opts.StrategyID == "" {
+		opts.StrategyID = "test"
+	}
+	c, err := New(context.Background(), rdb, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, rdb
+}
+
+func addFrame(rdb *redis.Client, asset, data string) {
+	rdb.XAdd(context.Background(), &redis.XAddArgs{
+		Stream: redismantis.StreamOrderbook(asset),
+		Values: map[string]interface{}{"data": data},
+	})
+}
+
+func TestSubmitOrder(t *testing.T) {
+	c, rdb := setup(t, Options{StrategyID: "bot"})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	engine := streamer.NewEngine(ctx, rdb)
+	feed := make(chan []byte)
+	engine.ProcessStream("orderbook", feed)
+	feed <- []byte(`{"event_type":"book","asset_id":"A","bids":[{"price":"0.48","size":"10"}],"asks":[{"price":"0.50","size":"10"}]}`)
+	rdb.HSet(ctx, redismantis.HashPortfolioBalance, "USD", 100)
+	// Start creates the group at "$"; create it first so the orders below
+	// cannot land before it and be skipped.
+	rdb.XGroupCreateMkStream(ctx, redismantis.StreamSignalsInbound, redismantis.GroupMantisExecutors, "$")
+	go executor.NewExecutor(ctx, rdb, engine).Start()
+
+	// Two orders in flight are matched to their own results.
+	buy, err := c.SubmitOrder(ctx, Buy, "A", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sell, err := c.SubmitOrder(ctx, Sell, "A", 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := sell.Wait(ctx)
+	if err != nil || res.Success || res.ErrorCode != executor.CodeRejected {
+		t.Errorf("sell: %+v %v", res, err)
+	}
+	res, err = buy.Wait(ctx)
+	if err != nil || !res.Success || res.FilledPrice != 0.50 {
+		t.Errorf("buy: %+v %v", res, err)
+	}
+
+	if _, err := c.SubmitOrder(ctx, "HOLD", "A", 1); err == nil {
+		t.Error("expected invalid side error")
+	}
+}
+
+func TestCloseFailsPending(t *testing.T) {
+	c, _ := setup(t, Options{})
+	o, err := c.SubmitOrder(context.Background(), Buy, "A", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	if _, err := o.Wait(context.Background()); err != ErrClosed {
+		t.Errorf("got %v, want ErrClosed", err)
+	}
+	if _, err := c.SubmitOrder(context.Background(), Buy, "A", 1); err != ErrClosed {
+		t.Errorf("submit after close: %v", err)
+	}
+}
+
+func TestBook(t *testing.T) {
+	c, rdb := setup(t, Options{})
+	ctx := context.Background()
+	addFrame(rdb, "A", `{"event_type":"book","asset_id":"A","bids":[{"price":"0.40","size":"10"},{"price":"0.45","size":"5"}],"asks":[{"price":"0.55","size":"7"}]}`)
+	addFrame(rdb, "A", `[{"event_type":"price_change","asset_id":"B","bids":[{"price":"0.10","size":"1"}]},{"event_type":"price_change","asset_id":"A","bids":[{"price":"0.47","size":"3"}]}]`)
+	addFrame(rdb, "A", `{"event_type":"price_change","asset_id":"A","bids":[{"price":"0.40","size":"0"}]}`)
+
+	b, err := c.Book(ctx, "A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.BestBid != 0.47 || b.BestAsk != 0.55 || len(b.Bids) != 2 || b.Bids[1].Price != "0.47" || b.Asks[0].Size != "7" {
+		t.Errorf("book = %+v", b)
+	}
+	if _, err := c.Book(ctx, "missing"); err != ErrNoBook {
+		t.Errorf("missing asset: %v", err)
+	}
+
+	b.Apply(streamer.OrderbookUpdate{EventType: "price_change", AssetID: "A", Asks: []streamer.PriceLevel{{Price: "0.50", Size: "2"}}})
+	b.Apply(streamer.OrderbookUpdate{EventType: "price_change", AssetID: "B", Asks: []streamer.PriceLevel{{Price: "0.20", Size: "2"}}})
+	if b.BestAsk != 0.50 || len(b.Asks) != 2 {
+		t.Errorf("book after Apply = %+v", b)
+	}
+}
+
+func TestBookScanIsBounded(t *testing.T) {
+	c, rdb := setup(t, Options{})
+	ctx := context.Background()
+	addFrame(rdb, "A", `{"event_type":"book","asset_id":"A","bids":[{"price":"0.10","size":"1"}],"asks":[{"price":"0.90","size":"1"}]}`)
+	for i := 0; i < maxBookScan+50; i++ {
+		addFrame(rdb, "A", `{"event_type":"price_change","asset_id":"A","bids":[{"price":"0.40","size":"1"}]}`)
+	}
+
+	// The snapshot is out of range, so only the price changes are replayed.
+	b, err := c.Book(ctx, "A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.BestBid != 0.40 || b.BestAsk != 0 || len(b.Bids) != 1 {
+		t.Errorf("book = %+v", b)
+	}
+}
+
+func TestCandles(t *testing.T) {
+	c, rdb := setup(t, Options{})
+	ctx := context.Background()
+	for _, data := range []string{`{"asset_id":"A","interval":"1m","close":0.4}`, `{"asset_id":"A","interval":"1m","close":0.5}`, `{"asset_id":"A","interval":"1m","close":0.6}`} {
+		rdb.XAdd(ctx, &redis.XAddArgs{Stream: redismantis.StreamCandles("1m", "A"), Values: map[string]interface{}{"data": data}})
+	}
+	bars, err := c.Candles(ctx, "A", time.Minute, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bars) != 2 || bars[0].Close != 0.5 || bars[1].Close != 0.6 {
+		t.Errorf("bars = %+v", bars)
+	}
+}
+
+func TestSubscribeBook(t *testing.T) {
+	c, rdb := setup(t, Options{})
+	ctx, cancel := context.WithCancel(context.Background())
+	addFrame(rdb, "A", `{"event_type":"book","asset_id":"A","bids":[{"price":"0.10"}]}`)
+
+	updates, err := c.SubscribeBook(ctx, "A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addFrame(rdb, "A", `[{"asset_id":"B","bids":[{"price":"0.20"}]},{"asset_id":"A","bids":[{"price":"0.30"}]}]`)
+	addFrame(rdb, "A", `{"asset_id":"A","asks":[{"price":"0.40"}]}`)
+
+	if u := <-updates; u.Bids[0].Price != "0.30" {
+		t.Errorf("first update = %+v, want the batched A entry", u)
+	}
+	if u := <-updates; u.Asks[0].Price != "0.40" {
+		t.Errorf("second update = %+v", u)
+	}
+	cancel()
+	for range updates {
+	}
+}
+
+func TestSubscribeBookGroupResumes(t *testing.T) {
+	c, rdb := setup(t, Options{Group: "bots", Consumer: "one"})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	updates, err := c.SubscribeBook(ctx, "A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addFrame(rdb, "A", `{"asset_id":"A","bids":[{"price":"0.10"}]}`)
+	<-updates
+	cancel()
+	for range updates {
+	}
+
+	// Entries added while nobody reads are delivered on resubscribe.
+	addFrame(rdb, "A", `{"asset_id":"A","bids":[{"price":"0.20"}]}`)
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	updates, err = c.SubscribeBook(ctx, "A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := <-updates; u.Bids[0].Price != "0.20" {
+		t.Errorf("resumed at %+v, want 0.20", u)
+	}
+}
+
+func TestMarketsAndPortfolio(t *testing.T) {
+	c, rdb := setup(t, Options{})
+	ctx := context.Background()
+	engine := streamer.NewEngine(ctx, rdb)
+	engine.RegisterMetadata("btc-100k", []market.Token{
+		{TokenID: "Y", Outcome: "Yes", Market: "BTC 100k?", TickSize: 0.01, NegRisk: true, Status: market.StatusActive},
+		{TokenID: "N", Outcome: "No", Market: "BTC 100k?"},
+	})
+
+	markets, err := c.Markets(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(markets) != 1 || markets[0].Slug != "btc-100k" || len(markets[0].Tokens) != 2 {
+		t.Fatalf("markets = %+v", markets)
+	}
+	if y := markets[0].Tokens[1]; y.TokenID != "Y" || y.TickSize != 0.01 || !y.NegRisk || y.Status != market.StatusActive {
+		t.Errorf("token = %+v", y)
+	}
+
+	rdb.HSet(ctx, redismantis.HashPortfolioBalance, "USD", 50, "Y", 10)
+	rdb.HSet(ctx, redismantis.HashPortfolioCost, "Y", 4)
+	addFrame(rdb, "Y", `{"event_type":"book","asset_id":"Y","bids":[{"price":"0.50","size":"10"}],"asks":[{"price":"0.60","size":"10"}]}`)
+	p, err := c.Portfolio(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Equity != 55.5 || len(p.Holdings) != 1 || p.Holdings[0].PnL != 1.5 {
+		t.Errorf("portfolio = %+v", p)
+	}
+}
@@ -1,34 +1,268 @@
 package config
 
 import (
+	"bytes"
 	"os"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
+	Redis RedisConfig `yaml:"redis"`
+
+	// Polymarket endpoints; empty values use the production URLs.
+	Polymarket struct {
+		GammaURL  string `yaml:"gamma_url"`
+		ClobWSURL string `yaml:"clob_ws_url"`
+	} `yaml:"polymarket"`
+
+	// Logging controls the slog output. Level changes apply on reload.
+	Logging struct {
+		Level  string `yaml:"level"`
+		Format string `yaml:"format"`
+		// Sample keeps the first Initial copies of a message per interval and
+		// then one in every Thereafter; Initial 0 logs everything.
+		Sample struct {
+			Initial         int `yaml:"initial"`
+			Thereafter      int `yaml:"thereafter"`
+			IntervalSeconds int `yaml:"interval_seconds"`
+		} `yaml:"sample"`
+	} `yaml:"logging"`
+
+	// Shutdown bounds the drain on SIGINT/SIGTERM; defaults to 15 seconds.
+	Shutdown struct {
+		TimeoutSeconds int `yaml:"timeout_seconds"`
+	} `yaml:"shutdown"`
+
+	// HTTP serves /metrics, /healthz and /readyz; an empty addr disables the
+	// listener.
+	HTTP struct {
+		Addr string `yaml:"addr"`
+	} `yaml:"http"`
+
+	// Admin mounts the JSON API under /api/ on the HTTP listener. Requests
+	// must carry the bearer token read from TokenEnv (default
+	// MANTIS_ADMIN_TOKEN).
+	Admin struct {
+		Enabled  bool   `yaml:"enabled"`
+		TokenEnv string `yaml:"token_env"`
+	} `yaml:"admin"`
+
+	// StreamWriter queues stream writes and sends them to Redis in
+	// pipelines, off the ingest path. A zero flush interval flushes whenever
+	// the queue is momentarily empty. Overflow is "block" (back-pressure on
+	// the WebSocket reader) or "drop". Needs a restart.
+	StreamWriter struct {
+		Enabled         bool   `yaml:"enabled"`
+		QueueSize       int    `yaml:"queue_size"`
+		BatchSize       int    `yaml:"batch_size"`
+		FlushIntervalMs int    `yaml:"flush_interval_ms"`
+		Overflow        string `yaml:"overflow"`
+	} `yaml:"stream_writer"`
+
+	// Candles aggregates mids and trades into OHLCV bars per asset, written
+	// to candles:<interval>:<asset_id>. Window is how many closed bars per
+	// asset and interval stay in memory for the API. Needs a restart.
+	Candles struct {
+		Enabled   bool     `yaml:"enabled"`
+		Intervals []string `yaml:"intervals"`
+		Window    int      `yaml:"window"`
+	} `yaml:"candles"`
+
+	// Features publishes microstructure features per asset on every book
+	// update to features:<asset_id> (latest) and features:stream:<asset_id>.
+	// Set names the features (mid, microprice, spread_ticks, imbalance,
+	// volatility); DepthLevels feeds imbalance and VolatilityWindowSeconds
+	// volatility. Needs a restart.
+	Features struct {
+		Enabled                 bool     `yaml:"enabled"`
+		Set                     []string `yaml:"set"`
+		DepthLevels             int      `yaml:"depth_levels"`
+		VolatilityWindowSeconds int      `yaml:"volatility_window_seconds"`
+	} `yaml:"features"`
+
+	// Arb prices every subscribed market's outcomes as a set, plus the Yes
+	// tokens of neg-risk events subscribed by event slug, and writes
+	// arb:opportunities when buying or selling the whole set clears MinEdge
+	// per $1 payout after FeeBps on each leg. Needs a restart.
+	Arb struct {
+		Enabled bool    `yaml:"enabled"`
+		MinEdge float64 `yaml:"min_edge"`
+		FeeBps  float64 `yaml:"fee_bps"`
+	} `yaml:"arb"`
+
+	// Retention trims the Redis streams the engine writes. Each policy sets
+	// max_len (entries) or max_age_minutes (MINID time window), not both.
+	// Markets, keyed by slug, win over namespaces (orderbook, market,
+	// discovery, candles, features, arb), which win over default. Applied live on save.
+	Retention struct {
+		Default    RetentionPolicy            `yaml:"default"`
+		Namespaces map[string]RetentionPolicy `yaml:"namespaces"`
+		Markets    map[string]RetentionPolicy `yaml:"markets"`
+	} `yaml:"retention"`
+
+	// Ledger records fills, cash movements and balance snapshots in SQLite
+	// or PostgreSQL. The DSN is read from DSNEnv (default MANTIS_LEDGER_DSN),
+	// falling back to DSN, which suits a SQLite path. Needs a restart.
+	Ledger struct {
+		Enabled                 bool   `yaml:"enabled"`
+		Driver                  string `yaml:"driver"`
+		DSN                     string `yaml:"dsn"`
+		DSNEnv                  string `yaml:"dsn_env"`
+		SnapshotIntervalMinutes int    `yaml:"snapshot_interval_minutes"`
+	} `yaml:"ledger"`
+
+	// Recorder captures every raw frame to rotating gzip files for replay.
+	Recorder struct {
+		Enabled       bool   `yaml:"enabled"`
+		Dir           string `yaml:"dir"`
+		RotateMinutes int    `yaml:"rotate_minutes"`
+		MaxFileMB     int    `yaml:"max_file_mb"`
+	} `yaml:"recorder"`
+
+	Strategies []StrategyConfig `yaml:"strategies"`
+
+	// Risk limits are checked by the executor before every fill. Zero means
+	// unlimited.
+	Risk struct {
+		MaxOrderAmount   float64 `yaml:"max_order_amount"`
+		MaxOrderNotional float64 `yaml:"max_order_notional"`
+	} `yaml:"risk"`
+
 	Pipelines struct {
 		Discovery struct {
 			Enabled         bool `yaml:"enabled"`
 			IntervalMinutes int  `yaml:"interval_minutes"`
 		} `yaml:"discovery"`
 		Orderbook struct {
-			Enabled bool     `yaml:"enabled"`
-			Markets []string `yaml:"markets"`
+			Enabled bool           `yaml:"enabled"`
+			Markets []string       `yaml:"markets"`
+			Series  []SeriesConfig `yaml:"series"`
+			// MarketStreams also writes each market's events, both
+			// outcomes together, to market:stream:<condition_id>. Needs a
+			// restart.
+			MarketStreams bool `yaml:"market_streams"`
 		} `yaml:"orderbook"`
 	} `yaml:"pipelines"`
 }
 
+// RedisConfig selects a standalone, Sentinel or Cluster deployment.
+// Credentials are read from the environment variables named here, never from
+// the file itself.
+type RedisConfig struct {
+	Addrs          []string `yaml:"addrs"`
+	DB             int      `yaml:"db"`
+	UsernameEnv    string   `yaml:"username_env"`
+	PasswordEnv    string   `yaml:"password_env"`
+	SentinelMaster string   `yaml:"sentinel_master"`
+	Cluster        bool     `yaml:"cluster"`
+	TLS            struct {
+		Enabled            bool   `yaml:"enabled"`
+		CAFile             string `yaml:"ca_file"`
+		ServerName         string `yaml:"server_name"`
+		InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+	} `yaml:"tls"`
+}
+
+// RetentionPolicy bounds one stream by entry count or by age.
+type RetentionPolicy struct {
+	MaxLen        int `yaml:"max_len"`
+	MaxAgeMinutes int `yaml:"max_age_minutes"`
+}
+
+// StrategyConfig enables an in-process Go strategy registered under Type.
+type StrategyConfig struct {
+	Type   string            `yaml:"type"`
+	ID     string            `yaml:"id"`
+	Params map[string]string `yaml:"params"`
+}
+
+// SeriesConfig describes a recurring market whose slug is derived from its
+// start time, e.g. "xrp-up-or-down-{month}-{day}-{year}-{hour}{ampm}-et".
+type SeriesConfig struct {
+	Name            string `yaml:"name"`
+	Template        string `yaml:"template"`
+	Timezone        string `yaml:"timezone"`
+	IntervalMinutes int    `yaml:"interval_minutes"`
+	LeadMinutes     int    `yaml:"lead_minutes"`
+	LingerMinutes   int    `yaml:"linger_minutes"`
+}
+
+// LoadConfig reads path strictly (unknown keys are errors), applies MANTIS_*
+// environment overrides and validates the result.
 func LoadConfig(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
+	return Parse(data)
+}
 
+func Parse(data []byte) (*Config, error) {
 	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	if err := applyEnv(&cfg, os.LookupEnv); err != nil {
+		return nil, err
+	}
+	cfg.setDefaults()
+	if err := cfg.Validate(); err != nil {
 		return nil, err
 	}
 
 	return &cfg, nil
 }
+
+func (c *Config) setDefaults() {
+	if len(c.Redis.Addrs) == 0 {
+		c.Redis.Addrs = []string{"localhost:6379"}
+	}
+	if c.Redis.UsernameEnv == "" {
+		c.Redis.UsernameEnv = "MANTIS_REDIS_USERNAME"
+	}
+	if c.Redis.PasswordEnv == "" {
+		c.Redis.PasswordEnv = "MANTIS_REDIS_PASSWORD"
+	}
+	if c.Shutdown.TimeoutSeconds == 0 {
+		c.Shutdown.TimeoutSeconds = 15
+	}
+	if c.Admin.TokenEnv == "" {
+		c.Admin.TokenEnv = "MANTIS_ADMIN_TOKEN"
+	}
+	if c.StreamWriter.QueueSize == 0 {
+		c.StreamWriter.QueueSize = 10000
+	}
+	if c.StreamWriter.BatchSize == 0 {
+		c.StreamWriter.BatchSize = 256
+	}
+	if c.StreamWriter.Overflow == "" {
+		c.StreamWriter.Overflow = "block"
+	}
+	if len(c.Candles.Intervals) == 0 {
+		c.Candles.Intervals = []string{"1s", "1m", "5m", "1h"}
+	}
+	if c.Candles.Window == 0 {
+		c.Candles.Window = 500
+	}
+	if len(c.Features.Set) == 0 {
+		c.Features.Set = []string{"mid", "microprice", "spread_ticks", "imbalance", "volatility"}
+	}
+	if c.Features.DepthLevels == 0 {
+		c.Features.DepthLevels = 5
+	}
+	if c.Features.VolatilityWindowSeconds == 0 {
+		c.Features.VolatilityWindowSeconds = 60
+	}
+	if c.Ledger.Driver == "" {
+		c.Ledger.Driver = "sqlite"
+	}
+	if c.Ledger.DSNEnv == "" {
+		c.Ledger.DSNEnv = "MANTIS_LEDGER_DSN"
+	}
+	if c.Retention.Default == (RetentionPolicy{}) {
+		c.Retention.Default.MaxLen = 1000
+	}
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShippedConfigIsValid(t *testing.T) {
+	if _, err := LoadConfig("../config.yaml"); err != nil {
+		t.Fatalf("config.yaml does not load: %v", err)
+	}
+}
+
+func TestParseRejectsUnknownFields(t *testing.T) {
+	_, err := Parse([]byte("pipelines:\n  discovery:\n    enabeld: true\n"))
+	if err == nil || !strings.Contains(err.Error(), "enabeld") {
+		t.Fatalf("expected unknown field error, got %v", err)
+	}
+}
+
+func TestParseValidates(t *testing.T) {
+	_, err := Parse([]byte("pipelines:\n  discovery:\n    enabled: true\n    interval_minutes: 0\n"))
+	if err == nil || !strings.Contains(err.Error(), "interval_minutes") {
+		t.Fatalf("expected interval validation error, got %v", err)
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	t.Setenv("MANTIS_PIPELINES_DISCOVERY_INTERVAL_MINUTES", "5")
+	t.Setenv("MANTIS_PIPELINES_ORDERBOOK_MARKETS", "a-market, b-market")
+	t.Setenv("MANTIS_REDIS_ADDRS", "redis-1:6379,redis-2:6379")
+
+	cfg, err := Parse([]byte("pipelines:\n  discovery:\n    enabled: true\n    interval_minutes: 10\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Pipelines.Discovery.IntervalMinutes != 5 {
+		t.Errorf("interval not overridden: %d", cfg.Pipelines.Discovery.IntervalMinutes)
+	}
+	if m := cfg.Pipelines.Orderbook.Markets; len(m) != 2 || m[1] != "b-market" {
+		t.Errorf("markets not overridden: %v", m)
+	}
+	if len(cfg.Redis.Addrs) != 2 {
+		t.Errorf("redis addrs not overridden: %v", cfg.Redis.Addrs)
+	}
+}
+
+func TestRetentionValidation(t *testing.T) {
+	cfg, err := Parse([]byte("retention:\n  namespaces:\n    orderbook:\n      max_age_minutes: 360\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Retention.Default.MaxLen != 1000 {
+		t.Errorf("default retention = %+v", cfg.Retention.Default)
+	}
+
+	_, err = Parse([]byte("retention:\n  namespaces:\n    orderbok:\n      max_len: 10\n  markets:\n    m:\n      max_len: 10\n      max_age_minutes: 5\n"))
+	if err == nil || !strings.Contains(err.Error(), `unknown namespace "orderbok"`) || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Fatalf("expected retention errors, got %v", err)
+	}
+}
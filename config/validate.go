@@ -0,0 +1,181 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/arjunprakash027/Mantis/pkg/logging"
+)
+
+// Validate reports every semantic problem at once.
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if len(c.Redis.Addrs) == 0 {
+		add("redis.addrs: at least one address is required")
+	}
+	if c.Redis.Cluster && c.Redis.SentinelMaster != "" {
+		add("redis: cluster and sentinel_master are mutually exclusive")
+	}
+	if c.Redis.DB < 0 {
+		add("redis.db: must not be negative")
+	}
+
+	d := c.Pipelines.Discovery
+	if d.Enabled && d.IntervalMinutes <= 0 {
+		add("pipelines.discovery.interval_minutes: must be positive, got %d", d.IntervalMinutes)
+	}
+
+	seen := make(map[string]bool)
+	for i, slug := range c.Pipelines.Orderbook.Markets {
+		switch {
+		case strings.TrimSpace(slug) == "":
+			add("pipelines.orderbook.markets[%d]: empty slug", i)
+		case seen[slug]:
+			add("pipelines.orderbook.markets[%d]: duplicate slug %q", i, slug)
+		}
+		seen[slug] = true
+	}
+
+	names := make(map[string]bool)
+	for i, s := range c.Pipelines.Orderbook.Series {
+		prefix := fmt.Sprintf("pipelines.orderbook.series[%d]", i)
+		if s.Name == "" {
+			add("%s.name: required", prefix)
+		} else if names[s.Name] {
+			add("%s.name: duplicate series %q", prefix, s.Name)
+		}
+		names[s.Name] = true
+		if s.Template == "" || !strings.Contains(s.Template, "{") {
+			add("%s.template: must contain at least one {placeholder}", prefix)
+		}
+		if s.IntervalMinutes <= 0 {
+			add("%s.interval_minutes: must be positive", prefix)
+		}
+		if s.LeadMinutes < 0 || s.LingerMinutes < 0 {
+			add("%s: lead_minutes and linger_minutes must not be negative", prefix)
+		}
+		if s.Timezone != "" {
+			if _, err := time.LoadLocation(s.Timezone); err != nil {
+				add("%s.timezone: %v", prefix, err)
+			}
+		}
+	}
+
+	if _, err := logging.ParseLevel(c.Logging.Level); err != nil {
+		add("logging.level: %v", err)
+	}
+	if f := c.Logging.Format; f != "" && f != "text" && f != "json" {
+		add("logging.format: must be text or json, got %q", f)
+	}
+	if ls := c.Logging.Sample; ls.Initial < 0 || ls.Thereafter < 0 || ls.IntervalSeconds < 0 {
+		add("logging.sample: values must not be negative")
+	}
+
+	if c.Shutdown.TimeoutSeconds < 0 {
+		add("shutdown.timeout_seconds: must not be negative")
+	}
+
+	if c.HTTP.Addr != "" {
+		if _, _, err := net.SplitHostPort(c.HTTP.Addr); err != nil {
+			add("http.addr: %v", err)
+		}
+	}
+	if c.Admin.Enabled && c.HTTP.Addr == "" {
+		add("admin: requires http.addr")
+	}
+
+	if sw := c.StreamWriter; sw.QueueSize < 0 || sw.BatchSize < 0 || sw.FlushIntervalMs < 0 {
+		add("stream_writer: queue_size, batch_size and flush_interval_ms must not be negative")
+	}
+	if o := c.StreamWriter.Overflow; o != "block" && o != "drop" {
+		add("stream_writer.overflow: must be block or drop, got %q", o)
+	}
+
+	for i, iv := range c.Candles.Intervals {
+		if d, err := time.ParseDuration(iv); err != nil || d < time.Second || d%time.Second != 0 {
+			add("candles.intervals[%d]: want a whole number of seconds like 1s, 5m or 1h, got %q", i, iv)
+		}
+	}
+	if c.Candles.Window < 0 {
+		add("candles.window: must not be negative")
+	}
+
+	for i, name := range c.Features.Set {
+		switch name {
+		case "mid", "microprice", "spread_ticks", "imbalance", "volatility":
+		default:
+			add("features.set[%d]: unknown feature %q", i, name)
+		}
+	}
+	if c.Features.DepthLevels < 0 {
+		add("features.depth_levels: must not be negative")
+	}
+	if c.Features.VolatilityWindowSeconds < 0 {
+		add("features.volatility_window_seconds: must not be negative")
+	}
+
+	if c.Arb.MinEdge < 0 {
+		add("arb.min_edge: must not be negative")
+	}
+	if c.Arb.FeeBps < 0 || c.Arb.FeeBps >= 10000 {
+		add("arb.fee_bps: must be in [0, 10000)")
+	}
+
+	checkRetention := func(name string, p RetentionPolicy) {
+		switch {
+		case p.MaxLen < 0 || p.MaxAgeMinutes < 0:
+			add("%s: values must not be negative", name)
+		case p.MaxLen > 0 && p.MaxAgeMinutes > 0:
+			add("%s: max_len and max_age_minutes are mutually exclusive", name)
+		case p.MaxLen == 0 && p.MaxAgeMinutes == 0:
+			add("%s: set max_len or max_age_minutes", name)
+		}
+	}
+	checkRetention("retention.default", c.Retention.Default)
+	for ns, p := range c.Retention.Namespaces {
+		if ns != "orderbook" && ns != "market" && ns != "discovery" && ns != "candles" && ns != "features" && ns != "arb" {
+			add("retention.namespaces: unknown namespace %q", ns)
+		}
+		checkRetention("retention.namespaces."+ns, p)
+	}
+	for slug, p := range c.Retention.Markets {
+		checkRetention("retention.markets."+slug, p)
+	}
+
+	if d := c.Ledger.Driver; d != "sqlite" && d != "postgres" {
+		add("ledger.driver: must be sqlite or postgres, got %q", d)
+	}
+	if c.Ledger.SnapshotIntervalMinutes < 0 {
+		add("ledger.snapshot_interval_minutes: must not be negative")
+	}
+
+	if c.Recorder.Enabled && c.Recorder.Dir == "" {
+		add("recorder.dir: required when the recorder is enabled")
+	}
+	if c.Recorder.RotateMinutes < 0 || c.Recorder.MaxFileMB < 0 {
+		add("recorder: rotate_minutes and max_file_mb must not be negative")
+	}
+
+	ids := make(map[string]bool)
+	for i, s := range c.Strategies {
+		if s.Type == "" || s.ID == "" {
+			add("strategies[%d]: type and id are required", i)
+		} else if ids[s.ID] {
+			add("strategies[%d].id: duplicate id %q", i, s.ID)
+		}
+		ids[s.ID] = true
+	}
+
+	if c.Risk.MaxOrderAmount < 0 || c.Risk.MaxOrderNotional < 0 {
+		add("risk: limits must not be negative")
+	}
+
+	return errors.Join(errs...)
+}
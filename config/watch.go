@@ -0,0 +1,43 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"time"
+
+	"github.com/arjunprakash027/Mantis/pkg/logging"
+)
+
+// Watch polls path and calls onChange with every new configuration that
+// parses and validates. Broken edits are logged and ignored, so the running
+// configuration stays in effect until the file is fixed.
+func Watch(ctx context.Context, path string, interval time.Duration, onChange func(*Config)) {
+	logger := logging.For("config").With("path", path)
+	last, _ := os.ReadFile(path)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil || bytes.Equal(data, last) {
+			continue
+		}
+		last = data
+
+		cfg, err := Parse(data)
+		if err != nil {
+			logger.Error("config reload rejected", "err", err)
+			continue
+		}
+		logger.Info("config reloaded")
+		onChange(cfg)
+	}
+}
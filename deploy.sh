@@ -6,7 +6,7 @@ REMOTE_DIR="~/workdesk/mantis"
 BINARY_NAME="mantis"
 
 echo "Building Mantis (AMD64)..."
-GOOS=linux GOARCH=amd64 go build -ldflags="-s -w" -o $BINARY_NAME main.go
+GOOS=linux GOARCH=amd64 go build -ldflags="-s -w" -o $BINARY_NAME .
 
 echo "Deploying to $REMOTE_ALIAS..."
 
@@ -4,72 +4,177 @@ import (
 	"context"
 	_ "embed"
 	"encoding/json"
-	"log"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/arjunprakash027/Mantis/market"
+	"github.com/arjunprakash027/Mantis/pkg/logging"
+	"github.com/arjunprakash027/Mantis/pkg/metrics"
 	"github.com/arjunprakash027/Mantis/pkg/redismantis"
+	"github.com/arjunprakash027/Mantis/pkg/wire"
 	"github.com/arjunprakash027/Mantis/streamer"
 	"github.com/redis/go-redis/v9"
 )
 
-type Signal struct {
-	Action     string  `json:"action"`
-	Asset      string  `json:"asset"`
-	Amount     float64 `json:"amount"`
-	StrategyID string  `json:"strategy_id"`
-}
+// Signal and ExecutionResult are the JSON payloads on signals:inbound and
+// signals:outbound, shared with the client SDK.
+type (
+	Signal          = wire.Signal
+	ExecutionResult = wire.ExecutionResult
+)
 
-type ExecutionResult struct {
-	Success      bool    `json:"success"`
-	FilledPrice  float64 `json:"filled_price"`
-	FilledAmount float64 `json:"filled_amount"`
-	Fee          float64 `json:"fee"`
-	ErrorMsg     string  `json:"error_msg,omitempty"`
-	Timestamp    int64   `json:"timestamp"`
+// Rejection codes reported in ExecutionResult.ErrorCode.
+const (
+	CodeInvalidAction     = "INVALID_ACTION"
+	CodeInvalidAmount     = "INVALID_AMOUNT"
+	CodeMarketNotTradable = "MARKET_NOT_TRADABLE"
+	CodeBelowMinSize      = "BELOW_MIN_ORDER_SIZE"
+	CodeNotStreamed       = "ASSET_NOT_STREAMED"
+	CodeStalePrice        = "STALE_PRICE"
+	CodeNoLiquidity       = "NO_LIQUIDITY"
+	CodeRiskLimit         = "RISK_LIMIT"
+	CodeRejected          = "REJECTED"
+	CodeShuttingDown      = "SHUTTING_DOWN"
+	CodeInternal          = "INTERNAL_ERROR"
+)
+
+// Limits caps individual orders. Zero disables a limit.
+type Limits struct {
+	MaxOrderAmount   float64
+	MaxOrderNotional float64
 }
 
 type Executor struct {
-	rdb    *redis.Client
+	rdb    redis.UniversalClient
 	engine *streamer.Engine
 	ctx    context.Context
+
+	limitsMu sync.RWMutex
+	limits   Limits
+
+	statusMu sync.Mutex
+	status   LoopStatus
+
+	// readCtx is cancelled by Stop; ctx keeps serving in-flight trades.
+	readCtx  context.Context
+	stopRead context.CancelFunc
+	started  atomic.Bool
+	done     chan struct{}
+
+	logger *slog.Logger
+}
+
+// LoopStatus describes the signals:inbound consumer loop.
+type LoopStatus struct {
+	Running   bool      `json:"running"`
+	LastPoll  time.Time `json:"last_poll"`
+	LastError string    `json:"last_error,omitempty"`
 }
 
+// pollBlock bounds each XREADGROUP so LastPoll advances while idle.
+var pollBlock = 5 * time.Second
+
 //go:embed trade.lua
 var tradeLua string
 
 var tradeScript = redis.NewScript(tradeLua)
 
-func NewExecutor(ctx context.Context, rdb *redis.Client, engine *streamer.Engine) *Executor {
+func NewExecutor(ctx context.Context, rdb redis.UniversalClient, engine *streamer.Engine) *Executor {
+	readCtx, stopRead := context.WithCancel(ctx)
 	return &Executor{
-		rdb:    rdb,
-		engine: engine,
-		ctx:    ctx,
+		rdb:      rdb,
+		engine:   engine,
+		ctx:      ctx,
+		readCtx:  readCtx,
+		stopRead: stopRead,
+		done:     make(chan struct{}),
+		logger:   logging.For("executor"),
+	}
+}
+
+// SetLimits replaces the risk limits; safe to call while running.
+func (e *Executor) SetLimits(l Limits) {
+	e.limitsMu.Lock()
+	defer e.limitsMu.Unlock()
+	e.limits = l
+}
+
+func (e *Executor) Limits() Limits {
+	e.limitsMu.RLock()
+	defer e.limitsMu.RUnlock()
+	return e.limits
+}
+
+// Status reports the consumer loop state for health checks.
+func (e *Executor) Status() LoopStatus {
+	e.statusMu.Lock()
+	defer e.statusMu.Unlock()
+	return e.status
+}
+
+func (e *Executor) setStatus(running bool, err error) {
+	e.statusMu.Lock()
+	defer e.statusMu.Unlock()
+	e.status.Running = running
+	e.status.LastPoll = time.Now()
+	e.status.LastError = ""
+	if err != nil {
+		e.status.LastError = err.Error()
 	}
 }
 
+// Start consumes signals:inbound until Stop is called or the executor's
+// context ends.
 func (e *Executor) Start() {
+	e.started.Store(true)
+	defer close(e.done)
 
-	log.Println("Executor Started: Listening on signals:inbound")
+	e.logger.Info("executor started", "stream", redismantis.StreamSignalsInbound)
 	e.rdb.XGroupCreateMkStream(e.ctx, redismantis.StreamSignalsInbound, redismantis.GroupMantisExecutors, "$")
+	e.setStatus(true, nil)
+	defer e.setStatus(false, nil)
 
+	// Signals read but never acknowledged (e.g. a read cut short by the last
+	// shutdown) are redelivered from the pending list before new ones.
+	id := "0"
 	for {
-		streams, err := e.rdb.XReadGroup(e.ctx, &redis.XReadGroupArgs{
+		streams, err := e.rdb.XReadGroup(e.readCtx, &redis.XReadGroupArgs{
 			Group:    redismantis.GroupMantisExecutors,
 			Consumer: redismantis.ConsumerWorker1,
-			Streams:  []string{redismantis.StreamSignalsInbound, ">"},
+			Streams:  []string{redismantis.StreamSignalsInbound, id},
 			Count:    1,
-			Block:    0,
+			Block:    pollBlock,
 		}).Result()
 
-		if e.ctx.Err() != nil {
+		if e.readCtx.Err() != nil {
 			return
 		}
 
+		if err == redis.Nil {
+			e.setStatus(true, nil)
+			continue
+		}
+		e.setStatus(true, err)
 		if err != nil {
-			log.Printf("Redis Stream Error [%s]: %v", "signals:inbound", err)
+			e.logger.Error("signal read failed", "stream", redismantis.StreamSignalsInbound, "err", err)
+			// Avoid spinning while Redis is down.
+			select {
+			case <-e.readCtx.Done():
+				return
+			case <-time.After(time.Second):
+			}
 			continue
 		}
 
+		if id == "0" && len(streams[0].Messages) == 0 {
+			id = ">"
+			continue
+		}
 		for _, msg := range streams[0].Messages {
 			e.processSignal(msg)
 			e.rdb.XAck(e.ctx, redismantis.StreamSignalsInbound, redismantis.GroupMantisExecutors, msg.ID)
@@ -77,30 +182,112 @@ func (e *Executor) Start() {
 	}
 }
 
+// Stop stops reading new signals and waits until the one in progress has been
+// executed and acknowledged, or ctx ends. Redis must stay open until it
+// returns.
+func (e *Executor) Stop(ctx context.Context) error {
+	e.stopRead()
+	if !e.started.Load() {
+		return nil
+	}
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (e *Executor) processSignal(msg redis.XMessage) {
 	var sig Signal
 
 	dataStr, ok := msg.Values["data"].(string)
 	if !ok {
-		log.Printf("Invalid signal format: missing 'data' field")
+		e.logger.Warn("invalid signal: missing data field", "id", msg.ID)
+		metrics.Signals.WithLabelValues("invalid", "").Inc()
 		return
 	}
 
 	if err := json.Unmarshal([]byte(dataStr), &sig); err != nil {
-		log.Printf("Invalid JSON: %v", err)
+		e.logger.Warn("invalid signal JSON", "id", msg.ID, "err", err)
+		metrics.Signals.WithLabelValues("invalid", "").Inc()
 		return
 	}
+	if sig.ID == "" {
+		sig.ID = msg.ID
+	}
+
+	e.submit(sig)
+}
+
+// Submit executes an in-process signal, publishes the result on
+// signals:outbound and records it in the metrics, exactly like a signal read
+// from Redis. Once Stop has been called it rejects with SHUTTING_DOWN.
+func (e *Executor) Submit(sig Signal) ExecutionResult {
+	if e.readCtx.Err() != nil {
+		res := ExecutionResult{Success: false, ErrorCode: CodeShuttingDown, ErrorMsg: "Executor is shutting down"}
+		e.respond(sig, res)
+		return res
+	}
+	return e.submit(sig)
+}
+
+func (e *Executor) submit(sig Signal) ExecutionResult {
+	start := time.Now()
+	res := e.Execute(sig)
+	metrics.ExecutionSeconds.Observe(metrics.Since(start))
+	if res.Success {
+		metrics.Signals.WithLabelValues("filled", "").Inc()
+	} else {
+		metrics.Signals.WithLabelValues("rejected", res.ErrorCode).Inc()
+	}
+	e.respond(sig, res)
+	return res
+}
+
+// Execute fills a signal against the cached book and returns the outcome
+// without publishing it. Time is read from the engine's clock so backtests
+// see the same stale guard as live trading.
+func (e *Executor) Execute(sig Signal) ExecutionResult {
+	now := e.engine.Clock().Now().Unix()
+
+	if sig.Action != "BUY" && sig.Action != "SELL" {
+		return ExecutionResult{Success: false, ErrorCode: CodeInvalidAction, ErrorMsg: "Action must be BUY or SELL"}
+	}
+	// A negative amount would slip under the risk limits and turn a BUY
+	// into a cash credit.
+	if !(sig.Amount > 0) || math.IsInf(sig.Amount, 0) {
+		return ExecutionResult{Success: false, ErrorCode: CodeInvalidAmount, ErrorMsg: "Amount must be a positive number"}
+	}
+
+	// Signals read from Redis always carry an ID; the trade script remembers
+	// filled ones so a redelivery after a crash does not trade twice. Check
+	// first, since a redelivered signal may no longer pass the price checks.
+	signalKey := ""
+	if sig.ID != "" {
+		signalKey = sig.StrategyID + ":" + sig.ID
+		if prev, err := e.rdb.HGet(e.ctx, redismantis.HashFilledSignals, signalKey).Result(); err == nil {
+			return duplicateFill(sig, prev)
+		}
+	}
+
+	meta, hasMeta := e.engine.GetMetadata(sig.Asset)
+	if hasMeta && !meta.Status.Tradable() {
+		return ExecutionResult{Success: false, ErrorCode: CodeMarketNotTradable, ErrorMsg: "Market is " + string(meta.Status)}
+	}
+
+	if hasMeta && meta.MinOrderSize > 0 && sig.Amount < meta.MinOrderSize {
+		return ExecutionResult{Success: false, ErrorCode: CodeBelowMinSize, ErrorMsg: "Below minimum order size"}
+	}
 
 	priceState, exists := e.engine.GetPrice(sig.Asset)
 
 	if !exists {
-		e.respond(sig, ExecutionResult{Success: false, ErrorMsg: "Asset not streamed"})
-		return
+		return ExecutionResult{Success: false, ErrorCode: CodeNotStreamed, ErrorMsg: "Asset not streamed"}
 	}
 
-	if time.Now().Unix()-priceState.LastUpdated > 60 {
-		e.respond(sig, ExecutionResult{Success: false, ErrorMsg: "Stale price (stream lagging or dead)"})
-		return
+	if now-priceState.LastUpdated > 60 {
+		return ExecutionResult{Success: false, ErrorCode: CodeStalePrice, ErrorMsg: "Stale price (stream lagging or dead)"}
 	}
 
 	fillPrice := 0.0
@@ -111,37 +298,88 @@ func (e *Executor) processSignal(msg redis.XMessage) {
 	}
 
 	if fillPrice <= 0 {
-		e.respond(sig, ExecutionResult{Success: false, ErrorMsg: "No liquidity (price 0)"})
-		return
+		return ExecutionResult{Success: false, ErrorCode: CodeNoLiquidity, ErrorMsg: "No liquidity (price 0)"}
 	}
 
 	totalCost := fillPrice * sig.Amount
 
+	limits := e.Limits()
+	if limits.MaxOrderAmount > 0 && sig.Amount > limits.MaxOrderAmount {
+		return ExecutionResult{Success: false, ErrorCode: CodeRiskLimit, ErrorMsg: "Order amount exceeds max_order_amount"}
+	}
+	if limits.MaxOrderNotional > 0 && totalCost > limits.MaxOrderNotional {
+		return ExecutionResult{Success: false, ErrorCode: CodeRiskLimit, ErrorMsg: "Order notional exceeds max_order_notional"}
+	}
+
+	outcome, marketName := e.describe(sig.Asset, meta, hasMeta)
+
 	res, err := tradeScript.Run(e.ctx, e.rdb,
-		[]string{redismantis.HashPortfolioBalance, redismantis.HashTradeLog},
-		sig.Action, sig.Asset, sig.Amount, fillPrice, totalCost, time.Now().Unix(), sig.StrategyID,
+		[]string{redismantis.HashPortfolioBalance, redismantis.HashTradeLog, redismantis.HashPortfolioCost,
+			redismantis.HashFilledSignals, redismantis.ZSetFilledSignals},
+		sig.Action, sig.Asset, sig.Amount, fillPrice, totalCost, now, sig.StrategyID, outcome, marketName, signalKey,
 	).Result()
 
 	if err != nil {
-		log.Printf("Redis Lua Error: %v", err)
-		e.respond(sig, ExecutionResult{Success: false, ErrorMsg: "Internal DB Error"})
-		return
+		e.logger.Error("trade script failed", "asset_id", sig.Asset, "strategy_id", sig.StrategyID, "err", err)
+		return ExecutionResult{Success: false, ErrorCode: CodeInternal, ErrorMsg: "Internal DB Error"}
 	}
 
 	resSlice := res.([]interface{})
+	if resSlice[0].(int64) == 2 {
+		return duplicateFill(sig, resSlice[1].(string))
+	}
 	success := resSlice[0].(int64) == 1
 
 	result := ExecutionResult{
 		Success:      success,
 		FilledPrice:  fillPrice,
 		FilledAmount: sig.Amount,
-		Timestamp:    time.Now().Unix(),
+		Timestamp:    now,
 	}
 	if !success {
+		result.ErrorCode = CodeRejected
 		result.ErrorMsg = resSlice[1].(string)
 	}
 
-	e.respond(sig, result)
+	return result
+}
+
+// duplicateFill rebuilds the result of a signal that had already filled from
+// the "price timestamp" pair the trade script stored for it.
+func duplicateFill(sig Signal, prev string) ExecutionResult {
+	res := ExecutionResult{Success: true, FilledAmount: sig.Amount}
+	if price, ts, ok := strings.Cut(prev, " "); ok {
+		res.FilledPrice, _ = strconv.ParseFloat(price, 64)
+		res.Timestamp, _ = strconv.ParseInt(ts, 10, 64)
+	}
+	return res
+}
+
+// describe returns the outcome and market name for the trade log. They are
+// passed to the Lua script as arguments because reading token:meta inside it
+// would cross hash slots on Redis Cluster.
+func (e *Executor) describe(asset string, meta market.Token, hasMeta bool) (string, string) {
+	outcome, marketName := "unknown", "unknown"
+	if hasMeta {
+		if meta.Outcome != "" {
+			outcome = meta.Outcome
+		}
+		if meta.Market != "" {
+			marketName = meta.Market
+		}
+		return outcome, marketName
+	}
+
+	vals, err := e.rdb.HMGet(e.ctx, redismantis.HashTokenMeta(asset), "outcome", "market").Result()
+	if err == nil {
+		if v, ok := vals[0].(string); ok {
+			outcome = v
+		}
+		if v, ok := vals[1].(string); ok {
+			marketName = v
+		}
+	}
+	return outcome, marketName
 }
 
 func (e *Executor) respond(sig Signal, res ExecutionResult) {
@@ -151,13 +389,15 @@ func (e *Executor) respond(sig Signal, res ExecutionResult) {
 		Stream: redismantis.StreamSignalsOutbound,
 		Values: map[string]interface{}{
 			"strategy_id": sig.StrategyID,
+			"signal_id":   sig.ID,
 			"data":        jsonRes,
 		},
 	})
 
+	logger := e.logger.With("strategy_id", sig.StrategyID, "asset_id", sig.Asset, "action", sig.Action, "amount", sig.Amount)
 	if res.Success {
-		log.Printf("%s %s | Price: %.2f | Amount: %.2f", sig.Action, sig.Asset, res.FilledPrice, sig.Amount)
+		logger.Info("order filled", "price", res.FilledPrice)
 	} else {
-		log.Printf("%s REJECTED | Asset: %s | Reason: %s", sig.Action, sig.Asset, res.ErrorMsg)
+		logger.Warn("order rejected", "error_code", res.ErrorCode, "reason", res.ErrorMsg)
 	}
 }
@@ -2,11 +2,17 @@ package executor
 
 import (
 	"context"
+	"encoding/json"
+	"math"
 	"os"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/alicebob/miniredis/v2"
+	"github.com/arjunprakash027/Mantis/market"
+	"github.com/arjunprakash027/Mantis/pkg/clock"
+	"github.com/arjunprakash027/Mantis/pkg/redismantis"
 	"github.com/arjunprakash027/Mantis/streamer"
 	"github.com/redis/go-redis/v9"
 )
@@ -34,15 +40,15 @@ func TestAtomicTrade(t *testing.T) {
 	exec := NewExecutor(ctx, rdb, engine)
 
 	priceChan := make(chan []byte, 1)
-	go engine.ProcessStream("orderbook", priceChan)
+	engine.ProcessStream("orderbook", priceChan)
 
-	rdb.HSet(ctx, "portfolio:balance", "USD", 10.00)
+	rdb.HSet(ctx, redismantis.HashPortfolioBalance, "USD", 10.00)
 	rdb.HSet(ctx, "token:meta:Asset_123", map[string]interface{}{
 		"market":  "Bitcoin Moon",
 		"outcome": "Yes",
 	})
 
-	priceChan <- []byte(`{"asset_id":"Asset_123","bids":[{"price":"0.48"}],"asks":[{"price":"0.50"}]}`)
+	priceChan <- []byte(`{"asset_id":"Asset_123","bids":[{"price":"0.48","size":"100"}],"asks":[{"price":"0.50","size":"100"}]}`)
 	time.Sleep(10 * time.Millisecond)
 
 	rdb.XGroupCreateMkStream(ctx, "signals:inbound", "mantis_executors", "$")
@@ -62,7 +68,7 @@ func TestAtomicTrade(t *testing.T) {
 
 	exec.processSignal(streams[0].Messages[0])
 
-	balance, _ := rdb.HGet(ctx, "portfolio:balance", "USD").Float64()
+	balance, _ := rdb.HGet(ctx, redismantis.HashPortfolioBalance, "USD").Float64()
 	if balance != 5.00 {
 		t.Errorf("Expected balance 5.00, got %.2f", balance)
 	}
@@ -74,10 +80,10 @@ func TestInsufficientFunds(t *testing.T) {
 	exec := NewExecutor(ctx, rdb, engine)
 
 	priceChan := make(chan []byte, 1)
-	go engine.ProcessStream("orderbook", priceChan)
+	engine.ProcessStream("orderbook", priceChan)
 
-	rdb.HSet(ctx, "portfolio:balance", "USD", 1.00)
-	priceChan <- []byte(`{"asset_id":"Asset_123","bids":[{"price":"0.48"}],"asks":[{"price":"0.50"}]}`)
+	rdb.HSet(ctx, redismantis.HashPortfolioBalance, "USD", 1.00)
+	priceChan <- []byte(`{"asset_id":"Asset_123","bids":[{"price":"0.48","size":"100"}],"asks":[{"price":"0.50","size":"100"}]}`)
 	time.Sleep(10 * time.Millisecond)
 
 	rdb.XGroupCreateMkStream(ctx, "signals:inbound", "mantis_executors", "$")
@@ -97,7 +103,7 @@ func TestInsufficientFunds(t *testing.T) {
 
 	exec.processSignal(streams[0].Messages[0])
 
-	balance, _ := rdb.HGet(ctx, "portfolio:balance", "USD").Float64()
+	balance, _ := rdb.HGet(ctx, redismantis.HashPortfolioBalance, "USD").Float64()
 	if balance != 1.00 {
 		t.Errorf("Balance changed despite insufficient funds: got %.2f", balance)
 	}
@@ -108,7 +114,7 @@ func TestAssetNotStreamed(t *testing.T) {
 	engine := streamer.NewEngine(ctx, rdb)
 	exec := NewExecutor(ctx, rdb, engine)
 
-	rdb.HSet(ctx, "portfolio:balance", "USD", 100.00)
+	rdb.HSet(ctx, redismantis.HashPortfolioBalance, "USD", 100.00)
 
 	rdb.XGroupCreateMkStream(ctx, "signals:inbound", "mantis_executors", "$")
 	rdb.XAdd(ctx, &redis.XAddArgs{
@@ -127,8 +133,397 @@ func TestAssetNotStreamed(t *testing.T) {
 
 	exec.processSignal(streams[0].Messages[0])
 
-	balance, _ := rdb.HGet(ctx, "portfolio:balance", "USD").Float64()
+	balance, _ := rdb.HGet(ctx, redismantis.HashPortfolioBalance, "USD").Float64()
 	if balance != 100.00 {
 		t.Errorf("Trade processed for unknown asset")
 	}
 }
+
+func TestMarketNotTradable(t *testing.T) {
+	rdb.FlushAll(ctx)
+	engine := streamer.NewEngine(ctx, rdb)
+	exec := NewExecutor(ctx, rdb, engine)
+
+	priceChan := make(chan []byte, 1)
+	engine.ProcessStream("orderbook", priceChan)
+
+	rdb.HSet(ctx, redismantis.HashPortfolioBalance, "USD", 100.00)
+	engine.RegisterMetadata("closed-market", []market.Token{{TokenID: "Asset_123", Outcome: "Yes"}})
+	priceChan <- []byte(`{"asset_id":"Asset_123","bids":[{"price":"0.48","size":"100"}],"asks":[{"price":"0.50","size":"100"}]}`)
+	priceChan <- []byte(`{"event_type":"market_resolved","assets_ids":["Asset_123"]}`)
+	time.Sleep(10 * time.Millisecond)
+
+	rdb.XGroupCreateMkStream(ctx, "signals:inbound", "mantis_executors", "$")
+	rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: "signals:inbound",
+		Values: map[string]interface{}{
+			"data": `{"action":"BUY", "asset":"Asset_123", "amount": 10.0}`,
+		},
+	})
+
+	streams, _ := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    "mantis_executors",
+		Consumer: "test_worker",
+		Streams:  []string{"signals:inbound", ">"},
+		Count:    1,
+	}).Result()
+
+	exec.processSignal(streams[0].Messages[0])
+
+	balance, _ := rdb.HGet(ctx, redismantis.HashPortfolioBalance, "USD").Float64()
+	if balance != 100.00 {
+		t.Errorf("Trade processed on resolved market")
+	}
+
+	out, _ := rdb.XRange(ctx, "signals:outbound", "-", "+").Result()
+	if len(out) != 1 {
+		t.Fatalf("expected one response, got %d", len(out))
+	}
+	if id := out[0].Values["signal_id"]; id != streams[0].Messages[0].ID {
+		t.Errorf("signal_id = %v, want the inbound entry ID %s", id, streams[0].Messages[0].ID)
+	}
+	var res ExecutionResult
+	json.Unmarshal([]byte(out[0].Values["data"].(string)), &res)
+	if res.ErrorCode != CodeMarketNotTradable {
+		t.Errorf("expected %s, got %q", CodeMarketNotTradable, res.ErrorCode)
+	}
+}
+
+func TestInvalidSignalsRejected(t *testing.T) {
+	rdb.FlushAll(ctx)
+	engine := streamer.NewEngine(ctx, rdb)
+	engine.Process("orderbook", []byte(`{"asset_id":"Asset_V","bids":[{"price":"0.48","size":"100"}],"asks":[{"price":"0.50","size":"100"}]}`))
+	rdb.HSet(ctx, redismantis.HashPortfolioBalance, "USD", 10.00)
+	exec := NewExecutor(ctx, rdb, engine)
+	exec.SetLimits(Limits{MaxOrderAmount: 100, MaxOrderNotional: 50})
+
+	// In-process strategies.
+	cases := []struct {
+		sig  Signal
+		code string
+	}{
+		{Signal{Action: "BUY", Asset: "Asset_V", Amount: -10}, CodeInvalidAmount},
+		{Signal{Action: "SELL", Asset: "Asset_V", Amount: 0}, CodeInvalidAmount},
+		{Signal{Action: "BUY", Asset: "Asset_V", Amount: math.NaN()}, CodeInvalidAmount},
+		{Signal{Action: "BUY", Asset: "Asset_V", Amount: math.Inf(1)}, CodeInvalidAmount},
+		{Signal{Action: "buy", Asset: "Asset_V", Amount: 1}, CodeInvalidAction},
+		{Signal{Asset: "Asset_V", Amount: 1}, CodeInvalidAction},
+	}
+	for _, c := range cases {
+		if res := exec.Submit(c.sig); res.Success || res.ErrorCode != c.code {
+			t.Errorf("%s %v: got success=%v code %q, want %s", c.sig.Action, c.sig.Amount, res.Success, res.ErrorCode, c.code)
+		}
+	}
+
+	// Signals read from Redis.
+	rdb.XGroupCreateMkStream(ctx, redismantis.StreamSignalsInbound, redismantis.GroupMantisExecutors, "$")
+	rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: redismantis.StreamSignalsInbound,
+		Values: map[string]interface{}{"data": `{"id":"neg","action":"BUY","asset":"Asset_V","amount":-10}`},
+	})
+	streams, _ := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    redismantis.GroupMantisExecutors,
+		Consumer: redismantis.ConsumerWorker1,
+		Streams:  []string{redismantis.StreamSignalsInbound, ">"},
+		Count:    1,
+	}).Result()
+	exec.processSignal(streams[0].Messages[0])
+
+	out, _ := rdb.XRevRangeN(ctx, redismantis.StreamSignalsOutbound, "+", "-", 1).Result()
+	if len(out) != 1 {
+		t.Fatal("no result published for the Redis signal")
+	}
+	var res ExecutionResult
+	json.Unmarshal([]byte(out[0].Values["data"].(string)), &res)
+	if res.Success || res.ErrorCode != CodeInvalidAmount {
+		t.Errorf("Redis signal: got success=%v code %q, want %s", res.Success, res.ErrorCode, CodeInvalidAmount)
+	}
+
+	if bal, _ := rdb.HGet(ctx, redismantis.HashPortfolioBalance, "USD").Float64(); bal != 10 {
+		t.Errorf("cash changed to %v", bal)
+	}
+	if n, _ := rdb.HExists(ctx, redismantis.HashPortfolioBalance, "Asset_V").Result(); n {
+		t.Error("an invalid signal opened a position")
+	}
+}
+
+func TestLoopStatus(t *testing.T) {
+	pollBlock = 50 * time.Millisecond
+	rdb.FlushAll(ctx)
+	loopCtx, cancel := context.WithCancel(ctx)
+	exec := NewExecutor(loopCtx, rdb, streamer.NewEngine(loopCtx, rdb))
+
+	if exec.Status().Running {
+		t.Fatal("loop reported running before Start")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		exec.Start()
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for !exec.Status().Running {
+		if time.Now().After(deadline) {
+			t.Fatal("loop never reported running")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+	if exec.Status().Running {
+		t.Fatal("loop still reported running after shutdown")
+	}
+}
+
+func TestPendingRedeliveredAndStop(t *testing.T) {
+	pollBlock = 50 * time.Millisecond
+	rdb.FlushAll(ctx)
+	engine := streamer.NewEngine(ctx, rdb)
+	engine.Process("orderbook", []byte(`{"asset_id":"Asset_P","bids":[{"price":"0.48","size":"100"}],"asks":[{"price":"0.50","size":"100"}]}`))
+	rdb.HSet(ctx, redismantis.HashPortfolioBalance, "USD", 10.00)
+
+	// A signal read by the previous run but never acknowledged.
+	rdb.XGroupCreateMkStream(ctx, redismantis.StreamSignalsInbound, redismantis.GroupMantisExecutors, "$")
+	rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: redismantis.StreamSignalsInbound,
+		Values: map[string]interface{}{"data": `{"action":"BUY","asset":"Asset_P","amount":2}`},
+	})
+	rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    redismantis.GroupMantisExecutors,
+		Consumer: redismantis.ConsumerWorker1,
+		Streams:  []string{redismantis.StreamSignalsInbound, ">"},
+		Count:    1,
+	})
+
+	exec := NewExecutor(ctx, rdb, engine)
+	go exec.Start()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		n, _ := rdb.XLen(ctx, redismantis.StreamSignalsOutbound).Result()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("pending signal was not redelivered")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	stopCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := exec.Stop(stopCtx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	pending, _ := rdb.XPending(ctx, redismantis.StreamSignalsInbound, redismantis.GroupMantisExecutors).Result()
+	if pending.Count != 0 {
+		t.Fatalf("expected no pending signals after Stop, got %d", pending.Count)
+	}
+	if bal, _ := rdb.HGet(ctx, redismantis.HashPortfolioBalance, "Asset_P").Float64(); bal != 2 {
+		t.Fatalf("expected position 2, got %v", bal)
+	}
+}
+
+func TestRedeliveredSignalFillsOnce(t *testing.T) {
+	rdb.FlushAll(ctx)
+	engine := streamer.NewEngine(ctx, rdb)
+	engine.Process("orderbook", []byte(`{"asset_id":"Asset_D","bids":[{"price":"0.48","size":"100"}],"asks":[{"price":"0.50","size":"100"}]}`))
+	rdb.HSet(ctx, redismantis.HashPortfolioBalance, "USD", 10.00)
+	exec := NewExecutor(ctx, rdb, engine)
+
+	msg := redis.XMessage{ID: "1-1", Values: map[string]interface{}{"data": `{"action":"BUY","asset":"Asset_D","amount":2,"strategy_id":"bot"}`}}
+	exec.processSignal(msg)
+	// The same entry again, as after a crash between the fill and the XACK,
+	// and once the price has moved.
+	engine.Process("orderbook", []byte(`{"event_type":"book","asset_id":"Asset_D","bids":[{"price":"0.58","size":"100"}],"asks":[{"price":"0.60","size":"100"}]}`))
+	exec.processSignal(msg)
+
+	if bal, _ := rdb.HGet(ctx, redismantis.HashPortfolioBalance, "Asset_D").Float64(); bal != 2 {
+		t.Errorf("expected position 2, got %v", bal)
+	}
+	if n, _ := rdb.XLen(ctx, redismantis.HashTradeLog).Result(); n != 1 {
+		t.Errorf("expected one trade log entry, got %d", n)
+	}
+	results, _ := rdb.XRange(ctx, redismantis.StreamSignalsOutbound, "-", "+").Result()
+	if len(results) != 2 {
+		t.Fatalf("expected a result per delivery, got %d", len(results))
+	}
+	var res ExecutionResult
+	json.Unmarshal([]byte(results[1].Values["data"].(string)), &res)
+	if !res.Success || res.FilledPrice != 0.5 {
+		t.Errorf("redelivery result = %+v, want the original fill", res)
+	}
+
+	// Another strategy may reuse the ID.
+	exec.processSignal(redis.XMessage{ID: "1-2", Values: map[string]interface{}{"data": `{"id":"1-1","action":"BUY","asset":"Asset_D","amount":1,"strategy_id":"other"}`}})
+	if bal, _ := rdb.HGet(ctx, redismantis.HashPortfolioBalance, "Asset_D").Float64(); bal != 3 {
+		t.Errorf("expected position 3, got %v", bal)
+	}
+}
+
+func TestFilledSignalsExpire(t *testing.T) {
+	rdb.FlushAll(ctx)
+	sim := clock.NewSim(time.Unix(1735689600, 0))
+	engine := streamer.NewEngine(ctx, rdb)
+	engine.SetClock(sim)
+	engine.Process("orderbook", []byte(`{"event_type":"book","asset_id":"Asset_E","bids":[{"price":"0.48","size":"100"}],"asks":[{"price":"0.50","size":"100"}]}`))
+	rdb.HSet(ctx, redismantis.HashPortfolioBalance, "USD", 1e6)
+	exec := NewExecutor(ctx, rdb, engine)
+
+	if res := exec.Execute(Signal{ID: "first", Action: "BUY", Asset: "Asset_E", Amount: 1}); !res.Success {
+		t.Fatalf("first fill: %+v", res)
+	}
+	// More remembered fills than unpack can take in one call, as the trade
+	// script records them.
+	now := sim.Now().Unix()
+	pipe := rdb.Pipeline()
+	for i := 0; i < 10050; i++ {
+		key := ":" + strconv.Itoa(i)
+		pipe.HSet(ctx, redismantis.HashFilledSignals, key, "0.5 "+strconv.FormatInt(now, 10))
+		pipe.ZAdd(ctx, redismantis.ZSetFilledSignals, redis.Z{Score: float64(now), Member: key})
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// A day later every remembered signal expires in one call.
+	sim.Advance(25 * time.Hour)
+	engine.Process("orderbook", []byte(`{"event_type":"book","asset_id":"Asset_E","bids":[{"price":"0.48","size":"100"}],"asks":[{"price":"0.50","size":"100"}]}`))
+	if res := exec.Execute(Signal{ID: "next", Action: "BUY", Asset: "Asset_E", Amount: 1}); !res.Success {
+		t.Fatalf("fill after expiry: %+v", res)
+	}
+	if h, z := rdb.HLen(ctx, redismantis.HashFilledSignals).Val(), rdb.ZCard(ctx, redismantis.ZSetFilledSignals).Val(); h != 1 || z != 1 {
+		t.Errorf("filled signals = %d, index = %d, want 1 each", h, z)
+	}
+}
+
+func TestSettlement(t *testing.T) {
+	rdb.FlushAll(ctx)
+	engine := streamer.NewEngine(ctx, rdb)
+	exec := NewExecutor(ctx, rdb, engine)
+	engine.SetSettler(exec)
+	engine.RegisterMetadata("m", []market.Token{
+		{TokenID: "Yes_S", Outcome: "Yes", Market: "M?"},
+		{TokenID: "No_S", Outcome: "No", Market: "M?"},
+	})
+	rdb.HSet(ctx, redismantis.HashPortfolioBalance, "USD", 100.00)
+	engine.Process("orderbook", []byte(`{"event_type":"book","asset_id":"Yes_S","bids":[{"price":"0.58","size":"100"}],"asks":[{"price":"0.60","size":"100"}]}`))
+	engine.Process("orderbook", []byte(`{"event_type":"book","asset_id":"No_S","bids":[{"price":"0.38","size":"100"}],"asks":[{"price":"0.40","size":"100"}]}`))
+	exec.Execute(Signal{Action: "BUY", Asset: "Yes_S", Amount: 10})
+	exec.Execute(Signal{Action: "BUY", Asset: "No_S", Amount: 5})
+
+	resolved := []byte(`{"event_type":"market_resolved","assets_ids":["Yes_S","No_S"],"winning_asset_id":"Yes_S"}`)
+	engine.Process("orderbook", resolved)
+	// A repeated event settles nothing twice.
+	engine.Process("orderbook", resolved)
+
+	bal, _ := rdb.HGetAll(ctx, redismantis.HashPortfolioBalance).Result()
+	if bal["USD"] != "102" || bal["Yes_S"] != "0" || bal["No_S"] != "0" {
+		t.Errorf("balances after settlement = %v", bal)
+	}
+	if n, _ := rdb.HLen(ctx, redismantis.HashPortfolioCost).Result(); n != 0 {
+		t.Errorf("%d cost bases left", n)
+	}
+	log, _ := rdb.XRange(ctx, redismantis.HashTradeLog, "-", "+").Result()
+	if len(log) != 4 {
+		t.Fatalf("trade log has %d entries, want 2 fills and 2 settlements", len(log))
+	}
+	if v := log[2].Values; v["action"] != "SETTLE" || v["asset_id"] != "Yes_S" || v["price"] != "1" || v["total"] != "10" || v["outcome"] != "Yes" {
+		t.Errorf("winning settlement = %v", v)
+	}
+	if v := log[3].Values; v["asset_id"] != "No_S" || v["total"] != "0" {
+		t.Errorf("losing settlement = %v", v)
+	}
+}
+
+func TestAccountOperationsAndPnL(t *testing.T) {
+	rdb.FlushAll(ctx)
+	engine := streamer.NewEngine(ctx, rdb)
+	exec := NewExecutor(ctx, rdb, engine)
+
+	if err := exec.Reset(100); err != nil {
+		t.Fatal(err)
+	}
+	if bal, _ := exec.Deposit(50); bal != 150 {
+		t.Fatalf("deposit: balance %v", bal)
+	}
+	if _, err := exec.Withdraw(1000); err != ErrInsufficientFunds {
+		t.Fatalf("expected ErrInsufficientFunds, got %v", err)
+	}
+	if bal, _ := exec.Withdraw(50); bal != 100 {
+		t.Fatalf("withdraw: balance %v", bal)
+	}
+
+	engine.Process("orderbook", []byte(`{"asset_id":"Asset_PnL","bids":[{"price":"0.40","size":"100"}],"asks":[{"price":"0.50","size":"100"}]}`))
+	exec.Execute(Signal{Action: "BUY", Asset: "Asset_PnL", Amount: 100})
+	exec.Execute(Signal{Action: "SELL", Asset: "Asset_PnL", Amount: 40})
+
+	p, err := exec.Portfolio()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Holdings) != 1 {
+		t.Fatalf("expected one holding, got %+v", p.Holdings)
+	}
+	h := p.Holdings[0]
+	// Bought 100 @ 0.50, sold 40 @ 0.40: 60 left at an average of 0.50,
+	// marked at the 0.45 mid.
+	if !approx(h.Quantity, 60) || !approx(h.AvgPrice, 0.50) || !approx(h.PnL, 60*0.45-30) {
+		t.Fatalf("unexpected holding %+v", h)
+	}
+
+	if err := exec.Reset(10); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := exec.Portfolio(); p.Cash != 10 || len(p.Holdings) != 0 {
+		t.Fatalf("reset left %+v", p)
+	}
+}
+
+func TestQueuedAndCancel(t *testing.T) {
+	rdb.FlushAll(ctx)
+	exec := NewExecutor(ctx, rdb, streamer.NewEngine(ctx, rdb))
+
+	if q, err := exec.Queued(); err != nil || len(q) != 0 {
+		t.Fatalf("empty queue: %v %v", q, err)
+	}
+
+	rdb.XGroupCreateMkStream(ctx, redismantis.StreamSignalsInbound, redismantis.GroupMantisExecutors, "$")
+	var ids []string
+	for i := 0; i < 2; i++ {
+		id, _ := rdb.XAdd(ctx, &redis.XAddArgs{
+			Stream: redismantis.StreamSignalsInbound,
+			Values: map[string]interface{}{"data": `{"action":"BUY","asset":"A","amount":1,"strategy_id":"s"}`},
+		}).Result()
+		ids = append(ids, id)
+	}
+	// The executor has taken the first one.
+	rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    redismantis.GroupMantisExecutors,
+		Consumer: redismantis.ConsumerWorker1,
+		Streams:  []string{redismantis.StreamSignalsInbound, ">"},
+		Count:    1,
+	})
+
+	q, err := exec.Queued()
+	if err != nil || len(q) != 1 || q[0].ID != ids[1] || q[0].Signal.StrategyID != "s" {
+		t.Fatalf("queued: %+v %v", q, err)
+	}
+	if err := exec.Cancel(ids[0]); err != ErrNotQueued {
+		t.Fatalf("cancelling a delivered signal: %v", err)
+	}
+	if err := exec.Cancel(ids[1]); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if q, _ := exec.Queued(); len(q) != 0 {
+		t.Fatalf("queue after cancel: %+v", q)
+	}
+}
+
+func approx(a, b float64) bool {
+	d := a - b
+	return d < 1e-9 && d > -1e-9
+}
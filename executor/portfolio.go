@@ -0,0 +1,139 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/arjunprakash027/Mantis/pkg/redismantis"
+	"github.com/arjunprakash027/Mantis/pkg/wire"
+	"github.com/redis/go-redis/v9"
+)
+
+// Portfolio and Holding are defined in wire so the client SDK can read the
+// account without importing the executor.
+type (
+	Portfolio = wire.Portfolio
+	Holding   = wire.Holding
+)
+
+var ErrInsufficientFunds = errors.New("insufficient USD funds")
+
+func (e *Executor) Portfolio() (Portfolio, error) {
+	return wire.ReadPortfolio(e.ctx, e.rdb, e.engine.GetPrice)
+}
+
+func (e *Executor) Deposit(amount float64) (float64, error) {
+	return Deposit(e.ctx, e.rdb, amount)
+}
+
+func (e *Executor) Withdraw(amount float64) (float64, error) {
+	return Withdraw(e.ctx, e.rdb, amount)
+}
+
+func (e *Executor) Reset(cash float64) error {
+	return Reset(e.ctx, e.rdb, cash)
+}
+
+// Cash movements are appended to the cash log in the same script as the
+// balance change, so a ledger tailing it never misses or double counts one.
+var depositScript = redis.NewScript(`
+local balance = redis.call('HINCRBYFLOAT', KEYS[1], 'USD', ARGV[1])
+redis.call('XADD', KEYS[2], '*', 'kind', 'deposit', 'amount', ARGV[1], 'balance_usd', balance)
+return balance
+`)
+
+// Deposit adds USD and returns the new balance.
+func Deposit(ctx context.Context, rdb redis.UniversalClient, amount float64) (float64, error) {
+	res, err := depositScript.Run(ctx, rdb, cashKeys, amount).Text()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(res, 64)
+}
+
+var withdrawScript = redis.NewScript(`
+local usd = tonumber(redis.call('HGET', KEYS[1], 'USD') or 0)
+local amount = tonumber(ARGV[1])
+if usd < amount then
+    return false
+end
+local balance = redis.call('HINCRBYFLOAT', KEYS[1], 'USD', -amount)
+redis.call('XADD', KEYS[2], '*', 'kind', 'withdrawal', 'amount', ARGV[1], 'balance_usd', balance)
+return balance
+`)
+
+// Withdraw removes USD and returns the new balance, or ErrInsufficientFunds.
+func Withdraw(ctx context.Context, rdb redis.UniversalClient, amount float64) (float64, error) {
+	res, err := withdrawScript.Run(ctx, rdb, cashKeys, amount).Text()
+	if err == redis.Nil {
+		return 0, ErrInsufficientFunds
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(res, 64)
+}
+
+var cashKeys = []string{redismantis.HashPortfolioBalance, redismantis.StreamCashLog}
+
+// settleScript closes a position at the payout price and logs it on the trade
+// log as a SETTLE entry. An empty position is left alone, so a repeated
+// resolution settles once.
+var settleScript = redis.NewScript(`
+local asset = ARGV[1]
+local amount = tonumber(redis.call('HGET', KEYS[1], asset) or 0)
+if amount <= 0 then
+    return 0
+end
+local total = amount * tonumber(ARGV[2])
+local final_asset = redis.call('HINCRBYFLOAT', KEYS[1], asset, -amount)
+local final_usd = redis.call('HINCRBYFLOAT', KEYS[1], 'USD', total)
+redis.call('HDEL', KEYS[3], asset)
+redis.call('XADD', KEYS[2], '*',
+    'action', 'SETTLE', 'asset_id', asset, 'market', ARGV[5], 'outcome', ARGV[4],
+    'amount', amount, 'price', ARGV[2], 'total', total,
+    'balance_usd', final_usd, 'balance_asset', final_asset,
+    'strategy', '', 'timestamp', ARGV[3]
+)
+return 1
+`)
+
+// Settle implements streamer.Settler: every position in a resolved market is
+// closed, shares of winner paying $1 and the rest nothing.
+func (e *Executor) Settle(assets []string, winner string) {
+	now := e.engine.Clock().Now().Unix()
+	for _, asset := range assets {
+		price := 0.0
+		if asset == winner {
+			price = 1
+		}
+		meta, hasMeta := e.engine.GetMetadata(asset)
+		outcome, marketName := e.describe(asset, meta, hasMeta)
+		n, err := settleScript.Run(e.ctx, e.rdb,
+			[]string{redismantis.HashPortfolioBalance, redismantis.HashTradeLog, redismantis.HashPortfolioCost},
+			asset, price, now, outcome, marketName,
+		).Int()
+		if err != nil {
+			e.logger.Error("settle script failed", "asset_id", asset, "err", err)
+			continue
+		}
+		if n == 1 {
+			e.logger.Info("position settled", "asset_id", asset, "price", price)
+		}
+	}
+}
+
+// Reset drops every position and sets the USD balance to cash. The trade log
+// is kept.
+func Reset(ctx context.Context, rdb redis.UniversalClient, cash float64) error {
+	pipe := rdb.TxPipeline()
+	pipe.Del(ctx, redismantis.HashPortfolioBalance, redismantis.HashPortfolioCost)
+	pipe.HSet(ctx, redismantis.HashPortfolioBalance, "USD", cash)
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: redismantis.StreamCashLog,
+		Values: map[string]interface{}{"kind": "reset", "amount": cash, "balance_usd": cash},
+	})
+	_, err := pipe.Exec(ctx)
+	return err
+}
@@ -0,0 +1,99 @@
+package executor
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+
+	"github.com/arjunprakash027/Mantis/pkg/redismantis"
+	"github.com/redis/go-redis/v9"
+)
+
+// QueuedSignal is a signal on signals:inbound that the executor has not read
+// yet. Fills are immediate, so these are the only open orders.
+type QueuedSignal struct {
+	ID     string `json:"id"`
+	Signal Signal `json:"signal"`
+}
+
+var ErrNotQueued = errors.New("signal is not queued")
+
+// Queued lists signals waiting for the executor, oldest first.
+func (e *Executor) Queued() ([]QueuedSignal, error) {
+	start := "-"
+	groups, err := e.rdb.XInfoGroups(e.ctx, redismantis.StreamSignalsInbound).Result()
+	if err != nil && err != redis.Nil && !isNoSuchKey(err) {
+		return nil, err
+	}
+	for _, g := range groups {
+		if g.Name == redismantis.GroupMantisExecutors && g.LastDeliveredID != "0-0" {
+			start = "(" + g.LastDeliveredID
+		}
+	}
+
+	msgs, err := e.rdb.XRange(e.ctx, redismantis.StreamSignalsInbound, start, "+").Result()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]QueuedSignal, 0, len(msgs))
+	for _, m := range msgs {
+		q := QueuedSignal{ID: m.ID}
+		if data, ok := m.Values["data"].(string); ok {
+			json.Unmarshal([]byte(data), &q.Signal)
+		}
+		out = append(out, q)
+	}
+	return out, nil
+}
+
+// cancelScript deletes a signal only if the consumer group has not read it
+// yet, so a cancel never races an execution.
+var cancelScript = redis.NewScript(`
+local function after(a, b)
+    local am, as = string.match(a, '(%d+)-(%d+)')
+    local bm, bs = string.match(b, '(%d+)-(%d+)')
+    am, as, bm, bs = tonumber(am), tonumber(as), tonumber(bm), tonumber(bs)
+    return am > bm or (am == bm and as > bs)
+end
+
+if redis.call('EXISTS', KEYS[1]) == 0 then
+    return 0
+end
+local last = '0-0'
+for _, g in ipairs(redis.call('XINFO', 'GROUPS', KEYS[1])) do
+    for i = 1, #g, 2 do
+        if g[i] == 'name' and g[i + 1] ~= ARGV[2] then
+            break
+        end
+        if g[i] == 'last-delivered-id' then
+            last = g[i + 1]
+        end
+    end
+end
+if not after(ARGV[1], last) then
+    return 0
+end
+return redis.call('XDEL', KEYS[1], ARGV[1])
+`)
+
+// Cancel removes a queued signal. It returns ErrNotQueued if the signal does
+// not exist or the executor already picked it up.
+func (e *Executor) Cancel(id string) error {
+	if !streamID.MatchString(id) {
+		return ErrNotQueued
+	}
+	n, err := cancelScript.Run(e.ctx, e.rdb, []string{redismantis.StreamSignalsInbound}, id, redismantis.GroupMantisExecutors).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotQueued
+	}
+	return nil
+}
+
+var streamID = regexp.MustCompile(`^\d+-\d+$`)
+
+func isNoSuchKey(err error) bool {
+	return err != nil && err.Error() == "ERR no such key"
+}
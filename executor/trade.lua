@@ -1,5 +1,6 @@
 local portfolio_key = KEYS[1]
 local trade_log_key = KEYS[2]
+local cost_key = KEYS[3]
 
 local action = ARGV[1]
 local asset = ARGV[2]
@@ -8,10 +9,35 @@ local price = tonumber(ARGV[4])
 local total_cost = tonumber(ARGV[5]) 
 local timestamp = ARGV[6]
 local strategy_id = ARGV[7]
+local outcome = ARGV[8]
+local market = ARGV[9]
+local signal_key = ARGV[10]
+local filled_key = KEYS[4]
+local filled_index_key = KEYS[5]
 
-local meta_key = "token:meta:" .. asset
-local outcome = redis.call('HGET', meta_key, 'outcome') or 'unknown'
-local market = redis.call('HGET', meta_key, 'market') or 'unknown'
+-- A redelivered signal that already filled returns its original fill instead
+-- of trading twice. Entries are kept for a day of engine time.
+if signal_key ~= "" then
+    local prev = redis.call('HGET', filled_key, signal_key)
+    if prev then
+        return {2, prev}
+    end
+    -- Expired keys are deleted in chunks: unpack fails on a few thousand
+    -- elements, and a backtest can expire a whole day of signals at once.
+    local cutoff = tonumber(timestamp) - 86400
+    local offset = 0
+    while true do
+        local expired = redis.call('ZRANGEBYSCORE', filled_index_key, '-inf', cutoff, 'LIMIT', offset, 500)
+        if #expired == 0 then
+            break
+        end
+        redis.call('HDEL', filled_key, unpack(expired))
+        offset = offset + #expired
+    end
+    if offset > 0 then
+        redis.call('ZREMRANGEBYSCORE', filled_index_key, '-inf', cutoff)
+    end
+end
 
 if action == "BUY" then
     local usd_balance = tonumber(redis.call('HGET', portfolio_key, 'USD') or 0)
@@ -20,12 +46,20 @@ if action == "BUY" then
     end
     redis.call('HINCRBYFLOAT', portfolio_key, 'USD', -total_cost)
     redis.call('HINCRBYFLOAT', portfolio_key, asset, amount)
+    redis.call('HINCRBYFLOAT', cost_key, asset, total_cost)
     
 elseif action == "SELL" then
     local asset_balance = tonumber(redis.call('HGET', portfolio_key, asset) or 0)
     if asset_balance < amount then
         return {0, "Insufficient asset balance"}
     end
+    -- Cost basis shrinks pro rata, so the average entry price is unchanged.
+    local cost = tonumber(redis.call('HGET', cost_key, asset) or 0)
+    if asset_balance - amount <= 0 then
+        redis.call('HDEL', cost_key, asset)
+    else
+        redis.call('HINCRBYFLOAT', cost_key, asset, -cost * amount / asset_balance)
+    end
     redis.call('HINCRBYFLOAT', portfolio_key, asset, -amount)
     redis.call('HINCRBYFLOAT', portfolio_key, 'USD', total_cost)
 end
@@ -40,4 +74,9 @@ redis.call('XADD', trade_log_key, '*',
     'strategy', strategy_id, 'timestamp', timestamp
 )
 
+if signal_key ~= "" then
+    redis.call('HSET', filled_key, signal_key, price .. " " .. timestamp)
+    redis.call('ZADD', filled_index_key, timestamp, signal_key)
+end
+
 return {1, "Success"}
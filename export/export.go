@@ -0,0 +1,239 @@
+// Package export writes order book data and executor fills to partitioned
+// Parquet or CSV files, so research can work with more history than the
+// capped Redis streams hold, in pandas, DuckDB or Spark.
+package export
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/arjunprakash027/Mantis/pkg/wire"
+	"github.com/arjunprakash027/Mantis/streamer"
+)
+
+// Table names, which are also the top-level directories of an export.
+const (
+	TableTopOfBook = "top_of_book"
+	TableDepth     = "depth"
+	TableTrades    = "trades"
+	TableFills     = "fills"
+)
+
+var Tables = []string{TableTopOfBook, TableDepth, TableTrades, TableFills}
+
+// Options configures an Exporter. Run names the part files of this export
+// (default: the start time), so repeated exports into the same Dir add files
+// instead of replacing them.
+type Options struct {
+	Dir    string
+	Format Format
+	Tables []string
+	Run    string
+}
+
+// Exporter turns CLOB events and trade log entries into rows. Market data
+// must be fed in receive order per asset for top of book to be correct.
+type Exporter struct {
+	top    *table[TopOfBook]
+	depth  *table[DepthLevel]
+	trades *table[Trade]
+	fills  *table[Fill]
+
+	books map[string]*wire.L2Book
+	tops  map[string]TopOfBook
+}
+
+func New(opts Options) (*Exporter, error) {
+	if opts.Format != Parquet && opts.Format != CSV {
+		return nil, fmt.Errorf("export: format must be parquet or csv, got %q", opts.Format)
+	}
+	if opts.Dir == "" {
+		return nil, errors.New("export: Dir is required")
+	}
+	if len(opts.Tables) == 0 {
+		opts.Tables = Tables
+	}
+	if opts.Run == "" {
+		opts.Run = time.Now().UTC().Format("20060102T150405Z")
+	}
+
+	x := &Exporter{books: make(map[string]*wire.L2Book), tops: make(map[string]TopOfBook)}
+	for _, name := range opts.Tables {
+		switch name {
+		case TableTopOfBook:
+			x.top = newTable[TopOfBook](opts.Dir, name, opts.Run, opts.Format, true)
+		case TableDepth:
+			x.depth = newTable[DepthLevel](opts.Dir, name, opts.Run, opts.Format, true)
+		case TableTrades:
+			x.trades = newTable[Trade](opts.Dir, name, opts.Run, opts.Format, true)
+		case TableFills:
+			x.fills = newTable[Fill](opts.Dir, name, opts.Run, opts.Format, false)
+		default:
+			return nil, fmt.Errorf("export: unknown table %q (want one of %v)", name, Tables)
+		}
+	}
+	return x, nil
+}
+
+// Wants reports whether the table is part of this export.
+func (x *Exporter) Wants(name string) bool {
+	switch name {
+	case TableTopOfBook:
+		return x.top != nil
+	case TableDepth:
+		return x.depth != nil
+	case TableTrades:
+		return x.trades != nil
+	case TableFills:
+		return x.fills != nil
+	}
+	return false
+}
+
+// Frame exports a CLOB frame (one event or a batch) received at at. A
+// non-empty asset skips events for other assets.
+func (x *Exporter) Frame(at time.Time, data []byte, asset string) error {
+	updates, err := wire.DecodeUpdates(data)
+	if err != nil {
+		// Keepalive PONGs and other non-JSON frames carry no market data.
+		return nil
+	}
+	for _, u := range updates {
+		if u.AssetID == "" || (asset != "" && u.AssetID != asset) {
+			continue
+		}
+		if err := x.event(at, u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Warm folds a frame from before the export window into the books without
+// writing rows, so top of book is right from the window's first event.
+func (x *Exporter) Warm(data []byte, asset string) {
+	updates, err := wire.DecodeUpdates(data)
+	if err != nil {
+		return
+	}
+	for _, u := range updates {
+		if u.AssetID == "" || (asset != "" && u.AssetID != asset) ||
+			(u.EventType != "book" && len(u.Bids) == 0 && len(u.Asks) == 0) {
+			continue
+		}
+		x.book(u.AssetID).Apply(u)
+	}
+}
+
+func (x *Exporter) book(asset string) *wire.L2Book {
+	b := x.books[asset]
+	if b == nil {
+		b = wire.NewL2Book()
+		x.books[asset] = b
+	}
+	return b
+}
+
+func (x *Exporter) event(at time.Time, u streamer.OrderbookUpdate) error {
+	if u.EventType == "last_trade_price" {
+		if x.trades == nil {
+			return nil
+		}
+		return x.trades.write(at, u.AssetID, Trade{
+			TS: at, AssetID: u.AssetID, Price: parse(u.Price), Size: parse(u.Size), Side: u.Side,
+		})
+	}
+
+	if u.EventType == "book" && x.depth != nil {
+		// The CLOB sends bids ascending and asks descending, best last.
+		sides := []struct {
+			name   string
+			levels []streamer.PriceLevel
+		}{{"bid", u.Bids}, {"ask", u.Asks}}
+		for _, side := range sides {
+			for i, l := range slices.Backward(side.levels) {
+				err := x.depth.write(at, u.AssetID, DepthLevel{
+					TS: at, AssetID: u.AssetID, Side: side.name, Level: int32(len(side.levels) - 1 - i),
+					Price: parse(l.Price), Size: parse(l.Size),
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if u.EventType != "book" && len(u.Bids) == 0 && len(u.Asks) == 0 {
+		return nil
+	}
+	// Top of book comes from the full book, as in the engine.
+	b := x.book(u.AssetID)
+	b.Apply(u)
+	prev := x.tops[u.AssetID]
+	next := TopOfBook{TS: at, AssetID: u.AssetID}
+	next.BestBid, next.BidSize, next.BestAsk, next.AskSize = b.Best()
+	x.tops[u.AssetID] = next
+	if x.top == nil || (next.BestBid == prev.BestBid && next.BidSize == prev.BidSize &&
+		next.BestAsk == prev.BestAsk && next.AskSize == prev.AskSize) {
+		return nil
+	}
+	return x.top.write(at, u.AssetID, next)
+}
+
+// Fill exports one trade log entry. at is used when the entry has no
+// timestamp.
+func (x *Exporter) Fill(at time.Time, v map[string]interface{}) error {
+	if x.fills == nil {
+		return nil
+	}
+	f := func(k string) float64 {
+		s, _ := v[k].(string)
+		return parse(s)
+	}
+	s := func(k string) string {
+		s, _ := v[k].(string)
+		return s
+	}
+	// The stream ID is when the entry was written; timestamp is the
+	// executor's clock, which differs in backtests.
+	if ts, err := strconv.ParseInt(s("timestamp"), 10, 64); err == nil {
+		at = time.Unix(ts, 0)
+	}
+	return x.fills.write(at, "", Fill{
+		TS: at, AssetID: s("asset_id"), Action: s("action"), Amount: f("amount"), Price: f("price"),
+		Total: f("total"), BalanceUSD: f("balance_usd"), BalanceAsset: f("balance_asset"),
+		Outcome: s("outcome"), Market: s("market"), StrategyID: s("strategy"),
+	})
+}
+
+// Close finishes every file and returns the rows written per table.
+func (x *Exporter) Close() (map[string]int, error) {
+	counts := make(map[string]int)
+	var errs []error
+	closeTable := func(name string, c interface{ close() (int, error) }) {
+		n, err := c.close()
+		counts[name] = n
+		errs = append(errs, err)
+	}
+	if x.top != nil {
+		closeTable(TableTopOfBook, x.top)
+	}
+	if x.depth != nil {
+		closeTable(TableDepth, x.depth)
+	}
+	if x.trades != nil {
+		closeTable(TableTrades, x.trades)
+	}
+	if x.fills != nil {
+		closeTable(TableFills, x.fills)
+	}
+	return counts, errors.Join(errs...)
+}
+
+func parse(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
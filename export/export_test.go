@@ -0,0 +1,176 @@
+package export
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/arjunprakash027/Mantis/pkg/redismantis"
+	"github.com/parquet-go/parquet-go"
+	"github.com/redis/go-redis/v9"
+)
+
+func seed(t *testing.T) *redis.Client {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	ctx := context.Background()
+	add := func(stream, id string, values ...interface{}) {
+		if err := rdb.XAdd(ctx, &redis.XAddArgs{Stream: stream, ID: id, Values: values}).Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 2025-01-01T00:00:00Z, then one second later.
+	book := redismantis.StreamOrderbook("A")
+	add(book, "1735689600000-0", "data", `{"event_type":"book","asset_id":"A","bids":[{"price":"0.40","size":"10"},{"price":"0.45","size":"5"}],"asks":[{"price":"0.55","size":"7"}]}`)
+	add(book, "1735689601000-0", "data", `{"event_type":"price_change","asset_id":"A","bids":[{"price":"0.47","size":"2"}]}`)
+	add(book, "1735689601000-1", "data", `{"event_type":"last_trade_price","asset_id":"A","price":"0.55","size":"3","side":"BUY"}`)
+	// A legacy batched entry: only its own asset's events belong to this stream.
+	add(redismantis.StreamOrderbook("B"), "1735776000000-0", "data",
+		`[{"event_type":"price_change","asset_id":"A","bids":[{"price":"0.10","size":"1"}]},{"event_type":"price_change","asset_id":"B","asks":[{"price":"0.30","size":"4"}]}]`)
+	add(redismantis.HashTradeLog, "1735689602000-0", "action", "BUY", "asset_id", "A", "market", "M", "outcome", "Yes",
+		"amount", "3", "price", "0.55", "total", "1.65", "balance_usd", "98.35", "balance_asset", "3", "strategy", "bot", "timestamp", "1735689700")
+	return rdb
+}
+
+func export(t *testing.T, rdb *redis.Client, format Format) string {
+	dir := t.TempDir()
+	x, err := New(Options{Dir: dir, Format: format, Run: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := Streams(ctx, rdb, x, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := TradeLog(ctx, rdb, x, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	counts, err := x.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{TableTopOfBook: 3, TableDepth: 3, TableTrades: 1, TableFills: 1}
+	for name, n := range want {
+		if counts[name] != n {
+			t.Errorf("%s: %d rows, want %d", name, counts[name], n)
+		}
+	}
+	return dir
+}
+
+func TestExportParquet(t *testing.T) {
+	dir := export(t, seed(t), Parquet)
+
+	top, err := parquet.ReadFile[TopOfBook](filepath.Join(dir, "top_of_book/date=2025-01-01/asset=A/part-test.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 2 || top[1].BestBid != 0.47 || top[1].BidSize != 2 || top[1].BestAsk != 0.55 {
+		t.Errorf("top of book = %+v", top)
+	}
+	if !top[1].TS.Equal(time.UnixMilli(1735689601000)) {
+		t.Errorf("ts = %v", top[1].TS)
+	}
+
+	depth, err := parquet.ReadFile[DepthLevel](filepath.Join(dir, "depth/date=2025-01-01/asset=A/part-test.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(depth) != 3 || depth[0] != (DepthLevel{TS: depth[0].TS, AssetID: "A", Side: "bid", Level: 0, Price: 0.45, Size: 5}) || depth[1].Level != 1 {
+		t.Errorf("depth = %+v", depth)
+	}
+
+	fills, err := parquet.ReadFile[Fill](filepath.Join(dir, "fills/date=2025-01-01/part-test.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fills) != 1 || fills[0].StrategyID != "bot" || fills[0].BalanceUSD != 98.35 {
+		t.Errorf("fills = %+v", fills)
+	}
+	if len(fills) == 1 && !fills[0].TS.Equal(time.Unix(1735689700, 0)) {
+		t.Errorf("fill ts = %v, want the trade log's timestamp", fills[0].TS)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "top_of_book/date=2025-01-02/asset=A")); err == nil {
+		t.Error("an A event in B's stream was exported")
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	dir := export(t, seed(t), CSV)
+
+	data, err := os.ReadFile(filepath.Join(dir, "trades/date=2025-01-01/asset=A/part-test.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "ts,asset_id,price,size,side\n2025-01-01T00:00:01.000000Z,A,0.55,3,BUY\n"
+	if string(data) != want {
+		t.Errorf("trades.csv = %q, want %q", data, want)
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, "top_of_book/date=2025-01-02/asset=B/part-test.csv"))
+	if !strings.HasSuffix(string(data), ",B,0,0,0.3,4\n") {
+		t.Errorf("B top of book = %q", data)
+	}
+	if tmp, _ := filepath.Glob(filepath.Join(dir, "*/*/*/*.tmp")); len(tmp) > 0 {
+		t.Errorf("temporary files left behind: %v", tmp)
+	}
+}
+
+func TestExportSinceStartsFromSnapshot(t *testing.T) {
+	rdb := seed(t)
+	dir := t.TempDir()
+	x, err := New(Options{Dir: dir, Format: CSV, Run: "test", Tables: []string{TableTopOfBook}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The window opens on the price change; the snapshot before it still
+	// seeds the book.
+	if err := Streams(context.Background(), rdb, x, time.UnixMilli(1735689601000)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := x.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "top_of_book/date=2025-01-01/asset=A/part-test.csv"))
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 2 || !strings.HasSuffix(lines[1], ",A,0.47,2,0.55,7") {
+		t.Errorf("top_of_book.csv = %q", data)
+	}
+}
+
+func TestPartitionsClosedWhenCapReached(t *testing.T) {
+	prev := maxOpenParts
+	maxOpenParts = 1
+	t.Cleanup(func() { maxOpenParts = prev })
+
+	dir := t.TempDir()
+	tbl := newTable[Trade](dir, TableTrades, "test", CSV, false)
+	day1 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	for _, at := range []time.Time{day1, day2, day1} {
+		if err := tbl.write(at, "", Trade{TS: at, AssetID: "A"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(tbl.parts) != 1 {
+		t.Errorf("%d partitions open", len(tbl.parts))
+	}
+	n, err := tbl.close()
+	if err != nil || n != 3 {
+		t.Fatalf("close = %d, %v", n, err)
+	}
+	// Reopening a closed partition starts a new file instead of replacing it.
+	files, _ := filepath.Glob(filepath.Join(dir, "trades/date=2025-01-01/*.csv"))
+	if len(files) != 2 {
+		t.Errorf("day one files = %v", files)
+	}
+}
+
+func TestUnknownTable(t *testing.T) {
+	if _, err := New(Options{Dir: t.TempDir(), Format: Parquet, Tables: []string{"quotes"}}); err == nil {
+		t.Error("expected unknown table error")
+	}
+}
@@ -0,0 +1,98 @@
+package export
+
+import (
+	"strconv"
+	"time"
+)
+
+// The row types below are the exported schema. Column names and types are
+// stable; new columns are only ever appended. Times are UTC with microsecond
+// precision: the receive time for market data, and for fills the executor's
+// fill time from the trade log's timestamp field (whole seconds).
+
+// TopOfBook is written whenever an event changes an asset's best bid or ask,
+// tracked on a full book rebuilt from snapshots and price changes.
+type TopOfBook struct {
+	TS      time.Time `parquet:"ts,timestamp(microsecond)"`
+	AssetID string    `parquet:"asset_id,dict"`
+	BestBid float64   `parquet:"best_bid"`
+	BidSize float64   `parquet:"bid_size"`
+	BestAsk float64   `parquet:"best_ask"`
+	AskSize float64   `parquet:"ask_size"`
+}
+
+// DepthLevel is one price level of a full book snapshot. Level 0 is the best
+// price on its side.
+type DepthLevel struct {
+	TS      time.Time `parquet:"ts,timestamp(microsecond)"`
+	AssetID string    `parquet:"asset_id,dict"`
+	Side    string    `parquet:"side,dict"`
+	Level   int32     `parquet:"level"`
+	Price   float64   `parquet:"price"`
+	Size    float64   `parquet:"size"`
+}
+
+// Trade is a last_trade_price event from the exchange.
+type Trade struct {
+	TS      time.Time `parquet:"ts,timestamp(microsecond)"`
+	AssetID string    `parquet:"asset_id,dict"`
+	Price   float64   `parquet:"price"`
+	Size    float64   `parquet:"size"`
+	Side    string    `parquet:"side,dict"`
+}
+
+// Fill is a paper trade from the executor's trade log.
+type Fill struct {
+	TS           time.Time `parquet:"ts,timestamp(microsecond)"`
+	AssetID      string    `parquet:"asset_id,dict"`
+	Action       string    `parquet:"action,dict"`
+	Amount       float64   `parquet:"amount"`
+	Price        float64   `parquet:"price"`
+	Total        float64   `parquet:"total"`
+	BalanceUSD   float64   `parquet:"balance_usd"`
+	BalanceAsset float64   `parquet:"balance_asset"`
+	Outcome      string    `parquet:"outcome,dict"`
+	Market       string    `parquet:"market,dict"`
+	StrategyID   string    `parquet:"strategy_id,dict"`
+}
+
+func (TopOfBook) header() []string {
+	return []string{"ts", "asset_id", "best_bid", "bid_size", "best_ask", "ask_size"}
+}
+
+func (r TopOfBook) record() []string {
+	return []string{ts(r.TS), r.AssetID, num(r.BestBid), num(r.BidSize), num(r.BestAsk), num(r.AskSize)}
+}
+
+func (DepthLevel) header() []string {
+	return []string{"ts", "asset_id", "side", "level", "price", "size"}
+}
+
+func (r DepthLevel) record() []string {
+	return []string{ts(r.TS), r.AssetID, r.Side, strconv.Itoa(int(r.Level)), num(r.Price), num(r.Size)}
+}
+
+func (Trade) header() []string {
+	return []string{"ts", "asset_id", "price", "size", "side"}
+}
+
+func (r Trade) record() []string {
+	return []string{ts(r.TS), r.AssetID, num(r.Price), num(r.Size), r.Side}
+}
+
+func (Fill) header() []string {
+	return []string{"ts", "asset_id", "action", "amount", "price", "total", "balance_usd", "balance_asset", "outcome", "market", "strategy_id"}
+}
+
+func (r Fill) record() []string {
+	return []string{ts(r.TS), r.AssetID, r.Action, num(r.Amount), num(r.Price), num(r.Total),
+		num(r.BalanceUSD), num(r.BalanceAsset), r.Outcome, r.Market, r.StrategyID}
+}
+
+func ts(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000000Z")
+}
+
+func num(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
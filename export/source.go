@@ -0,0 +1,129 @@
+package export
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arjunprakash027/Mantis/pkg/redismantis"
+	"github.com/arjunprakash027/Mantis/pkg/wire"
+	"github.com/arjunprakash027/Mantis/recorder"
+	"github.com/redis/go-redis/v9"
+)
+
+const pageSize = 1000
+
+// Streams exports the orderbook streams in Redis, one asset at a time, from
+// since (zero for everything still retained).
+func Streams(ctx context.Context, rdb redis.UniversalClient, x *Exporter, since time.Time) error {
+	prefix := redismantis.StreamOrderbook("")
+	var assets []string
+	iter := rdb.Scan(ctx, 0, prefix+"*", 200).Iterator()
+	for iter.Next(ctx) {
+		assets = append(assets, strings.TrimPrefix(iter.Val(), prefix))
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	sort.Strings(assets)
+
+	for _, asset := range assets {
+		stream := redismantis.StreamOrderbook(asset)
+		start, err := snapshotBefore(ctx, rdb, stream, asset, since)
+		if err != nil {
+			return err
+		}
+		err = xrange(ctx, rdb, stream, start, func(m redis.XMessage) error {
+			data, _ := m.Values["data"].(string)
+			at := redismantis.StreamTime(m.ID)
+			if at.Before(since) {
+				x.Warm([]byte(data), asset)
+				return nil
+			}
+			return x.Frame(at, []byte(data), asset)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshotBefore returns the ID of the newest entry before since that holds a
+// "book" snapshot of asset, so the export starts with a full book. Without
+// one, the whole retained stream is replayed.
+func snapshotBefore(ctx context.Context, rdb redis.UniversalClient, stream, asset string, since time.Time) (string, error) {
+	if since.IsZero() {
+		return "-", nil
+	}
+	end := "(" + strconv.FormatInt(since.UnixMilli(), 10) + "-0"
+	for {
+		msgs, err := rdb.XRevRangeN(ctx, stream, end, "-", pageSize).Result()
+		if err != nil {
+			return "", err
+		}
+		for _, m := range msgs {
+			data, _ := m.Values["data"].(string)
+			updates, _ := wire.DecodeUpdates([]byte(data))
+			for _, u := range updates {
+				if u.AssetID == asset && u.EventType == "book" {
+					return m.ID, nil
+				}
+			}
+		}
+		if len(msgs) < pageSize {
+			return "-", nil
+		}
+		end = "(" + msgs[len(msgs)-1].ID
+	}
+}
+
+// TradeLog exports the executor's fills from since.
+func TradeLog(ctx context.Context, rdb redis.UniversalClient, x *Exporter, since time.Time) error {
+	start := "-"
+	if !since.IsZero() {
+		start = strconv.FormatInt(since.UnixMilli(), 10)
+	}
+	return xrange(ctx, rdb, redismantis.HashTradeLog, start, func(m redis.XMessage) error {
+		return x.Fill(redismantis.StreamTime(m.ID), m.Values)
+	})
+}
+
+// Capture exports the orderbook frames of recorder capture files, which hold
+// the full history rather than what the streams still retain. Frames before
+// since only warm the books.
+func Capture(ctx context.Context, files []string, x *Exporter, since time.Time) error {
+	p := &recorder.Player{Files: files}
+	return p.Play(ctx, func(rec recorder.Record) error {
+		if rec.Namespace != "orderbook" {
+			return nil
+		}
+		at := time.Unix(0, rec.ReceivedAt)
+		if at.Before(since) {
+			x.Warm([]byte(rec.Data), "")
+			return nil
+		}
+		return x.Frame(at, []byte(rec.Data), "")
+	})
+}
+
+// xrange pages through a stream in ID order from start.
+func xrange(ctx context.Context, rdb redis.UniversalClient, stream, start string, fn func(redis.XMessage) error) error {
+	for {
+		msgs, err := rdb.XRangeN(ctx, stream, start, "+", pageSize).Result()
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			if err := fn(m); err != nil {
+				return err
+			}
+		}
+		if len(msgs) < pageSize {
+			return nil
+		}
+		start = "(" + msgs[len(msgs)-1].ID
+	}
+}
@@ -0,0 +1,164 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+type Format string
+
+const (
+	Parquet Format = "parquet"
+	CSV     Format = "csv"
+)
+
+type row interface {
+	header() []string
+	record() []string
+}
+
+// maxOpenParts caps the partitions a table keeps open. An export of every
+// asset over many days would otherwise hold a file per asset and day until
+// Close.
+var maxOpenParts = 32
+
+// table writes the rows of one type to Hive-style partitions,
+// <dir>/<name>/date=<day>/[asset=<id>/]part-<run>[-<n>].<ext>. Files are
+// written under a temporary name and renamed on close, so readers globbing the
+// directory never see a partial file. When more than maxOpenParts are open,
+// the least recently written one is closed; a later row for it starts the
+// next numbered file.
+type table[T row] struct {
+	dir     string
+	name    string
+	run     string
+	format  Format
+	byAsset bool
+	parts   map[string]*part[T]
+	opened  map[string]int
+	rows    int
+	clock   int
+}
+
+type part[T row] struct {
+	path string
+	f    *os.File
+	bw   *bufio.Writer
+	pq   *parquet.GenericWriter[T]
+	csv  *csv.Writer
+	buf  []T
+	used int
+}
+
+func newTable[T row](dir, name, run string, format Format, byAsset bool) *table[T] {
+	return &table[T]{dir: dir, name: name, run: run, format: format, byAsset: byAsset,
+		parts: make(map[string]*part[T]), opened: make(map[string]int)}
+}
+
+func (t *table[T]) write(at time.Time, asset string, r T) error {
+	rel := filepath.Join(t.name, "date="+at.UTC().Format(time.DateOnly))
+	if t.byAsset {
+		rel = filepath.Join(rel, "asset="+asset)
+	}
+	p, ok := t.parts[rel]
+	if !ok {
+		if len(t.parts) >= maxOpenParts {
+			if err := t.evict(); err != nil {
+				return err
+			}
+		}
+		var err error
+		if p, err = t.open(filepath.Join(t.dir, rel), t.opened[rel]); err != nil {
+			return err
+		}
+		t.parts[rel] = p
+		t.opened[rel]++
+	}
+	t.clock++
+	p.used = t.clock
+	t.rows++
+	if p.csv != nil {
+		return p.csv.Write(r.record())
+	}
+	p.buf = append(p.buf, r)
+	if len(p.buf) == cap(p.buf) {
+		return p.flush()
+	}
+	return nil
+}
+
+// evict closes the least recently written partition.
+func (t *table[T]) evict() error {
+	var oldest string
+	for rel, p := range t.parts {
+		if oldest == "" || p.used < t.parts[oldest].used {
+			oldest = rel
+		}
+	}
+	p := t.parts[oldest]
+	delete(t.parts, oldest)
+	return p.close()
+}
+
+func (t *table[T]) open(dir string, seq int) (*part[T], error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	name := "part-" + t.run
+	if seq > 0 {
+		name += fmt.Sprintf("-%d", seq)
+	}
+	path := filepath.Join(dir, name+"."+string(t.format))
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return nil, err
+	}
+	p := &part[T]{path: path, f: f, bw: bufio.NewWriter(f)}
+	if t.format == CSV {
+		p.csv = csv.NewWriter(p.bw)
+		var zero T
+		return p, p.csv.Write(zero.header())
+	}
+	p.pq = parquet.NewGenericWriter[T](p.bw, parquet.Compression(&parquet.Snappy))
+	p.buf = make([]T, 0, 1024)
+	return p, nil
+}
+
+func (p *part[T]) flush() error {
+	_, err := p.pq.Write(p.buf)
+	p.buf = p.buf[:0]
+	return err
+}
+
+func (p *part[T]) close() error {
+	var err error
+	if p.csv != nil {
+		p.csv.Flush()
+		err = p.csv.Error()
+	} else {
+		err = errors.Join(p.flush(), p.pq.Close())
+	}
+	err = errors.Join(err, p.bw.Flush(), p.f.Close())
+	if err != nil {
+		os.Remove(p.f.Name())
+		return err
+	}
+	return os.Rename(p.f.Name(), p.path)
+}
+
+// close finishes every partition and returns the number of rows written.
+func (t *table[T]) close() (int, error) {
+	var errs []error
+	for _, p := range t.parts {
+		errs = append(errs, p.close())
+	}
+	t.parts = nil
+	return t.rows, errors.Join(errs...)
+}
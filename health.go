@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/arjunprakash027/Mantis/executor"
+	"github.com/arjunprakash027/Mantis/streamer"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// staleAfter matches the executor's stale-price guard.
+	staleAfter = 60 * time.Second
+	// executorStallAfter allows a few missed polls before the loop counts as
+	// stuck.
+	executorStallAfter = 30 * time.Second
+)
+
+// health serves /healthz (the process is up) and /readyz (Redis, the
+// executor loop and every subscribed feed are usable).
+type health struct {
+	rdb    redis.UniversalClient
+	engine *streamer.Engine
+	exec   *executor.Executor
+	subs   *subscriptions
+}
+
+type redisHealth struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type feedHealth struct {
+	Slug       string     `json:"slug"`
+	Connected  bool       `json:"connected"`
+	Since      time.Time  `json:"since"`
+	LastError  string     `json:"last_error,omitempty"`
+	LastUpdate *time.Time `json:"last_update"`
+	AgeSeconds float64    `json:"age_seconds"`
+	Stale      bool       `json:"stale"`
+}
+
+type readiness struct {
+	Ready        bool                `json:"ready"`
+	Redis        redisHealth         `json:"redis"`
+	Executor     executor.LoopStatus `json:"executor"`
+	Feeds        []feedHealth        `json:"feeds"`
+	Stale        []string            `json:"stale"`
+	Disconnected []string            `json:"disconnected"`
+}
+
+func (h *health) register(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", h.handleHealthz)
+	mux.HandleFunc("/readyz", h.handleReadyz)
+}
+
+func (h *health) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (h *health) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	rep := h.check(r.Context())
+	code := http.StatusOK
+	if !rep.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, rep)
+}
+
+// check builds the readiness report. Stale feeds are listed but do not fail
+// readiness on their own, since quiet markets can go minutes without a book
+// change; a dropped connection does.
+func (h *health) check(ctx context.Context) readiness {
+	rep := readiness{Stale: []string{}, Disconnected: []string{}, Feeds: []feedHealth{}}
+
+	pingCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if err := h.rdb.Ping(pingCtx).Err(); err != nil {
+		rep.Redis.Error = err.Error()
+	} else {
+		rep.Redis.OK = true
+	}
+
+	rep.Executor = h.exec.Status()
+	execOK := rep.Executor.Running && time.Since(rep.Executor.LastPoll) < executorStallAfter
+
+	now := h.engine.Clock().Now()
+	for _, f := range h.subs.Feeds() {
+		connected, since, lastErr := f.status.Connected()
+		fh := feedHealth{Slug: f.slug, Connected: connected, Since: since, LastError: lastErr}
+
+		var last time.Time
+		for _, t := range f.tokens {
+			if state, ok := h.engine.GetPrice(t.TokenID); ok {
+				if at := time.Unix(state.LastUpdated, 0); at.After(last) {
+					last = at
+				}
+			}
+		}
+		if last.IsZero() {
+			// Nothing yet: stale once the connection has had time to deliver
+			// the initial book.
+			fh.AgeSeconds = now.Sub(since).Seconds()
+		} else {
+			fh.LastUpdate = &last
+			fh.AgeSeconds = now.Sub(last).Seconds()
+		}
+		fh.Stale = fh.AgeSeconds > staleAfter.Seconds()
+
+		if !connected {
+			rep.Disconnected = append(rep.Disconnected, f.slug)
+		}
+		if fh.Stale {
+			rep.Stale = append(rep.Stale, f.slug)
+		}
+		rep.Feeds = append(rep.Feeds, fh)
+	}
+
+	rep.Ready = rep.Redis.OK && execOK && len(rep.Disconnected) == 0
+	return rep
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,525 @@
+// Package ledger keeps a durable SQL record of the paper account: every fill
+// from trade:log, every deposit, withdrawal and reset from cash:log, and
+// periodic balance snapshots. Redis stays the live source of truth; the
+// ledger is what survives a FLUSHALL, and Reconcile compares the two.
+//
+// Entries are applied in stream ID order together with the stream cursors in
+// one transaction, so a restart resumes exactly where the last one stopped.
+// Only one process may write to a ledger.
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arjunprakash027/Mantis/pkg/logging"
+	"github.com/arjunprakash027/Mantis/pkg/metrics"
+	"github.com/arjunprakash027/Mantis/pkg/redismantis"
+	"github.com/redis/go-redis/v9"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	SQLite   = "sqlite"
+	Postgres = "postgres"
+)
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS ledger_entries (
+		seq         BIGINT PRIMARY KEY,
+		stream      TEXT NOT NULL,
+		stream_id   TEXT NOT NULL,
+		ts          BIGINT NOT NULL,
+		kind        TEXT NOT NULL,
+		asset_id    TEXT NOT NULL,
+		action      TEXT NOT NULL,
+		amount      DOUBLE PRECISION NOT NULL,
+		price       DOUBLE PRECISION NOT NULL,
+		cash_delta  DOUBLE PRECISION NOT NULL,
+		qty_delta   DOUBLE PRECISION NOT NULL,
+		balance_usd DOUBLE PRECISION NOT NULL,
+		strategy_id TEXT NOT NULL,
+		market      TEXT NOT NULL,
+		outcome     TEXT NOT NULL,
+		UNIQUE (stream, stream_id, asset_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS ledger_cursors (
+		stream  TEXT PRIMARY KEY,
+		last_id TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS balance_snapshots (
+		ts         BIGINT NOT NULL,
+		asset_id   TEXT NOT NULL,
+		quantity   DOUBLE PRECISION NOT NULL,
+		cost_basis DOUBLE PRECISION NOT NULL,
+		PRIMARY KEY (ts, asset_id)
+	)`,
+}
+
+// Open connects to a SQLite file or a PostgreSQL DSN and creates the tables
+// if needed.
+func Open(dialect, dsn string) (*sql.DB, error) {
+	var driver string
+	switch dialect {
+	case SQLite:
+		driver = "sqlite"
+	case Postgres:
+		driver = "pgx"
+	default:
+		return nil, fmt.Errorf("ledger: unknown driver %q", dialect)
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if dialect == SQLite {
+		// One writer; also keeps an in-memory database on one connection.
+		db.SetMaxOpenConns(1)
+	}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("ledger: create schema: %w", err)
+		}
+	}
+	return db, nil
+}
+
+var streams = []string{redismantis.HashTradeLog, redismantis.StreamCashLog}
+
+// pollBlock bounds each blocking read so Stop is noticed promptly.
+var pollBlock = time.Second
+
+type Ledger struct {
+	db      *sql.DB
+	dialect string
+	rdb     redis.UniversalClient
+	ctx     context.Context
+
+	snapshotEvery time.Duration
+	lastSnapshot  time.Time
+
+	cursors map[string]string
+	nextSeq int64
+
+	stop   chan struct{}
+	done   chan struct{}
+	logger *slog.Logger
+}
+
+func New(ctx context.Context, rdb redis.UniversalClient, db *sql.DB, dialect string) *Ledger {
+	return &Ledger{
+		db:            db,
+		dialect:       dialect,
+		rdb:           rdb,
+		ctx:           ctx,
+		snapshotEvery: time.Hour,
+		cursors:       make(map[string]string),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+		logger:        logging.For("ledger"),
+	}
+}
+
+// SetSnapshotInterval changes how often balances are snapshotted; zero only
+// snapshots on start and stop. It must be called before Start.
+func (l *Ledger) SetSnapshotInterval(d time.Duration) {
+	l.snapshotEvery = d
+}
+
+// Start opens the ledger on an empty database with the current Redis
+// balances, catches up on entries written while Mantis was down and
+// reconciles. Mismatches are logged and returned, not treated as errors.
+func (l *Ledger) Start() ([]Diff, error) {
+	rows, err := l.db.QueryContext(l.ctx, `SELECT stream, last_id FROM ledger_cursors`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var stream, id string
+		if err := rows.Scan(&stream, &id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		l.cursors[stream] = id
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := l.db.QueryRowContext(l.ctx, `SELECT COALESCE(MAX(seq), 0) + 1 FROM ledger_entries`).Scan(&l.nextSeq); err != nil {
+		return nil, err
+	}
+
+	if len(l.cursors) == 0 {
+		if err := l.open(); err != nil {
+			return nil, fmt.Errorf("ledger: opening balance: %w", err)
+		}
+	}
+	if err := l.catchUp(); err != nil {
+		return nil, fmt.Errorf("ledger: catch up: %w", err)
+	}
+	if err := l.snapshot(); err != nil {
+		return nil, err
+	}
+
+	diffs, err := l.Reconcile()
+	if err != nil {
+		return nil, err
+	}
+	metrics.LedgerMismatches.Set(float64(len(diffs)))
+	for _, d := range diffs {
+		l.logger.Warn("ledger and redis disagree", "asset_id", d.Asset, "ledger", d.Ledger, "redis", d.Redis)
+	}
+	if len(diffs) == 0 {
+		l.logger.Info("ledger reconciled with redis", "next_seq", l.nextSeq)
+	}
+	return diffs, nil
+}
+
+// Run tails the logs until Stop, snapshotting balances on the configured
+// interval and once more on the way out.
+func (l *Ledger) Run() {
+	defer close(l.done)
+	for {
+		select {
+		case <-l.stop:
+			if err := l.catchUp(); err != nil {
+				l.logger.Error("final ledger read failed", "err", err)
+			}
+			if err := l.snapshot(); err != nil {
+				l.logger.Error("final snapshot failed", "err", err)
+			}
+			return
+		default:
+		}
+
+		if _, err := l.poll(pollBlock); err != nil {
+			l.logger.Warn("ledger update failed, retrying", "err", err)
+			select {
+			case <-l.stop:
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		if l.snapshotEvery > 0 && time.Since(l.lastSnapshot) >= l.snapshotEvery {
+			if err := l.snapshot(); err != nil {
+				l.logger.Warn("balance snapshot failed", "err", err)
+			}
+		}
+	}
+}
+
+// Stop writes what is left in the logs, takes a last snapshot and waits for
+// Run to return, or for ctx to end.
+func (l *Ledger) Stop(ctx context.Context) error {
+	close(l.stop)
+	select {
+	case <-l.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// catchUp applies everything already in the logs without blocking.
+func (l *Ledger) catchUp() error {
+	for {
+		n, err := l.poll(-1)
+		if err != nil || n == 0 {
+			return err
+		}
+	}
+}
+
+type entry struct {
+	stream string
+	msg    redis.XMessage
+}
+
+// poll reads both logs from their cursors and applies what it finds. A
+// negative block does not wait.
+func (l *Ledger) poll(block time.Duration) (int, error) {
+	const count = 1000
+	args := &redis.XReadArgs{Count: count, Block: block}
+	for _, s := range streams {
+		args.Streams = append(args.Streams, s)
+	}
+	for _, s := range streams {
+		args.Streams = append(args.Streams, l.cursor(s))
+	}
+	res, err := l.rdb.XRead(l.ctx, args).Result()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// A stream that filled its page may have more entries before the other
+	// streams' last ones; stop at its end so entries apply in ID order.
+	var entries []entry
+	limit := ""
+	for _, s := range res {
+		for _, m := range s.Messages {
+			entries = append(entries, entry{s.Stream, m})
+		}
+		if n := len(s.Messages); n == count && (limit == "" || idLess(s.Messages[n-1].ID, limit)) {
+			limit = s.Messages[n-1].ID
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return idLess(entries[i].msg.ID, entries[j].msg.ID) })
+	if limit != "" {
+		n := sort.Search(len(entries), func(i int) bool { return idLess(limit, entries[i].msg.ID) })
+		entries = entries[:n]
+	}
+	return len(entries), l.apply(entries)
+}
+
+func (l *Ledger) cursor(stream string) string {
+	if id, ok := l.cursors[stream]; ok {
+		return id
+	}
+	return "0-0"
+}
+
+func (l *Ledger) apply(entries []entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	tx, err := l.db.BeginTx(l.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	seq := l.nextSeq
+	cursors := make(map[string]string)
+	for _, e := range entries {
+		r := toRow(e)
+		if err := l.insert(tx, seq, r); err != nil {
+			return err
+		}
+		seq++
+		cursors[e.stream] = e.msg.ID
+	}
+	for stream, id := range cursors {
+		if err := l.setCursor(tx, stream, id); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	l.nextSeq = seq
+	for stream, id := range cursors {
+		l.cursors[stream] = id
+	}
+	for _, e := range entries {
+		metrics.LedgerEntries.WithLabelValues(toRow(e).kind).Inc()
+	}
+	return nil
+}
+
+type row struct {
+	stream, streamID string
+	ts               int64
+	kind             string
+	asset, action    string
+	amount, price    float64
+	cash, qty        float64
+	balanceUSD       float64
+	strategy         string
+	market, outcome  string
+}
+
+func toRow(e entry) row {
+	v := e.msg.Values
+	s := func(k string) string {
+		s, _ := v[k].(string)
+		return s
+	}
+	f := func(k string) float64 {
+		n, _ := strconv.ParseFloat(s(k), 64)
+		return n
+	}
+	ms, _, _ := parseID(e.msg.ID)
+	r := row{stream: e.stream, streamID: e.msg.ID, ts: int64(ms), amount: f("amount"), balanceUSD: f("balance_usd")}
+
+	if e.stream == redismantis.HashTradeLog {
+		r.kind, r.asset, r.action, r.price = "fill", s("asset_id"), s("action"), f("price")
+		r.strategy, r.market, r.outcome = s("strategy"), s("market"), s("outcome")
+		switch r.action {
+		case "BUY":
+			r.cash, r.qty = -f("total"), r.amount
+		case "SETTLE":
+			// A resolved market pays out and closes the position.
+			r.kind = "settlement"
+			r.cash, r.qty = f("total"), -r.amount
+		default:
+			r.cash, r.qty = f("total"), -r.amount
+		}
+		return r
+	}
+
+	r.kind = s("kind")
+	switch r.kind {
+	case "withdrawal":
+		r.cash = -r.amount
+	default:
+		// Deposits add to the balance; a reset starts it over from amount.
+		r.cash = r.amount
+	}
+	return r
+}
+
+func (l *Ledger) insert(tx *sql.Tx, seq int64, r row) error {
+	_, err := tx.ExecContext(l.ctx, l.rebind(`INSERT INTO ledger_entries
+		(seq, stream, stream_id, ts, kind, asset_id, action, amount, price, cash_delta, qty_delta, balance_usd, strategy_id, market, outcome)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		seq, r.stream, r.streamID, r.ts, r.kind, r.asset, r.action, r.amount, r.price, r.cash, r.qty, r.balanceUSD, r.strategy, r.market, r.outcome)
+	return err
+}
+
+func (l *Ledger) setCursor(tx *sql.Tx, stream, id string) error {
+	_, err := tx.ExecContext(l.ctx, l.rebind(`INSERT INTO ledger_cursors (stream, last_id) VALUES (?, ?)
+		ON CONFLICT (stream) DO UPDATE SET last_id = excluded.last_id`), stream, id)
+	return err
+}
+
+// open records the current Redis balances as the opening entry and starts
+// the cursors at the current end of each log, all read in one MULTI. Earlier
+// history is already in those balances and is not imported.
+func (l *Ledger) open() error {
+	pipe := l.rdb.TxPipeline()
+	balances := pipe.HGetAll(l.ctx, redismantis.HashPortfolioBalance)
+	tails := make([]*redis.XMessageSliceCmd, len(streams))
+	for i, s := range streams {
+		tails[i] = pipe.XRevRangeN(l.ctx, s, "+", "-", 1)
+	}
+	if _, err := pipe.Exec(l.ctx); err != nil {
+		return err
+	}
+
+	tx, err := l.db.BeginTx(l.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UnixMilli()
+	id := fmt.Sprintf("%d-0", now)
+	cash, _ := strconv.ParseFloat(balances.Val()["USD"], 64)
+	// The cash row comes first: it marks where the opening starts.
+	seq := l.nextSeq
+	if err := l.insert(tx, seq, row{stream: "opening", streamID: id, ts: now, kind: "opening", cash: cash, balanceUSD: cash}); err != nil {
+		return err
+	}
+	assets := make([]string, 0, len(balances.Val()))
+	for asset := range balances.Val() {
+		if asset != "USD" {
+			assets = append(assets, asset)
+		}
+	}
+	sort.Strings(assets)
+	for _, asset := range assets {
+		qty, _ := strconv.ParseFloat(balances.Val()[asset], 64)
+		seq++
+		r := row{stream: "opening", streamID: id, ts: now, kind: "opening", asset: asset, amount: qty, qty: qty, balanceUSD: cash}
+		if err := l.insert(tx, seq, r); err != nil {
+			return err
+		}
+	}
+
+	cursors := make(map[string]string)
+	for i, s := range streams {
+		cursors[s] = "0-0"
+		if msgs := tails[i].Val(); len(msgs) > 0 {
+			cursors[s] = msgs[0].ID
+		}
+		if err := l.setCursor(tx, s, cursors[s]); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	l.nextSeq = seq + 1
+	l.cursors = cursors
+	l.logger.Info("ledger opened", "cash", cash, "positions", len(assets))
+	return nil
+}
+
+// snapshot copies the Redis balances and cost basis into balance_snapshots.
+func (l *Ledger) snapshot() error {
+	pipe := l.rdb.TxPipeline()
+	balances := pipe.HGetAll(l.ctx, redismantis.HashPortfolioBalance)
+	costs := pipe.HGetAll(l.ctx, redismantis.HashPortfolioCost)
+	if _, err := pipe.Exec(l.ctx); err != nil {
+		return err
+	}
+
+	tx, err := l.db.BeginTx(l.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	now := time.Now()
+	for asset, v := range balances.Val() {
+		qty, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			continue
+		}
+		cost, _ := strconv.ParseFloat(costs.Val()[asset], 64)
+		_, err = tx.ExecContext(l.ctx, l.rebind(`INSERT INTO balance_snapshots (ts, asset_id, quantity, cost_basis) VALUES (?, ?, ?, ?)
+			ON CONFLICT (ts, asset_id) DO NOTHING`), now.UnixMilli(), asset, qty, cost)
+		if err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	l.lastSnapshot = now
+	return nil
+}
+
+// rebind turns ? placeholders into $n for PostgreSQL.
+func (l *Ledger) rebind(query string) string {
+	if l.dialect != Postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func parseID(id string) (ms, seq uint64, ok bool) {
+	a, b, found := strings.Cut(id, "-")
+	ms, err1 := strconv.ParseUint(a, 10, 64)
+	seq, err2 := strconv.ParseUint(b, 10, 64)
+	return ms, seq, found && err1 == nil && err2 == nil
+}
+
+func idLess(a, b string) bool {
+	am, as, _ := parseID(a)
+	bm, bs, _ := parseID(b)
+	return am < bm || (am == bm && as < bs)
+}
@@ -0,0 +1,146 @@
+package ledger
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/arjunprakash027/Mantis/executor"
+	"github.com/arjunprakash027/Mantis/pkg/redismantis"
+	"github.com/arjunprakash027/Mantis/streamer"
+	"github.com/redis/go-redis/v9"
+)
+
+func init() {
+	pollBlock = 20 * time.Millisecond
+}
+
+// fill mimics trade.lua: the balance change and its trade log entry.
+func fill(t *testing.T, rdb *redis.Client, action, asset string, amount, price float64) {
+	ctx := context.Background()
+	total := amount * price
+	if action == "SELL" {
+		amount, total = -amount, -total
+	}
+	usd := rdb.HIncrByFloat(ctx, redismantis.HashPortfolioBalance, "USD", -total).Val()
+	qty := rdb.HIncrByFloat(ctx, redismantis.HashPortfolioBalance, asset, amount).Val()
+	rdb.XAdd(ctx, &redis.XAddArgs{Stream: redismantis.HashTradeLog, Values: map[string]interface{}{
+		"action": action, "asset_id": asset, "amount": max(amount, -amount), "price": price,
+		"total": max(total, -total), "balance_usd": usd, "balance_asset": qty, "strategy": "bot",
+	}})
+}
+
+func start(t *testing.T, rdb *redis.Client, path string) *Ledger {
+	db, err := Open(SQLite, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	l := New(context.Background(), rdb, db, SQLite)
+	diffs, err := l.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) > 0 {
+		t.Fatalf("unexpected diffs on start: %+v", diffs)
+	}
+	return l
+}
+
+func TestLedger(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "ledger.db")
+
+	// History before the ledger existed becomes its opening balance.
+	executor.Deposit(ctx, rdb, 100)
+	fill(t, rdb, "BUY", "A", 10, 0.5)
+	l := start(t, rdb, path)
+
+	go l.Run()
+	executor.Deposit(ctx, rdb, 50)
+	fill(t, rdb, "SELL", "A", 4, 0.6)
+	fill(t, rdb, "BUY", "B", 2, 0.25)
+	if _, err := executor.Withdraw(ctx, rdb, 20); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Written while the ledger was down, picked up on the next start.
+	fill(t, rdb, "BUY", "B", 1, 0.5)
+	l = start(t, rdb, path)
+	got, err := l.Balances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["USD"] != 126.4 || got["A"] != 6 || got["B"] != 3 {
+		t.Errorf("balances = %v", got)
+	}
+	var fills int
+	l.db.QueryRow(`SELECT COUNT(*) FROM ledger_entries WHERE kind = 'fill'`).Scan(&fills)
+	if fills != 3 {
+		t.Errorf("%d fills recorded, want 3 (history before opening is not imported)", fills)
+	}
+
+	// A reset starts the balances over.
+	if err := executor.Reset(ctx, rdb, 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.catchUp(); err != nil {
+		t.Fatal(err)
+	}
+	if diffs, _ := l.Reconcile(); len(diffs) > 0 {
+		t.Errorf("diffs after reset: %+v", diffs)
+	}
+
+	// A flushed Redis no longer matches the ledger.
+	s.FlushAll()
+	diffs, err := l.Reconcile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 1 || diffs[0] != (Diff{Asset: "USD", Ledger: 10, Redis: 0}) {
+		t.Errorf("diffs after flush = %+v", diffs)
+	}
+}
+
+func TestSettlementEntries(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	ctx := context.Background()
+	executor.Deposit(ctx, rdb, 100)
+	l := start(t, rdb, filepath.Join(t.TempDir(), "ledger.db"))
+
+	fill(t, rdb, "BUY", "Y", 10, 0.6)
+	fill(t, rdb, "BUY", "N", 5, 0.4)
+	executor.NewExecutor(ctx, rdb, streamer.NewEngine(ctx, rdb)).Settle([]string{"Y", "N"}, "Y")
+	if err := l.catchUp(); err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	var cash float64
+	l.db.QueryRow(`SELECT COUNT(*), SUM(cash_delta) FROM ledger_entries WHERE kind = 'settlement'`).Scan(&n, &cash)
+	if n != 2 || cash != 10 {
+		t.Errorf("%d settlements paying %v, want 2 paying 10", n, cash)
+	}
+	if diffs, err := l.Reconcile(); err != nil || len(diffs) > 0 {
+		t.Errorf("diffs after settlement: %+v %v", diffs, err)
+	}
+}
+
+func TestIDOrderAndRebind(t *testing.T) {
+	a := []string{"5-0", "10-0", "10-1", "9-3"}
+	if !idLess(a[0], a[1]) || !idLess(a[1], a[2]) || idLess(a[1], a[3]) {
+		t.Error("idLess does not order stream IDs numerically")
+	}
+	l := &Ledger{dialect: Postgres}
+	if q := l.rebind("VALUES (?, ?)"); q != "VALUES ($1, $2)" {
+		t.Errorf("rebind = %q", q)
+	}
+}
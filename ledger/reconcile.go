@@ -0,0 +1,81 @@
+package ledger
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/arjunprakash027/Mantis/pkg/redismantis"
+)
+
+// Diff is a balance the ledger and Redis disagree on. Asset is "USD" for
+// cash.
+type Diff struct {
+	Asset  string
+	Ledger float64
+	Redis  float64
+}
+
+// tolerance absorbs float rounding between HINCRBYFLOAT and SQL sums.
+const tolerance = 1e-6
+
+// Balances sums the ledger since its last opening or reset: cash under
+// "USD", positions under their asset IDs.
+func (l *Ledger) Balances() (map[string]float64, error) {
+	var base int64
+	err := l.db.QueryRowContext(l.ctx, `SELECT COALESCE(MAX(seq), 0) FROM ledger_entries
+		WHERE kind = 'reset' OR (kind = 'opening' AND asset_id = '')`).Scan(&base)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := l.db.QueryContext(l.ctx, l.rebind(`SELECT asset_id, SUM(cash_delta), SUM(qty_delta) FROM ledger_entries
+		WHERE seq >= ? GROUP BY asset_id`), base)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string]float64{"USD": 0}
+	for rows.Next() {
+		var asset string
+		var cash, qty float64
+		if err := rows.Scan(&asset, &cash, &qty); err != nil {
+			return nil, err
+		}
+		out["USD"] += cash
+		if asset != "" {
+			out[asset] = qty
+		}
+	}
+	return out, rows.Err()
+}
+
+// Reconcile compares the ledger's balances with the Redis portfolio.
+func (l *Ledger) Reconcile() ([]Diff, error) {
+	want, err := l.Balances()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := l.rdb.HGetAll(l.ctx, redismantis.HashPortfolioBalance).Result()
+	if err != nil {
+		return nil, err
+	}
+	got := make(map[string]float64, len(raw))
+	for asset, v := range raw {
+		got[asset], _ = strconv.ParseFloat(v, 64)
+	}
+
+	var diffs []Diff
+	for asset, v := range want {
+		if math.Abs(v-got[asset]) > tolerance {
+			diffs = append(diffs, Diff{asset, v, got[asset]})
+		}
+	}
+	for asset, v := range got {
+		if _, ok := want[asset]; !ok && math.Abs(v) > tolerance {
+			diffs = append(diffs, Diff{asset, 0, v})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Asset < diffs[j].Asset })
+	return diffs, nil
+}
@@ -2,94 +2,408 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/arjunprakash027/Mantis/admin"
+	"github.com/arjunprakash027/Mantis/backtest"
 	"github.com/arjunprakash027/Mantis/config"
 	"github.com/arjunprakash027/Mantis/executor"
+	"github.com/arjunprakash027/Mantis/ledger"
 	"github.com/arjunprakash027/Mantis/market"
+	"github.com/arjunprakash027/Mantis/pkg/logging"
+	"github.com/arjunprakash027/Mantis/pkg/metrics"
+	"github.com/arjunprakash027/Mantis/pkg/redisclient"
+	"github.com/arjunprakash027/Mantis/pkg/redismantis"
+	"github.com/arjunprakash027/Mantis/recorder"
+	"github.com/arjunprakash027/Mantis/strategy"
 	"github.com/arjunprakash027/Mantis/streamer"
-	"github.com/redis/go-redis/v9"
 )
 
+var configPath string
+
 func main() {
+	name, args := "run", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "mantis: unknown command %q\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+	err := cmd.run(args)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mantis %s: %v\n", name, err)
+		os.Exit(1)
+	}
+}
+
+// runEngine starts the data engine and executor and blocks until SIGINT or
+// SIGTERM. It is the default command.
+func runEngine(args []string) error {
+	fs := newFlagSet("run")
+	replayDir := fs.String("replay", "", "replay captured frames from this directory instead of streaming live")
+	replaySpeed := fs.Float64("speed", 1, "replay speed multiplier (0 = as fast as possible)")
+	backtestMode := fs.Bool("backtest", false, "backtest the -replay capture with -signals and configured strategies, then exit")
+	backtestSignals := fs.String("signals", "", "signals file (JSON lines) for -backtest")
+	backtestCash := fs.Float64("cash", 1000, "starting USD balance for -backtest")
+	backtestOut := fs.String("out", "backtest-out", "directory for -backtest reports")
+	backtestDB := fs.Int("scratch-db", -1, "empty redis database number that -backtest keeps its portfolio in")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
 	// 1. Load Configuration
-	cfg, err := config.LoadConfig("config.yaml")
+	cfg, err := config.LoadConfig(configPath)
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		fatal("failed to load config", "path", configPath, "err", err)
+	}
+	if err := logging.Setup(os.Stderr, loggingOptions(cfg)); err != nil {
+		fatal("failed to configure logging", "err", err)
+	}
+	logger := logging.For("main")
+
+	if *backtestMode {
+		if err := runBacktest(cfg, *backtestDB, *replayDir, *backtestSignals, *backtestCash, *backtestOut); err != nil {
+			fatal("backtest failed", "err", err)
+		}
+		return nil
 	}
 
 	// 2. Setup Redis
-	rdb := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	rdb, err := redisclient.New(cfg.Redis)
+	if err != nil {
+		fatal("failed to configure redis", "err", err)
+	}
+	// ctx stops the feeds; redisCtx outlives it so in-flight trades and
+	// stream writes can finish during shutdown.
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+	redisCtx, closeRedis := context.WithCancel(context.Background())
+	defer closeRedis()
 
-	fmt.Println("Mantis Data Engine Starting...")
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		logger.Warn("redis not reachable", "addrs", cfg.Redis.Addrs, "err", err)
+	} else if moved, err := redismantis.MigrateKeys(ctx, rdb); err != nil {
+		logger.Warn("legacy key migration failed", "err", err)
+	} else if len(moved) > 0 {
+		logger.Info("renamed legacy keys to their {mantis} names", "keys", moved)
+	}
 
-	marketEngine := streamer.NewEngine(ctx, rdb)
+	logger.Info("mantis data engine starting")
 
-	// 3. Start Orderbook Pipelines
-	if cfg.Pipelines.Orderbook.Enabled {
-		fmt.Printf("Starting Orderbook Pipelines for %d markets...\n", len(cfg.Pipelines.Orderbook.Markets))
-		for _, slug := range cfg.Pipelines.Orderbook.Markets {
-			go startOrderbookForSlug(marketEngine, slug)
+	marketEngine := streamer.NewEngine(redisCtx, rdb)
+	marketEngine.SetMarketStreams(cfg.Pipelines.Orderbook.MarketStreams)
+	marketEngine.SetRetention(retentionPolicy(cfg))
+	if cc := cfg.Candles; cc.Enabled {
+		opts := streamer.CandleOptions{Window: cc.Window}
+		for _, iv := range cc.Intervals {
+			d, _ := time.ParseDuration(iv) // checked by config validation
+			opts.Intervals = append(opts.Intervals, d)
+		}
+		marketEngine.StartCandles(opts)
+	}
+	if fc := cfg.Features; fc.Enabled {
+		err := marketEngine.StartFeatures(streamer.FeatureOptions{
+			Set:              fc.Set,
+			DepthLevels:      fc.DepthLevels,
+			VolatilityWindow: time.Duration(fc.VolatilityWindowSeconds) * time.Second,
+		})
+		if err != nil {
+			fatal("features not started", "err", err)
+		}
+	}
+	if ac := cfg.Arb; ac.Enabled {
+		marketEngine.StartArb(streamer.ArbOptions{MinEdge: ac.MinEdge, FeeRate: ac.FeeBps / 10000})
+	}
+	if sw := cfg.StreamWriter; sw.Enabled {
+		marketEngine.StartWriter(streamer.WriterOptions{
+			QueueSize:     sw.QueueSize,
+			BatchSize:     sw.BatchSize,
+			FlushInterval: time.Duration(sw.FlushIntervalMs) * time.Millisecond,
+			Overflow:      streamer.Overflow(sw.Overflow),
+		})
+	}
+
+	var rec *recorder.Recorder
+	if cfg.Recorder.Enabled && *replayDir != "" {
+		logger.Info("recorder disabled while replaying")
+	} else if cfg.Recorder.Enabled {
+		rec, err = recorder.New(recorder.Options{
+			Dir:          cfg.Recorder.Dir,
+			RotateEvery:  time.Duration(cfg.Recorder.RotateMinutes) * time.Minute,
+			MaxFileBytes: int64(cfg.Recorder.MaxFileMB) << 20,
+		})
+		if err != nil {
+			fatal("failed to start recorder", "dir", cfg.Recorder.Dir, "err", err)
 		}
+		marketEngine.SetRecorder(rec)
+		logger.Info("recording raw frames", "dir", cfg.Recorder.Dir)
+	}
+
+	client := market.NewClient(market.ClientConfig{
+		GammaURL:  cfg.Polymarket.GammaURL,
+		ClobWSURL: cfg.Polymarket.ClobWSURL,
+	})
+
+	exec := executor.NewExecutor(redisCtx, rdb, marketEngine)
+	marketEngine.SetSettler(exec)
+
+	// Strategy listeners must be attached before any stream feeds the engine.
+	if len(cfg.Strategies) > 0 {
+		host := strategy.NewHost(marketEngine, exec)
+		for _, sc := range cfg.Strategies {
+			s, err := strategy.New(sc.Type, sc.ID, sc.Params)
+			if err != nil {
+				fatal("failed to build strategy", "strategy_id", sc.ID, "type", sc.Type, "err", err)
+			}
+			host.Register(s)
+			logger.Info("strategy running in-process", "strategy_id", sc.ID, "type", sc.Type)
+		}
+		go host.Start(ctx)
+	}
+
+	if *replayDir != "" {
+		go startReplay(ctx, marketEngine, *replayDir, *replaySpeed)
+	}
+
+	// 3. Start Orderbook Pipelines
+	subs := newSubscriptions(ctx, client, marketEngine)
+	if *replayDir == "" {
+		subs.Apply(cfg)
 	}
 
 	// 4. Start Discovery Pipeline
-	if cfg.Pipelines.Discovery.Enabled {
-		fmt.Printf("Starting Discovery Pipeline for every %d minutes...\n", cfg.Pipelines.Discovery.IntervalMinutes)
+	if cfg.Pipelines.Discovery.Enabled && *replayDir == "" {
 		discoveryChan := make(chan []byte)
-		if err := market.StartDiscoveryStream(discoveryChan); err != nil {
-			log.Printf("Discovery Error: %v", err)
+		interval := time.Duration(cfg.Pipelines.Discovery.IntervalMinutes) * time.Minute
+		if err := client.StartDiscoveryStream(ctx, discoveryChan, interval); err != nil {
+			logger.Error("discovery failed to start", "err", err)
 		} else {
-			go marketEngine.ProcessStream("discovery", discoveryChan)
+			marketEngine.ProcessStream("discovery", discoveryChan)
+		}
+	}
+
+	// The ledger catches up and reconciles before the executor takes signals.
+	var sink *ledger.Ledger
+	if lc := cfg.Ledger; lc.Enabled {
+		dsn := lc.DSN
+		if v := os.Getenv(lc.DSNEnv); v != "" {
+			dsn = v
+		}
+		db, err := ledger.Open(lc.Driver, dsn)
+		if err != nil {
+			fatal("failed to open ledger", "driver", lc.Driver, "err", err)
+		}
+		defer db.Close()
+		sink = ledger.New(redisCtx, rdb, db, lc.Driver)
+		sink.SetSnapshotInterval(time.Duration(lc.SnapshotIntervalMinutes) * time.Minute)
+		if _, err := sink.Start(); err != nil {
+			fatal("failed to start ledger", "err", err)
 		}
+		go sink.Run()
 	}
 
-	exec := executor.NewExecutor(ctx, rdb, marketEngine)
+	exec.SetLimits(riskLimits(cfg))
 	go exec.Start()
 
-	fmt.Println("Pipelines & Executor active. Press Ctrl+C to stop.")
+	if cfg.HTTP.Addr != "" {
+		metrics.RegisterLive(marketEngine.LastUpdates, func() (float64, float64, map[string]float64, error) {
+			p, err := exec.Portfolio()
+			return p.Cash, p.Equity, p.Positions, err
+		}, marketEngine.Clock().Now)
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		(&health{rdb: rdb, engine: marketEngine, exec: exec, subs: subs}).register(mux)
+		if cfg.Admin.Enabled {
+			if token := os.Getenv(cfg.Admin.TokenEnv); token == "" {
+				logger.Warn("admin API disabled: token not set", "env", cfg.Admin.TokenEnv)
+			} else {
+				admin.New(token, exec, marketEngine, subs).Register(mux)
+				logger.Info("admin API enabled", "addr", cfg.HTTP.Addr)
+			}
+		}
+		go startHTTP(ctx, cfg.HTTP.Addr, mux)
+	}
+
+	// 5. Hot reload: market lists, risk limits and stream retention apply
+	// live; other sections need a restart.
+	go config.Watch(ctx, configPath, 2*time.Second, func(next *config.Config) {
+		if *replayDir == "" {
+			subs.Apply(next)
+		}
+		exec.SetLimits(riskLimits(next))
+		marketEngine.SetRetention(retentionPolicy(next))
+		if err := logging.SetLevel(next.Logging.Level); err != nil {
+			logger.Error("invalid log level", "err", err)
+		}
+	})
+
+	logger.Info("pipelines and executor active")
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 	<-stop
 
-	log.Println("Shutting down Mantis...")
+	timeout := time.Duration(cfg.Shutdown.TimeoutSeconds) * time.Second
+	logger.Info("shutting down", "timeout", timeout.String())
+	go func() {
+		<-stop
+		logger.Warn("second signal, exiting without draining")
+		os.Exit(1)
+	}()
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), timeout)
+	defer cancelShutdown()
+
+	// 1. Stop taking signals; the one in flight is executed and acknowledged.
+	if err := exec.Stop(shutdownCtx); err != nil {
+		logger.Warn("executor did not finish in time", "err", err)
+	}
+	// 2. Close WebSockets (with a close frame), discovery, replay, strategy
+	// timers and the HTTP server. Their channels close behind them.
 	cancel()
+	// 3. Write every frame that was already received.
+	if err := marketEngine.Drain(shutdownCtx); err != nil {
+		logger.Warn("streams did not drain in time", "err", err)
+	}
+	// 4. Nothing writes to Redis any more.
+	if sink != nil {
+		if err := sink.Stop(shutdownCtx); err != nil {
+			logger.Warn("ledger did not finish in time", "err", err)
+		}
+	}
+	if rec != nil {
+		if err := rec.Close(); err != nil {
+			logger.Error("recorder close failed", "err", err)
+		}
+	}
+	closeRedis()
 	rdb.Close()
+	logger.Info("shutdown complete")
+	return nil
+}
+
+// fatal logs at error level and exits, like log.Fatal.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+func loggingOptions(cfg *config.Config) logging.Options {
+	ls := cfg.Logging.Sample
+	return logging.Options{
+		Level:            cfg.Logging.Level,
+		Format:           cfg.Logging.Format,
+		SampleInitial:    ls.Initial,
+		SampleThereafter: ls.Thereafter,
+		SampleInterval:   time.Duration(ls.IntervalSeconds) * time.Second,
+	}
+}
+
+func riskLimits(cfg *config.Config) executor.Limits {
+	return executor.Limits{
+		MaxOrderAmount:   cfg.Risk.MaxOrderAmount,
+		MaxOrderNotional: cfg.Risk.MaxOrderNotional,
+	}
+}
+
+func retentionPolicy(cfg *config.Config) streamer.RetentionPolicy {
+	conv := func(p config.RetentionPolicy) streamer.Retention {
+		return streamer.Retention{MaxLen: int64(p.MaxLen), MaxAge: time.Duration(p.MaxAgeMinutes) * time.Minute}
+	}
+	rc := cfg.Retention
+	p := streamer.RetentionPolicy{
+		Default:    conv(rc.Default),
+		Namespaces: make(map[string]streamer.Retention, len(rc.Namespaces)),
+		Markets:    make(map[string]streamer.Retention, len(rc.Markets)),
+	}
+	for ns, r := range rc.Namespaces {
+		p.Namespaces[ns] = conv(r)
+	}
+	for slug, r := range rc.Markets {
+		p.Markets[slug] = conv(r)
+	}
+	return p
 }
 
-func startOrderbookForSlug(engine *streamer.Engine, slug string) {
-	// A. Fetch Tokens
-	tokens, eventTitle, err := market.GetTokens(slug)
+func runBacktest(cfg *config.Config, db int, captureDir, signalsPath string, cash float64, outDir string) error {
+	if db < 0 || db == cfg.Redis.DB {
+		return fmt.Errorf("set -scratch-db to an empty database other than the live one (%d)", cfg.Redis.DB)
+	}
+	files, err := recorder.Files(captureDir)
 	if err != nil {
-		log.Printf("[%s] Lookup Error: %v", slug, err)
-		return
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no capture files in %q (set -replay)", captureDir)
+	}
+	var signals []backtest.TimedSignal
+	if signalsPath != "" {
+		if signals, err = backtest.LoadSignals(signalsPath); err != nil {
+			return err
+		}
 	}
 
-	// B. Register Metadata
-	if err := engine.RegisterMetadata(slug, tokens); err != nil {
-		log.Printf("[%s] Metadata warning: %v", slug, err)
+	rc := cfg.Redis
+	rc.DB = db
+	rdb, err := redisclient.New(rc)
+	if err != nil {
+		return err
 	}
+	defer rdb.Close()
 
-	// C. Start Stream
-	assetIds := make([]string, len(tokens))
-	for i, t := range tokens {
-		assetIds[i] = t.TokenID
+	runner, err := backtest.NewRunner(context.Background(), backtest.Config{
+		Redis:        rdb,
+		Files:        files,
+		Signals:      signals,
+		StartingCash: cash,
+	})
+	if err != nil {
+		return err
 	}
+	defer runner.Close()
 
-	msgChan := make(chan []byte)
-	if err := market.StartOrderBookStream(engine.GetContext(), assetIds, msgChan); err != nil {
-		log.Printf("[%s] Stream Error: %v", slug, err)
-		return
+	for _, sc := range cfg.Strategies {
+		s, err := strategy.New(sc.Type, sc.ID, sc.Params)
+		if err != nil {
+			return fmt.Errorf("strategy %s: %w", sc.ID, err)
+		}
+		runner.AddStrategy(s)
 	}
 
-	fmt.Printf("Streaming %s (%d tokens)\n", eventTitle, len(tokens))
+	report, err := runner.Run()
+	if err != nil {
+		return err
+	}
+	report.Summary.Print(os.Stdout)
+	return report.WriteFiles(outDir)
+}
 
-	engine.ProcessStream("orderbook", msgChan)
+func startReplay(ctx context.Context, engine *streamer.Engine, dir string, speed float64) {
+	logger := logging.For("replay").With("dir", dir)
+	files, err := recorder.Files(dir)
+	if err != nil || len(files) == 0 {
+		logger.Error("no capture files to replay", "err", err)
+		return
+	}
+	logger.Info("replay started", "files", len(files), "speed", speed)
+	if err := recorder.ReplayInto(ctx, engine, &recorder.Player{Files: files, Speed: speed}); err != nil {
+		logger.Error("replay failed", "err", err)
+		return
+	}
+	logger.Info("replay finished")
 }
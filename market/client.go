@@ -0,0 +1,54 @@
+package market
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	DefaultGammaURL  = "https://gamma-api.polymarket.com"
+	DefaultClobWSURL = "wss://ws-subscriptions-clob.polymarket.com/ws/market"
+)
+
+// ClientConfig overrides the endpoints and transports used to reach
+// Polymarket. Zero values fall back to the production defaults.
+type ClientConfig struct {
+	GammaURL  string
+	ClobWSURL string
+	Transport http.RoundTripper
+	Dialer    *websocket.Dialer
+}
+
+// Client talks to the gamma REST API and the CLOB market WebSocket.
+type Client struct {
+	gammaURL  string
+	clobWSURL string
+	http      *http.Client
+	dialer    *websocket.Dialer
+	limiter   *rateLimiter
+}
+
+// DefaultClient is used by the package-level helpers.
+var DefaultClient = NewClient(ClientConfig{})
+
+func NewClient(cfg ClientConfig) *Client {
+	c := &Client{
+		gammaURL:  strings.TrimRight(cfg.GammaURL, "/"),
+		clobWSURL: cfg.ClobWSURL,
+		http:      &http.Client{Timeout: requestLimit, Transport: cfg.Transport},
+		dialer:    cfg.Dialer,
+		limiter:   &rateLimiter{gap: requestGap},
+	}
+	if c.gammaURL == "" {
+		c.gammaURL = DefaultGammaURL
+	}
+	if c.clobWSURL == "" {
+		c.clobWSURL = DefaultClobWSURL
+	}
+	if c.dialer == nil {
+		c.dialer = websocket.DefaultDialer
+	}
+	return c
+}
@@ -1,10 +1,12 @@
 package market
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"time"
+
+	"github.com/arjunprakash027/Mantis/pkg/logging"
 )
 
 type DiscoveryMarket struct {
@@ -28,55 +30,103 @@ type DiscoveryMarket struct {
 	OutcomePrices string `json:"outcomePrices"`
 }
 
-func StartDiscoveryStream(ch chan<- []byte) error {
-	base := "https://gamma-api.polymarket.com/markets?active=true&closed=false&limit=100&order=startDate&ascending=false"
-	fmt.Printf("Discovery Stream Started..")
+// DiscoveryScan is one pass over the gamma markets listing. Complete is false
+// when pagination stopped early, in which case Closed is never populated: a
+// truncated scan can't tell a closed market from one we simply didn't reach.
+// The engine writes Markets as the stream entry's data and the rest as
+// separate fields.
+type DiscoveryScan struct {
+	Complete  bool              `json:"complete"`
+	ScannedAt int64             `json:"scanned_at"`
+	Error     string            `json:"error,omitempty"`
+	Markets   []DiscoveryMarket `json:"markets"`
+	Closed    []string          `json:"closed,omitempty"`
+}
+
+const discoveryPageSize = 100
+
+func StartDiscoveryStream(ctx context.Context, ch chan<- []byte, interval time.Duration) error {
+	return DefaultClient.StartDiscoveryStream(ctx, ch, interval)
+}
+
+// StartDiscoveryStream pushes a DiscoveryScan into ch every interval. ch is
+// closed once ctx is cancelled.
+func (c *Client) StartDiscoveryStream(ctx context.Context, ch chan<- []byte, interval time.Duration) error {
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+	base := c.gammaURL + "/markets?active=true&closed=false&limit=100&order=startDate&ascending=false"
+	logger := logging.FromContext(ctx).With("component", "discovery")
+	logger.Info("discovery stream started", "interval", interval)
 
 	go func() {
-		ticker := time.NewTicker(10 * time.Minute)
-		for {
-			var allMarkets []DiscoveryMarket
-			offset := 0
-
-			for {
-				url := fmt.Sprintf("%s&offset=%d", base, offset)
-				resp, err := http.Get(url)
-				if err != nil {
-					fmt.Printf("Discovery Network Error: %v\n", err)
-					break
-				}
+		defer close(ch)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
 
-				if resp.StatusCode != http.StatusOK {
-					fmt.Printf("Discovery API Error: status %d for offset %d\n", resp.StatusCode, offset)
-					resp.Body.Close()
-					break
-				}
+		// IDs seen in the last complete scan, used for close detection.
+		var known map[string]bool
+
+		for {
+			scan := c.scanMarkets(ctx, base)
+			if ctx.Err() != nil {
+				return
+			}
 
-				var batch []DiscoveryMarket
-				decoder := json.NewDecoder(resp.Body)
-				if err := decoder.Decode(&batch); err != nil {
-					fmt.Printf("Discovery Decode Error at offset %d: %v\n", offset, err)
-					resp.Body.Close()
-					break
+			if scan.Complete {
+				current := make(map[string]bool, len(scan.Markets))
+				for _, m := range scan.Markets {
+					current[m.ID] = true
 				}
-				if len(batch) == 0 {
-					resp.Body.Close()
-					break
+				for id := range known {
+					if !current[id] {
+						scan.Closed = append(scan.Closed, id)
+					}
 				}
-				allMarkets = append(allMarkets, batch...)
-				resp.Body.Close()
-				offset += 100
+				known = current
+			} else {
+				logger.Warn("discovery scan truncated", "markets", len(scan.Markets), "err", scan.Error)
 			}
 
-			if len(allMarkets) > 0 {
-				fmt.Printf("Discovery: Found %d active markets. Pushing to Redis...\n", len(allMarkets))
-				if data, err := json.Marshal(allMarkets); err == nil {
-					ch <- data
+			if len(scan.Markets) > 0 {
+				logger.Info("discovery scan finished", "markets", len(scan.Markets), "closed", len(scan.Closed), "complete", scan.Complete)
+				if data, err := json.Marshal(scan); err == nil {
+					select {
+					case ch <- data:
+					case <-ctx.Done():
+						return
+					}
 				}
 			}
-			<-ticker.C
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
 		}
 	}()
 
 	return nil
 }
+
+func (c *Client) scanMarkets(ctx context.Context, base string) DiscoveryScan {
+	scan := DiscoveryScan{ScannedAt: time.Now().Unix()}
+	offset := 0
+
+	for {
+		url := fmt.Sprintf("%s&offset=%d", base, offset)
+
+		var batch []DiscoveryMarket
+		if err := c.getJSON(ctx, url, &batch); err != nil {
+			scan.Error = fmt.Sprintf("offset %d: %v", offset, err)
+			return scan
+		}
+		scan.Markets = append(scan.Markets, batch...)
+		if len(batch) < discoveryPageSize {
+			scan.Complete = true
+			return scan
+		}
+		offset += discoveryPageSize
+	}
+}
@@ -0,0 +1,70 @@
+package market
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func fastBackoff(t *testing.T) {
+	prev := baseBackoff
+	baseBackoff = time.Millisecond
+	t.Cleanup(func() { baseBackoff = prev })
+}
+
+func TestGetJSONRetriesTooManyRequests(t *testing.T) {
+	fastBackoff(t)
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, `{"question":"ok"}`)
+	}))
+	defer srv.Close()
+
+	var out struct {
+		Question string `json:"question"`
+	}
+	if err := NewClient(ClientConfig{}).getJSON(context.Background(), srv.URL, &out); err != nil {
+		t.Fatalf("expected retry to succeed: %v", err)
+	}
+	if out.Question != "ok" || atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("got %+v after %d calls", out, calls)
+	}
+}
+
+func TestScanMarketsTruncated(t *testing.T) {
+	fastBackoff(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		if offset >= discoveryPageSize {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprint(w, "[")
+		for i := 0; i < discoveryPageSize; i++ {
+			if i > 0 {
+				fmt.Fprint(w, ",")
+			}
+			fmt.Fprintf(w, `{"id":"%d","liquidity":"1","volume":"1"}`, i)
+		}
+		fmt.Fprint(w, "]")
+	}))
+	defer srv.Close()
+
+	scan := NewClient(ClientConfig{}).scanMarkets(context.Background(), srv.URL+"?limit=100")
+	if scan.Complete {
+		t.Fatal("scan with a failing page must not be complete")
+	}
+	if len(scan.Markets) != discoveryPageSize || scan.Error == "" {
+		t.Errorf("got %d markets, error %q", len(scan.Markets), scan.Error)
+	}
+}
@@ -0,0 +1,339 @@
+// Package fakeserver is an in-process stand-in for the Polymarket gamma REST
+// API and the CLOB market WebSocket. It serves registered markets and replays
+// scripted book/trade frames so pipelines can be tested offline.
+package fakeserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arjunprakash027/Mantis/market"
+	"github.com/gorilla/websocket"
+)
+
+type Market struct {
+	ID           string
+	Slug         string
+	Question     string
+	ConditionID  string
+	NegRisk      bool
+	TickSize     float64
+	MinOrderSize float64
+	EndDate      string
+	Tokens       []market.Token
+
+	event *Event
+}
+
+type Event struct {
+	ID      string
+	Slug    string
+	Title   string
+	NegRisk bool
+	Markets []Market
+}
+
+// Frame is one WebSocket message, sent Delay after the previous one.
+type Frame struct {
+	Delay time.Duration
+	Data  []byte
+}
+
+type Level struct {
+	Price string `json:"price"`
+	Size  string `json:"size"`
+}
+
+type Server struct {
+	srv *httptest.Server
+
+	mu      sync.Mutex
+	markets []Market
+	events  map[string]Event
+	script  []Frame
+	conns   map[*wsConn]bool
+}
+
+type wsConn struct {
+	mu     sync.Mutex
+	conn   *websocket.Conn
+	assets map[string]bool
+}
+
+var upgrader = websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+
+func New() *Server {
+	s := &Server{
+		events: make(map[string]Event),
+		conns:  make(map[*wsConn]bool),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/markets/slug/", s.handleMarketSlug)
+	mux.HandleFunc("/events/slug/", s.handleEventSlug)
+	mux.HandleFunc("/markets", s.handleMarkets)
+	mux.HandleFunc("/ws/market", s.handleWS)
+	s.srv = httptest.NewServer(mux)
+	return s
+}
+
+func (s *Server) Close() {
+	s.mu.Lock()
+	for c := range s.conns {
+		c.conn.Close()
+	}
+	s.mu.Unlock()
+	s.srv.Close()
+}
+
+// DropConnections closes every live WebSocket, as a server restart would.
+func (s *Server) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.conn.Close()
+		delete(s.conns, c)
+	}
+}
+
+func (s *Server) GammaURL() string { return s.srv.URL }
+
+func (s *Server) WSURL() string {
+	return "ws" + strings.TrimPrefix(s.srv.URL, "http") + "/ws/market"
+}
+
+// Client returns a market.Client pointed at this server.
+func (s *Server) Client() *market.Client {
+	return market.NewClient(market.ClientConfig{GammaURL: s.GammaURL(), ClobWSURL: s.WSURL()})
+}
+
+func (s *Server) AddMarket(m Market) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range m.Tokens {
+		m.Tokens[i].Market = m.Question
+	}
+	s.markets = append(s.markets, m)
+}
+
+func (s *Server) AddEvent(e Event) {
+	s.mu.Lock()
+	s.events[e.Slug] = e
+	s.mu.Unlock()
+	for _, m := range e.Markets {
+		m.event = &e
+		s.AddMarket(m)
+	}
+}
+
+// Script sets the frames replayed to every new subscription. Frames that
+// carry an asset_id are only sent to connections subscribed to that asset.
+func (s *Server) Script(frames ...Frame) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.script = frames
+}
+
+// Push sends a frame immediately to every matching live connection.
+func (s *Server) Push(f Frame) {
+	s.mu.Lock()
+	conns := make([]*wsConn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	for _, c := range conns {
+		c.send(f.Data)
+	}
+}
+
+// Book builds a "book" snapshot frame. Levels are sent as given; Polymarket
+// orders bids and asks so the best price is last.
+func Book(asset string, bids, asks []Level) Frame {
+	data, _ := json.Marshal(map[string]interface{}{
+		"event_type": "book",
+		"asset_id":   asset,
+		"bids":       bids,
+		"asks":       asks,
+		"timestamp":  strconv.FormatInt(time.Now().UnixMilli(), 10),
+	})
+	return Frame{Data: data}
+}
+
+// TickSizeChange builds a "tick_size_change" frame.
+func TickSizeChange(asset, oldTick, newTick string) Frame {
+	data, _ := json.Marshal(map[string]interface{}{
+		"event_type":    "tick_size_change",
+		"asset_id":      asset,
+		"old_tick_size": oldTick,
+		"new_tick_size": newTick,
+		"timestamp":     strconv.FormatInt(time.Now().UnixMilli(), 10),
+	})
+	return Frame{Data: data}
+}
+
+// Trade builds a "last_trade_price" frame.
+func Trade(asset, price, size, side string) Frame {
+	data, _ := json.Marshal(map[string]interface{}{
+		"event_type": "last_trade_price",
+		"asset_id":   asset,
+		"price":      price,
+		"size":       size,
+		"side":       side,
+		"timestamp":  strconv.FormatInt(time.Now().UnixMilli(), 10),
+	})
+	return Frame{Data: data}
+}
+
+func (s *Server) handleMarketSlug(w http.ResponseWriter, r *http.Request) {
+	slug := strings.TrimPrefix(r.URL.Path, "/markets/slug/")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range s.markets {
+		if m.Slug == slug {
+			writeJSON(w, gammaMarket(m))
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+func (s *Server) handleEventSlug(w http.ResponseWriter, r *http.Request) {
+	slug := strings.TrimPrefix(r.URL.Path, "/events/slug/")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.events[slug]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	markets := make([]map[string]interface{}, len(e.Markets))
+	for i, m := range e.Markets {
+		markets[i] = gammaMarket(m)
+	}
+	writeJSON(w, map[string]interface{}{"id": e.ID, "title": e.Title, "slug": e.Slug, "negRisk": e.NegRisk, "markets": markets})
+}
+
+func (s *Server) handleMarkets(w http.ResponseWriter, r *http.Request) {
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = 100
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	page := []map[string]interface{}{}
+	for i := offset; i < len(s.markets) && i < offset+limit; i++ {
+		page = append(page, gammaMarket(s.markets[i]))
+	}
+	writeJSON(w, page)
+}
+
+func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &wsConn{conn: conn, assets: make(map[string]bool)}
+	defer conn.Close()
+
+	subscribed := false
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			s.mu.Lock()
+			delete(s.conns, c)
+			s.mu.Unlock()
+			return
+		}
+		if string(msg) == "PING" {
+			c.send([]byte("PONG"))
+			continue
+		}
+
+		var sub struct {
+			AssetsIDs []string `json:"assets_ids"`
+		}
+		if json.Unmarshal(msg, &sub) != nil {
+			continue
+		}
+		c.mu.Lock()
+		for _, id := range sub.AssetsIDs {
+			c.assets[id] = true
+		}
+		c.mu.Unlock()
+
+		if !subscribed {
+			subscribed = true
+			s.mu.Lock()
+			s.conns[c] = true
+			script := s.script
+			s.mu.Unlock()
+			go c.replay(script)
+		}
+	}
+}
+
+func (c *wsConn) replay(frames []Frame) {
+	for _, f := range frames {
+		if f.Delay > 0 {
+			time.Sleep(f.Delay)
+		}
+		if !c.send(f.Data) {
+			return
+		}
+	}
+}
+
+// send writes data if the connection is subscribed to its asset. It returns
+// false once the connection is unusable.
+func (c *wsConn) send(data []byte) bool {
+	var routed struct {
+		AssetID string `json:"asset_id"`
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if json.Unmarshal(data, &routed) == nil && routed.AssetID != "" && !c.assets[routed.AssetID] {
+		return true
+	}
+	return c.conn.WriteMessage(websocket.TextMessage, data) == nil
+}
+
+func gammaMarket(m Market) map[string]interface{} {
+	ids := make([]string, len(m.Tokens))
+	outcomes := make([]string, len(m.Tokens))
+	for i, t := range m.Tokens {
+		ids[i] = t.TokenID
+		outcomes[i] = t.Outcome
+	}
+	idsJSON, _ := json.Marshal(ids)
+	outcomesJSON, _ := json.Marshal(outcomes)
+	out := map[string]interface{}{
+		"id":                    m.ID,
+		"slug":                  m.Slug,
+		"question":              m.Question,
+		"conditionId":           m.ConditionID,
+		"negRisk":               m.NegRisk,
+		"orderPriceMinTickSize": m.TickSize,
+		"orderMinSize":          m.MinOrderSize,
+		"endDate":               m.EndDate,
+		"clobTokenIds":          string(idsJSON),
+		"outcomes":              string(outcomesJSON),
+		"liquidity":             "0",
+		"volume":                "0",
+	}
+	if m.event != nil {
+		out["events"] = []map[string]interface{}{{"id": m.event.ID, "title": m.event.Title, "slug": m.event.Slug}}
+	}
+	return out
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
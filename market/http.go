@@ -0,0 +1,159 @@
+package market
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	maxAttempts  = 4
+	maxBackoff   = 30 * time.Second
+	requestGap   = 100 * time.Millisecond
+	requestLimit = 15 * time.Second
+)
+
+var baseBackoff = 500 * time.Millisecond
+
+// StatusError is returned for non-200 responses that were not retried (or ran
+// out of retries).
+type StatusError struct {
+	URL    string
+	Status int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("GET %s: status %d", e.URL, e.Status)
+}
+
+// rateLimiter spaces requests by a fixed gap and can be pushed back when the
+// server asks us to slow down (429 / Retry-After).
+type rateLimiter struct {
+	mu   sync.Mutex
+	gap  time.Duration
+	next time.Time
+}
+
+func (r *rateLimiter) Wait(ctx context.Context) error {
+	r.mu.Lock()
+	now := time.Now()
+	at := r.next
+	if at.Before(now) {
+		at = now
+	}
+	r.next = at.Add(r.gap)
+	r.mu.Unlock()
+
+	if d := time.Until(at); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+	return nil
+}
+
+func (r *rateLimiter) Backoff(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if until := time.Now().Add(d); until.After(r.next) {
+		r.next = until
+	}
+}
+
+// getJSON fetches url and decodes the body into v, retrying network errors,
+// 5xx and 429 responses with exponential backoff.
+func (c *Client) getJSON(ctx context.Context, url string, v interface{}) error {
+	var lastErr error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			if err := sleepCtx(ctx, backoff(attempt)); err != nil {
+				return err
+			}
+		}
+		if err := c.limiter.Wait(ctx); err != nil {
+			return err
+		}
+
+		retry, err := c.getOnce(ctx, url, v)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry || ctx.Err() != nil {
+			return err
+		}
+	}
+	return lastErr
+}
+
+func (c *Client) getOnce(ctx context.Context, url string, v interface{}) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return true, fmt.Errorf("decode %s: %w", url, err)
+		}
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests:
+		if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			c.limiter.Backoff(d)
+		} else {
+			c.limiter.Backoff(backoff(1))
+		}
+		return true, &StatusError{URL: url, Status: resp.StatusCode}
+	case resp.StatusCode >= 500:
+		return true, &StatusError{URL: url, Status: resp.StatusCode}
+	default:
+		return false, &StatusError{URL: url, Status: resp.StatusCode}
+	}
+}
+
+func retryAfter(h string) (time.Duration, bool) {
+	if h == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(h); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(h); err == nil {
+		return time.Until(at), true
+	}
+	return 0, false
+}
+
+func backoff(attempt int) time.Duration {
+	d := baseBackoff << (attempt - 1)
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -2,84 +2,177 @@ package market
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"sync"
 	"time"
 
+	"github.com/arjunprakash027/Mantis/pkg/logging"
+	"github.com/arjunprakash027/Mantis/pkg/metrics"
 	"github.com/gorilla/websocket"
 )
 
-func StartOrderBookStream(ctx context.Context, assetIds []string, msgChan chan<- []byte) error {
-	wsURL := "wss://ws-subscriptions-clob.polymarket.com/ws/market"
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
 
-	go func() {
+// StreamStatus reports whether an orderbook stream currently has a live
+// connection.
+type StreamStatus struct {
+	mu        sync.Mutex
+	connected bool
+	since     time.Time
+	lastErr   string
+}
 
-		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
-		if err != nil {
+// Connected returns the connection state, when it last changed and the error
+// that dropped the previous connection, if any.
+func (s *StreamStatus) Connected() (bool, time.Time, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connected, s.since, s.lastErr
+}
+
+func (s *StreamStatus) set(connected bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.connected != connected || s.since.IsZero() {
+		s.since = time.Now()
+	}
+	s.connected = connected
+	if err != nil {
+		s.lastErr = err.Error()
+	}
+}
+
+func StartOrderBookStream(ctx context.Context, assetIds []string, msgChan chan<- []byte) (*StreamStatus, error) {
+	return DefaultClient.StartOrderBookStream(ctx, assetIds, msgChan)
+}
+
+// StartOrderBookStream streams book frames for assetIds into msgChan,
+// reconnecting with backoff whenever the socket drops. msgChan is closed once
+// ctx is cancelled.
+func (c *Client) StartOrderBookStream(ctx context.Context, assetIds []string, msgChan chan<- []byte) (*StreamStatus, error) {
+	status := &StreamStatus{}
+	status.set(false, nil)
+
+	logger := logging.FromContext(ctx).With("component", "market")
 
+	go func() {
+		defer close(msgChan)
+
+		delay := minReconnectDelay
+		for attempt := 0; ; attempt++ {
+			if attempt > 0 {
+				metrics.WSReconnects.Inc()
+			}
+			received := c.runOrderBookConn(ctx, logger, assetIds, msgChan, status)
 			if ctx.Err() != nil {
-				log.Printf("WebSocket Stream Stopped by Context")
+				logger.Info("websocket stream stopped")
 				return
-			} else {
-				log.Printf("WebSocket Dial Error: %v", err)
+			}
+			if received {
+				delay = minReconnectDelay
+			}
+			logger.Warn("websocket reconnecting", "delay", delay, "attempt", attempt+1)
+			select {
+			case <-ctx.Done():
 				return
+			case <-time.After(delay):
 			}
+			delay = min(delay*2, maxReconnectDelay)
 		}
+	}()
 
-		defer conn.Close()
-
-		var mu sync.Mutex
+	return status, nil
+}
 
-		subMsg := map[string]interface{}{
-			"type":       "market",
-			"assets_ids": assetIds,
+// runOrderBookConn runs one connection until it fails or ctx is cancelled,
+// reporting whether any frame was received.
+func (c *Client) runOrderBookConn(ctx context.Context, logger *slog.Logger, assetIds []string, msgChan chan<- []byte, status *StreamStatus) bool {
+	conn, _, err := c.dialer.DialContext(ctx, c.clobWSURL, nil)
+	if err != nil {
+		metrics.WSConnects.WithLabelValues("error").Inc()
+		status.set(false, err)
+		if ctx.Err() == nil {
+			logger.Error("websocket dial failed", "err", err)
 		}
+		return false
+	}
+	metrics.WSConnects.WithLabelValues("ok").Inc()
+	logger.Info("websocket connected", "assets", len(assetIds))
 
-		mu.Lock()
-		if err := conn.WriteJSON(subMsg); err != nil {
-			log.Printf("WebSocket Sub Error: %v", err)
-			return
-		}
-		mu.Unlock()
-
-		// Pinging the API with PING to let it know we are still listening
-		go func() {
-			ticker := time.NewTicker(20 * time.Second)
-			defer ticker.Stop()
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case <-ticker.C:
-					mu.Lock()
-					if err := conn.WriteMessage(websocket.TextMessage, []byte("PING")); err != nil {
-						log.Printf("WebSocket Sub Error: %v", err)
-						mu.Unlock()
-						return
-					}
-					mu.Unlock()
-				}
-			}
-		}()
+	done := make(chan struct{})
+	defer close(done)
+	defer conn.Close()
 
-		go func() {
-			<-ctx.Done()
-			conn.Close()
-		}()
+	var mu sync.Mutex
+
+	// The custom features include market_resolved, which settles positions.
+	subMsg := map[string]interface{}{
+		"type":                   "market",
+		"assets_ids":             assetIds,
+		"custom_feature_enabled": true,
+	}
 
+	if err := conn.WriteJSON(subMsg); err != nil {
+		logger.Error("websocket subscribe failed", "err", err)
+		status.set(false, err)
+		return false
+	}
+	status.set(true, nil)
+
+	// Pinging the API with PING to let it know we are still listening
+	go func() {
+		ticker := time.NewTicker(20 * time.Second)
+		defer ticker.Stop()
 		for {
-			_, message, err := conn.ReadMessage()
-			if err != nil {
-				if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
-					log.Printf("WebSocket Closed Gracefully")
-				} else {
-					log.Printf("WebSocket CRASHED: %v", err)
-				}
+			select {
+			case <-done:
 				return
+			case <-ticker.C:
+				mu.Lock()
+				if err := conn.WriteMessage(websocket.TextMessage, []byte("PING")); err != nil {
+					logger.Warn("websocket ping failed", "err", err)
+					mu.Unlock()
+					return
+				}
+				mu.Unlock()
 			}
-			msgChan <- message
 		}
 	}()
 
-	return nil
+	// On shutdown, say goodbye with a close frame before dropping the socket.
+	go func() {
+		select {
+		case <-ctx.Done():
+			mu.Lock()
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+				time.Now().Add(time.Second))
+			mu.Unlock()
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	received := false
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			status.set(false, err)
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logger.Info("websocket closed by server")
+			} else if ctx.Err() == nil {
+				logger.Error("websocket read failed", "err", err)
+			}
+			return received
+		}
+		received = true
+		select {
+		case msgChan <- message:
+		case <-ctx.Done():
+			return received
+		}
+	}
 }
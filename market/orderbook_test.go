@@ -0,0 +1,46 @@
+package market_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/arjunprakash027/Mantis/market/fakeserver"
+)
+
+func TestOrderBookStreamReconnects(t *testing.T) {
+	poly := fakeserver.New()
+	defer poly.Close()
+	poly.Script(fakeserver.Book("tok", []fakeserver.Level{{Price: "0.40", Size: "10"}}, nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	msgChan := make(chan []byte, 10)
+	status, err := poly.Client().StartOrderBookStream(ctx, []string{"tok"}, msgChan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := func() {
+		select {
+		case <-msgChan:
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for a frame")
+		}
+	}
+	next()
+	if ok, _, _ := status.Connected(); !ok {
+		t.Fatal("stream should report connected after the first frame")
+	}
+	poly.DropConnections()
+	// The script is replayed to the new subscription.
+	next()
+	if ok, _, lastErr := status.Connected(); !ok || lastErr == "" {
+		t.Fatalf("after reconnect: connected=%v lastErr=%q", ok, lastErr)
+	}
+
+	cancel()
+	for range msgChan {
+	}
+}
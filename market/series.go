@@ -0,0 +1,112 @@
+package market
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Series is a recurring market (hourly "up or down" etc.) whose slug is
+// rendered from the start time of each instance.
+//
+// Supported placeholders: {month} {mon} {day} {year} {hour} {hour24}
+// {minute} {ampm}. Month names are lower-case, hours are not zero padded.
+type Series struct {
+	Name     string
+	Template string
+	Location *time.Location
+	Interval time.Duration
+	Lead     time.Duration
+	Linger   time.Duration
+}
+
+type SeriesInstance struct {
+	Slug  string
+	Start time.Time
+	End   time.Time
+}
+
+func NewSeries(name, template, timezone string, interval, lead, linger time.Duration) (*Series, error) {
+	if template == "" {
+		return nil, fmt.Errorf("series %s: empty template", name)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("series %s: interval must be positive", name)
+	}
+	loc := time.UTC
+	if timezone != "" {
+		l, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("series %s: %w", name, err)
+		}
+		loc = l
+	}
+	return &Series{
+		Name:     name,
+		Template: template,
+		Location: loc,
+		Interval: interval,
+		Lead:     lead,
+		Linger:   linger,
+	}, nil
+}
+
+// InstanceAt returns the instance whose trading window contains t.
+// Windows are aligned to local midnight in the series timezone.
+func (s *Series) InstanceAt(t time.Time) SeriesInstance {
+	local := t.In(s.Location)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, s.Location)
+	elapsed := local.Sub(midnight)
+	start := midnight.Add(elapsed - elapsed%s.Interval)
+	return s.instance(start)
+}
+
+// Wanted returns every instance that should be subscribed at t: the live one,
+// any upcoming one inside the lead window, and the previous one while it is
+// still lingering for resolution.
+func (s *Series) Wanted(t time.Time) []SeriesInstance {
+	cur := s.InstanceAt(t)
+	out := []SeriesInstance{cur}
+
+	if prev := s.InstanceAt(cur.Start.Add(-time.Nanosecond)); t.Before(prev.End.Add(s.Linger)) {
+		out = append([]SeriesInstance{prev}, out...)
+	}
+	if next := s.InstanceAt(cur.End); !t.Before(next.Start.Add(-s.Lead)) {
+		out = append(out, next)
+	}
+	return out
+}
+
+func (s *Series) Render(start time.Time) string {
+	local := start.In(s.Location)
+	hour12 := local.Hour() % 12
+	if hour12 == 0 {
+		hour12 = 12
+	}
+	ampm := "am"
+	if local.Hour() >= 12 {
+		ampm = "pm"
+	}
+	month := strings.ToLower(local.Month().String())
+
+	r := strings.NewReplacer(
+		"{month}", month,
+		"{mon}", month[:3],
+		"{day}", strconv.Itoa(local.Day()),
+		"{year}", strconv.Itoa(local.Year()),
+		"{hour}", strconv.Itoa(hour12),
+		"{hour24}", strconv.Itoa(local.Hour()),
+		"{minute}", fmt.Sprintf("%02d", local.Minute()),
+		"{ampm}", ampm,
+	)
+	return r.Replace(s.Template)
+}
+
+func (s *Series) instance(start time.Time) SeriesInstance {
+	return SeriesInstance{
+		Slug:  s.Render(start),
+		Start: start,
+		End:   start.Add(s.Interval),
+	}
+}
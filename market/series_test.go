@@ -0,0 +1,42 @@
+package market
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSeriesRender(t *testing.T) {
+	s, err := NewSeries("xrp", "xrp-up-or-down-{month}-{day}-{year}-{hour}{ampm}-et", "America/New_York", time.Hour, 5*time.Minute, 5*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 20:30 UTC on March 19 2026 is 4:30pm EDT.
+	now := time.Date(2026, 3, 19, 20, 30, 0, 0, time.UTC)
+	inst := s.InstanceAt(now)
+	if inst.Slug != "xrp-up-or-down-march-19-2026-4pm-et" {
+		t.Errorf("unexpected slug %s", inst.Slug)
+	}
+	if inst.End.Sub(inst.Start) != time.Hour {
+		t.Errorf("unexpected window %s - %s", inst.Start, inst.End)
+	}
+}
+
+func TestSeriesWanted(t *testing.T) {
+	s, _ := NewSeries("xrp", "xrp-{hour}{ampm}", "UTC", time.Hour, 5*time.Minute, 5*time.Minute)
+
+	mid := s.Wanted(time.Date(2026, 3, 19, 12, 30, 0, 0, time.UTC))
+	if len(mid) != 1 || mid[0].Slug != "xrp-12pm" {
+		t.Errorf("mid-hour: got %+v", mid)
+	}
+
+	early := s.Wanted(time.Date(2026, 3, 19, 12, 2, 0, 0, time.UTC))
+	if len(early) != 2 || early[0].Slug != "xrp-11am" {
+		t.Errorf("linger window: got %+v", early)
+	}
+
+	late := s.Wanted(time.Date(2026, 3, 19, 12, 56, 0, 0, time.UTC))
+	if len(late) != 2 || late[1].Slug != "xrp-1pm" {
+		t.Errorf("lead window: got %+v", late)
+	}
+}
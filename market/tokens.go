@@ -1,75 +1,96 @@
 package market
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
+
+	"github.com/arjunprakash027/Mantis/pkg/wire"
 )
 
-type Token struct {
-	TokenID string `json:"token_id"`
-	Outcome string `json:"outcome"`
-	Market  string `json:"market"`
-}
+// Token and its status are shared with the client SDK.
+type (
+	MarketStatus = wire.MarketStatus
+	Token        = wire.Token
+)
+
+const (
+	StatusActive   = wire.StatusActive
+	StatusPaused   = wire.StatusPaused
+	StatusClosed   = wire.StatusClosed
+	StatusResolved = wire.StatusResolved
+)
 
 type MarketInfo struct {
 	Tokens   []Token `json:"tokens"`
 	Question string  `json:"question"`
 }
 
-func GetTokens(slug string) ([]Token, string, error) {
-	MarketUrl := fmt.Sprintf("https://gamma-api.polymarket.com/markets/slug/%s", slug)
-	resp, err := http.Get(MarketUrl)
-	if err == nil && resp.StatusCode == http.StatusOK {
-
-		var m struct {
-			Question     string `json:"question"`
-			ClobTokenIds string `json:"clobTokenIds"`
-			Outcomes     string `json:"outcomes"`
-		}
+// gammaMarket is the subset of a gamma /markets object Mantis cares about.
+type gammaMarket struct {
+	Question     string  `json:"question"`
+	Slug         string  `json:"slug"`
+	ConditionID  string  `json:"conditionId"`
+	ClobTokenIds string  `json:"clobTokenIds"`
+	Outcomes     string  `json:"outcomes"`
+	NegRisk      bool    `json:"negRisk"`
+	TickSize     float64 `json:"orderPriceMinTickSize"`
+	MinOrderSize float64 `json:"orderMinSize"`
+	EndDate      string  `json:"endDate"`
+	Active       *bool   `json:"active"`
+	Closed       bool    `json:"closed"`
+	Accepting    *bool   `json:"acceptingOrders"`
+	Resolution   string  `json:"umaResolutionStatus"`
+	Events       []struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+	} `json:"events"`
+}
 
-		json.NewDecoder(resp.Body).Decode(&m)
-		resp.Body.Close()
+type gammaEvent struct {
+	ID      string        `json:"id"`
+	Title   string        `json:"title"`
+	NegRisk bool          `json:"negRisk"`
+	Markets []gammaMarket `json:"markets"`
+}
 
+func GetTokens(slug string) ([]Token, string, error) {
+	return DefaultClient.GetTokens(context.Background(), slug)
+}
+
+func (c *Client) GetTokens(ctx context.Context, slug string) ([]Token, string, error) {
+	MarketUrl := fmt.Sprintf("%s/markets/slug/%s", c.gammaURL, slug)
+	var m gammaMarket
+	if err := c.getJSON(ctx, MarketUrl, &m); err == nil {
 		if m.ClobTokenIds != "" && m.ClobTokenIds != "[]" {
-			return parseTokens(m.ClobTokenIds, m.Outcomes, m.Question), m.Question, nil
+			ev := gammaEvent{}
+			if len(m.Events) > 0 {
+				ev.ID, ev.Title = m.Events[0].ID, m.Events[0].Title
+			}
+			return parseTokens(m, ev), m.Question, nil
 		}
 	}
 
-	eventURL := fmt.Sprintf("https://gamma-api.polymarket.com/events/slug/%s", slug)
-	resp, err = http.Get(eventURL)
-	if err != nil || resp.StatusCode != http.StatusOK {
-		return nil, "", fmt.Errorf("event not found")
-	}
-
-	defer resp.Body.Close()
-
-	var event struct {
-		Title   string `json:"title"`
-		Markets []struct {
-			Question     string `json:"question"`
-			ClobTokenIds string `json:"clobTokenIds"`
-			Outcomes     string `json:"outcomes"`
-		} `json:"markets"`
-	}
+	eventURL := fmt.Sprintf("%s/events/slug/%s", c.gammaURL, slug)
 
-	if err := json.NewDecoder(resp.Body).Decode(&event); err != nil {
-		return nil, "", err
+	var event gammaEvent
+	if err := c.getJSON(ctx, eventURL, &event); err != nil {
+		return nil, "", fmt.Errorf("event not found: %w", err)
 	}
 
 	var allTokens []Token
 	for _, m := range event.Markets {
-		tokens := parseTokens(m.ClobTokenIds, m.Outcomes, m.Question)
+		tokens := parseTokens(m, event)
 		allTokens = append(allTokens, tokens...)
 	}
 
 	return allTokens, event.Title, nil
 }
 
-func parseTokens(idsStr, nameStr string, marketName string) []Token {
+func parseTokens(m gammaMarket, ev gammaEvent) []Token {
 	var ids, names []string
-	json.Unmarshal([]byte(idsStr), &ids)
-	json.Unmarshal([]byte(nameStr), &names)
+	json.Unmarshal([]byte(m.ClobTokenIds), &ids)
+	json.Unmarshal([]byte(m.Outcomes), &names)
 
 	tokens := make([]Token, 0, len(ids))
 	for i, id := range ids {
@@ -78,10 +99,38 @@ func parseTokens(idsStr, nameStr string, marketName string) []Token {
 			name = names[i]
 		}
 		tokens = append(tokens, Token{
-			TokenID: id,
-			Outcome: name,
-			Market:  marketName,
+			TokenID:      id,
+			Outcome:      name,
+			Market:       m.Question,
+			MarketSlug:   m.Slug,
+			ConditionID:  m.ConditionID,
+			EventID:      ev.ID,
+			EventTitle:   ev.Title,
+			NegRisk:      m.NegRisk || ev.NegRisk,
+			TickSize:     m.TickSize,
+			MinOrderSize: m.MinOrderSize,
+			EndDate:      m.EndDate,
+			Status:       m.status(),
 		})
 	}
+
+	// Binary markets: each outcome's sibling is the complementary token.
+	if len(tokens) == 2 {
+		tokens[0].Sibling = tokens[1].TokenID
+		tokens[1].Sibling = tokens[0].TokenID
+	}
 	return tokens
 }
+
+func (m gammaMarket) status() MarketStatus {
+	switch {
+	case m.Resolution == "resolved":
+		return StatusResolved
+	case m.Closed:
+		return StatusClosed
+	case m.Active != nil && !*m.Active, m.Accepting != nil && !*m.Accepting:
+		return StatusPaused
+	default:
+		return StatusActive
+	}
+}
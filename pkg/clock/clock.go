@@ -0,0 +1,48 @@
+// Package clock lets the engine and executor run on wall time in production
+// and on simulated time in backtests.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+type Clock interface {
+	Now() time.Time
+}
+
+// Real is the wall clock.
+type Real struct{}
+
+func (Real) Now() time.Time { return time.Now() }
+
+// Sim is a manually driven clock. It never moves on its own.
+type Sim struct {
+	mu  sync.RWMutex
+	now time.Time
+}
+
+func NewSim(start time.Time) *Sim {
+	return &Sim{now: start}
+}
+
+func (s *Sim) Now() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.now
+}
+
+// Set moves the clock to t. Time never goes backwards.
+func (s *Sim) Set(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t.After(s.now) {
+		s.now = t
+	}
+}
+
+func (s *Sim) Advance(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = s.now.Add(d)
+}
@@ -0,0 +1,97 @@
+// Package logging configures the process-wide slog logger: level, text or
+// JSON output, and optional sampling of repeated messages.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Options mirror the logging section of config.yaml.
+type Options struct {
+	Level  string
+	Format string
+	// SampleInitial messages with the same level and text are logged per
+	// SampleInterval, then one in every SampleThereafter. Zero disables
+	// sampling.
+	SampleInitial    int
+	SampleThereafter int
+	SampleInterval   time.Duration
+}
+
+var level = new(slog.LevelVar)
+
+// Setup installs the default logger. The standard library log package is
+// routed through it as well.
+func Setup(w io.Writer, o Options) error {
+	if err := SetLevel(o.Level); err != nil {
+		return err
+	}
+
+	hopts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch strings.ToLower(o.Format) {
+	case "", "text":
+		h = slog.NewTextHandler(w, hopts)
+	case "json":
+		h = slog.NewJSONHandler(w, hopts)
+	default:
+		return fmt.Errorf("unknown log format %q", o.Format)
+	}
+
+	if o.SampleInitial > 0 {
+		h = newSampler(h, o.SampleInitial, o.SampleThereafter, o.SampleInterval)
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+// SetLevel changes the level of the installed logger; safe to call while
+// running.
+func SetLevel(s string) error {
+	l, err := ParseLevel(s)
+	if err != nil {
+		return err
+	}
+	level.Set(l)
+	return nil
+}
+
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+// For returns the default logger tagged with a component name.
+func For(component string) *slog.Logger {
+	return slog.Default().With("component", component)
+}
+
+type ctxKey struct{}
+
+// WithLogger attaches a logger to ctx so code further down, such as a
+// WebSocket stream, logs with the caller's attributes (slug, series, ...).
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext returns the logger attached to ctx, or the default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetupJSONWithLevel(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Setup(&buf, Options{Level: "warn", Format: "json"}); err != nil {
+		t.Fatal(err)
+	}
+	For("executor").Info("dropped")
+	For("executor").Warn("kept", "asset_id", "tok")
+
+	var rec map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("expected exactly one JSON record, got %q: %v", buf.String(), err)
+	}
+	if rec["msg"] != "kept" || rec["component"] != "executor" || rec["asset_id"] != "tok" {
+		t.Fatalf("unexpected record %v", rec)
+	}
+
+	if err := Setup(&buf, Options{Format: "xml"}); err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+}
+
+func TestSampler(t *testing.T) {
+	var buf bytes.Buffer
+	h := newSampler(slog.NewTextHandler(&buf, nil), 2, 3, time.Minute)
+	l := slog.New(h).With("component", "streamer")
+
+	for i := 0; i < 11; i++ {
+		l.Info("frame routed")
+	}
+	l.Info("other")
+
+	// 2 initial, then the 3rd, 6th and 9th of the remaining 9, plus "other".
+	if got := strings.Count(buf.String(), "\n"); got != 6 {
+		t.Fatalf("expected 6 lines, got %d:\n%s", got, buf.String())
+	}
+}
+
+func TestSamplerKeepsWarnings(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(newSampler(slog.NewTextHandler(&buf, nil), 1, 100, time.Minute))
+
+	for i := 0; i < 5; i++ {
+		l.Warn("redis not reachable")
+		l.Error("stream start failed")
+	}
+	if got := strings.Count(buf.String(), "\n"); got != 10 {
+		t.Fatalf("expected 10 lines, got %d:\n%s", got, buf.String())
+	}
+}
@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// sampler drops repeats of the same level and message once more than initial
+// have been logged in the current interval, keeping one in every thereafter.
+type sampler struct {
+	next       slog.Handler
+	initial    int
+	thereafter int
+	interval   time.Duration
+	state      *sampleState // shared with With/WithGroup copies
+}
+
+type sampleState struct {
+	mu     sync.Mutex
+	counts map[sampleKey]*sampleCount
+}
+
+type sampleKey struct {
+	level slog.Level
+	msg   string
+}
+
+type sampleCount struct {
+	window time.Time
+	n      int
+}
+
+func newSampler(next slog.Handler, initial, thereafter int, interval time.Duration) *sampler {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &sampler{
+		next:       next,
+		initial:    initial,
+		thereafter: thereafter,
+		interval:   interval,
+		state:      &sampleState{counts: make(map[sampleKey]*sampleCount)},
+	}
+}
+
+func (s *sampler) Enabled(ctx context.Context, l slog.Level) bool {
+	return s.next.Enabled(ctx, l)
+}
+
+func (s *sampler) Handle(ctx context.Context, r slog.Record) error {
+	if !s.keep(r) {
+		return nil
+	}
+	return s.next.Handle(ctx, r)
+}
+
+func (s *sampler) keep(r slog.Record) bool {
+	if r.Level >= slog.LevelWarn {
+		return true
+	}
+	now := r.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	key := sampleKey{level: r.Level, msg: r.Message}
+
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+	c, ok := s.state.counts[key]
+	if !ok || now.Sub(c.window) >= s.interval {
+		// Bound memory if messages are unexpectedly high-cardinality.
+		if len(s.state.counts) > 10000 {
+			clear(s.state.counts)
+		}
+		c = &sampleCount{window: now}
+		s.state.counts[key] = c
+	}
+	c.n++
+	if c.n <= s.initial {
+		return true
+	}
+	return s.thereafter > 0 && (c.n-s.initial)%s.thereafter == 0
+}
+
+func (s *sampler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *s
+	c.next = s.next.WithAttrs(attrs)
+	return &c
+}
+
+func (s *sampler) WithGroup(name string) slog.Handler {
+	c := *s
+	c.next = s.next.WithGroup(name)
+	return &c
+}
@@ -0,0 +1,179 @@
+// Package metrics holds the Prometheus collectors shared by the ingest,
+// routing and execution paths, and serves them on /metrics.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var Registry = prometheus.NewRegistry()
+
+var (
+	MessagesReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mantis_messages_received_total",
+		Help: "Market data events received, by namespace and asset.",
+	}, []string{"namespace", "asset"})
+
+	WSConnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mantis_ws_connects_total",
+		Help: "WebSocket connection attempts, by result (ok, error).",
+	}, []string{"result"})
+
+	WSReconnects = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "mantis_ws_reconnects_total",
+		Help: "WebSocket reconnects after a dropped connection.",
+	})
+
+	DecodeFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mantis_decode_failures_total",
+		Help: "Frames that could not be decoded, by namespace.",
+	}, []string{"namespace"})
+
+	RedisXAddSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mantis_redis_xadd_seconds",
+		Help:    "Latency of stream writes to Redis, by namespace.",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 2, 14),
+	}, []string{"namespace"})
+
+	RedisXAddErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mantis_redis_xadd_errors_total",
+		Help: "Failed stream writes to Redis, by namespace.",
+	}, []string{"namespace"})
+
+	WriterQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "mantis_writer_queue_depth",
+		Help: "Stream writes waiting in the async writer queue.",
+	})
+
+	WriterDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mantis_writer_dropped_total",
+		Help: "Stream writes dropped because the writer queue was full, by namespace.",
+	}, []string{"namespace"})
+
+	WriterBlockedSeconds = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "mantis_writer_blocked_seconds_total",
+		Help: "Time ingest spent waiting for room in a full writer queue.",
+	})
+
+	WriterBatchSize = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "mantis_writer_batch_size",
+		Help:    "Stream writes sent per pipeline flush.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+	})
+
+	WriterFlushSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "mantis_writer_flush_seconds",
+		Help:    "Latency of one pipelined flush to Redis.",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 2, 14),
+	})
+
+	Signals = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mantis_signals_total",
+		Help: "Signals processed by the executor, by result (filled, rejected, invalid) and reason code.",
+	}, []string{"result", "reason"})
+
+	ExecutionSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "mantis_execution_seconds",
+		Help:    "Time spent executing a signal, from decode to result.",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 2, 14),
+	})
+
+	LedgerEntries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mantis_ledger_entries_total",
+		Help: "Entries written to the SQL ledger, by kind (fill, deposit, withdrawal, reset).",
+	}, []string{"kind"})
+
+	LedgerMismatches = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "mantis_ledger_reconcile_mismatches",
+		Help: "Balances that differed between the SQL ledger and Redis at the last reconciliation.",
+	})
+
+	ArbEdge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mantis_arb_edge",
+		Help: "Best net edge per $1 payout, after fees, across groups of buying (buy_all) or selling (sell_all) every outcome.",
+	}, []string{"direction"})
+
+	ArbOpportunities = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mantis_arb_opportunities_total",
+		Help: "Times a group's net edge rose above the arb threshold, by direction.",
+	}, []string{"direction"})
+
+	StrategyDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mantis_strategy_dropped_total",
+		Help: "Events dropped because a strategy's queue was full, by strategy.",
+	}, []string{"strategy"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		MessagesReceived, WSConnects, WSReconnects, DecodeFailures,
+		RedisXAddSeconds, RedisXAddErrors, Signals, ExecutionSeconds,
+		WriterQueueDepth, WriterDropped, WriterBlockedSeconds, WriterBatchSize, WriterFlushSeconds,
+		LedgerEntries, LedgerMismatches,
+		ArbEdge, ArbOpportunities,
+		StrategyDropped,
+	)
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+func Since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
+
+// PriceAge reports when each asset's price last changed.
+type PriceAge func() map[string]time.Time
+
+// Portfolio reports cash, marked equity and per-asset position sizes.
+type Portfolio func() (cash, equity float64, positions map[string]float64, err error)
+
+type liveCollector struct {
+	priceAge  PriceAge
+	portfolio Portfolio
+	now       func() time.Time
+}
+
+var (
+	priceAgeDesc = prometheus.NewDesc("mantis_price_age_seconds", "Seconds since the last price update, by asset.", []string{"asset"}, nil)
+	cashDesc     = prometheus.NewDesc("mantis_portfolio_cash_usd", "USD balance of the paper portfolio.", nil, nil)
+	equityDesc   = prometheus.NewDesc("mantis_portfolio_equity_usd", "Cash plus positions marked at mid.", nil, nil)
+	positionDesc = prometheus.NewDesc("mantis_portfolio_position", "Position size, by asset.", []string{"asset"}, nil)
+)
+
+// RegisterLive adds collectors that are evaluated at scrape time.
+func RegisterLive(priceAge PriceAge, portfolio Portfolio, now func() time.Time) {
+	Registry.MustRegister(&liveCollector{priceAge: priceAge, portfolio: portfolio, now: now})
+}
+
+func (c *liveCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- priceAgeDesc
+	ch <- cashDesc
+	ch <- equityDesc
+	ch <- positionDesc
+}
+
+func (c *liveCollector) Collect(ch chan<- prometheus.Metric) {
+	now := c.now()
+	for asset, at := range c.priceAge() {
+		ch <- prometheus.MustNewConstMetric(priceAgeDesc, prometheus.GaugeValue, now.Sub(at).Seconds(), asset)
+	}
+
+	cash, equity, positions, err := c.portfolio()
+	if err != nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(cashDesc, prometheus.GaugeValue, cash)
+	ch <- prometheus.MustNewConstMetric(equityDesc, prometheus.GaugeValue, equity)
+	for asset, qty := range positions {
+		ch <- prometheus.MustNewConstMetric(positionDesc, prometheus.GaugeValue, qty, asset)
+	}
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandlerExposesLiveGauges(t *testing.T) {
+	now := time.Unix(1000, 0)
+	RegisterLive(
+		func() map[string]time.Time { return map[string]time.Time{"tok": now.Add(-30 * time.Second)} },
+		func() (float64, float64, map[string]float64, error) {
+			return 90, 105, map[string]float64{"tok": 25}, nil
+		},
+		func() time.Time { return now },
+	)
+	Signals.WithLabelValues("rejected", "STALE_PRICE").Inc()
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	for _, want := range []string{
+		`mantis_price_age_seconds{asset="tok"} 30`,
+		`mantis_portfolio_cash_usd 90`,
+		`mantis_portfolio_equity_usd 105`,
+		`mantis_portfolio_position{asset="tok"} 25`,
+		`mantis_signals_total{reason="STALE_PRICE",result="rejected"} 1`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("missing %q in /metrics output", want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// Package redisclient builds the Redis client Mantis and its CLI share.
+package redisclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/arjunprakash027/Mantis/config"
+	"github.com/arjunprakash027/Mantis/pkg/logging"
+	"github.com/redis/go-redis/v9"
+)
+
+// redisLogger routes go-redis's internal messages (pool dial failures and
+// the like) through slog.
+type redisLogger struct{}
+
+func (redisLogger) Printf(ctx context.Context, format string, v ...interface{}) {
+	logging.FromContext(ctx).Warn(fmt.Sprintf(format, v...), "component", "redis")
+}
+
+// New builds a standalone, Sentinel or Cluster client from config.
+func New(cfg config.RedisConfig) (redis.UniversalClient, error) {
+	redis.SetLogger(redisLogger{})
+
+	opts := &redis.UniversalOptions{
+		Addrs:         cfg.Addrs,
+		DB:            cfg.DB,
+		Username:      os.Getenv(cfg.UsernameEnv),
+		Password:      os.Getenv(cfg.PasswordEnv),
+		MasterName:    cfg.SentinelMaster,
+		IsClusterMode: cfg.Cluster,
+	}
+
+	if cfg.Cluster && cfg.SentinelMaster != "" {
+		return nil, fmt.Errorf("redis: cluster and sentinel_master are mutually exclusive")
+	}
+	if cfg.Cluster && cfg.DB != 0 {
+		return nil, fmt.Errorf("redis: cluster mode only supports db 0")
+	}
+
+	if cfg.TLS.Enabled {
+		tlsCfg := &tls.Config{
+			ServerName:         cfg.TLS.ServerName,
+			InsecureSkipVerify: cfg.TLS.InsecureSkipVerify,
+			MinVersion:         tls.VersionTLS12,
+		}
+		if cfg.TLS.CAFile != "" {
+			pem, err := os.ReadFile(cfg.TLS.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("redis: read CA: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("redis: no certificates in %s", cfg.TLS.CAFile)
+			}
+			tlsCfg.RootCAs = pool
+		}
+		opts.TLSConfig = tlsCfg
+	}
+
+	return redis.NewUniversalClient(opts), nil
+}
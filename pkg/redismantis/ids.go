@@ -0,0 +1,13 @@
+package redismantis
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StreamTime is the time encoded in an auto-generated stream entry ID.
+func StreamTime(id string) time.Time {
+	ms, _ := strconv.ParseInt(strings.SplitN(id, "-", 2)[0], 10, 64)
+	return time.UnixMilli(ms)
+}
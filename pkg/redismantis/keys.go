@@ -2,14 +2,22 @@ package redismantis
 
 import "fmt"
 
+// Keys touched together by the trade Lua script share the {mantis} hash tag
+// so they land in one slot on Redis Cluster.
 const (
-	StreamDiscovery = "discovery:stream:all"
-	StreamSignalsInbound  = "signals:inbound"
-	StreamSignalsOutbound = "signals:outbound"
-	HashPortfolioBalance = "portfolio:balance"
-	HashTradeLog = "trade:log"
-	GroupMantisExecutors = "mantis_executors"
-	ConsumerWorker1 = "worker_1"
+	StreamDiscovery        = "discovery:stream:all"
+	StreamSignalsInbound   = "signals:inbound"
+	StreamSignalsOutbound  = "signals:outbound"
+	HashPortfolioBalance   = "{mantis}:portfolio:balance"
+	HashPortfolioCost      = "{mantis}:portfolio:cost"
+	HashTradeLog           = "{mantis}:trade:log"
+	StreamCashLog          = "{mantis}:cash:log"
+	HashFilledSignals      = "{mantis}:signals:filled"
+	ZSetFilledSignals      = "{mantis}:signals:filled:index"
+	StreamArbOpportunities = "arb:opportunities"
+	SetSlugs               = "slugs:all"
+	GroupMantisExecutors   = "mantis_executors"
+	ConsumerWorker1        = "worker_1"
 )
 
 func HashTokenMeta(id string) string {
@@ -20,10 +28,40 @@ func StreamOrderbook(assetID string) string {
 	return fmt.Sprintf("orderbook:stream:%s", assetID)
 }
 
+// StreamMarket carries every event of one market (both outcomes) when
+// market streams are enabled.
+func StreamMarket(conditionID string) string {
+	return StreamNamespaceDynamic("market", conditionID)
+}
+
+// StreamCandles carries closed OHLCV bars, e.g. candles:1m:<asset_id>.
+func StreamCandles(interval, assetID string) string {
+	return fmt.Sprintf("candles:%s:%s", interval, assetID)
+}
+
+// HashFeatures holds an asset's latest microstructure features; their history
+// is on features:stream:<asset_id>.
+func HashFeatures(assetID string) string {
+	return fmt.Sprintf("features:%s", assetID)
+}
+
 func SetSlugAssets(slug string) string {
 	return fmt.Sprintf("slug:assets:%s", slug)
 }
 
 func StreamNamespaceDynamic(namespace string, identifier string) string {
 	return fmt.Sprintf("%s:stream:%s", namespace, identifier)
-}
\ No newline at end of file
+}
+func SetEventAssets(eventID string) string {
+	return fmt.Sprintf("event:assets:%s", eventID)
+}
+
+// Series keys are rewritten in one MULTI, so they share the series name as
+// hash tag.
+func SetSeriesAssets(name string) string {
+	return fmt.Sprintf("series:assets:{%s}", name)
+}
+
+func HashSeriesAlias(name string) string {
+	return fmt.Sprintf("series:alias:{%s}", name)
+}
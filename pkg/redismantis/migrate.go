@@ -0,0 +1,72 @@
+package redismantis
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/arjunprakash027/Mantis/pkg/logging"
+	"github.com/redis/go-redis/v9"
+)
+
+// LegacyKeys maps key names used before the {mantis} hash tag to their
+// current names.
+var LegacyKeys = map[string]string{
+	"portfolio:balance": HashPortfolioBalance,
+	"trade:log":         HashTradeLog,
+}
+
+// MigrateKeys moves every legacy key that still exists to its current name,
+// unless the current key already holds data. It returns the legacy keys it
+// moved. On Redis Cluster the two names hash to different slots, so the key
+// is copied with DUMP/RESTORE instead of RENAME.
+func MigrateKeys(ctx context.Context, rdb redis.UniversalClient) ([]string, error) {
+	var moved []string
+	for old, current := range LegacyKeys {
+		ok, err := rdb.RenameNX(ctx, old, current).Result()
+		switch {
+		case err == nil:
+			if ok {
+				moved = append(moved, old)
+			} else {
+				conflict(old, current)
+			}
+			continue
+		case strings.Contains(err.Error(), "no such key"):
+			continue
+		case !strings.HasPrefix(err.Error(), "CROSSSLOT"):
+			return moved, err
+		}
+
+		ok, err = copyKey(ctx, rdb, old, current)
+		if err != nil {
+			return moved, err
+		}
+		if ok {
+			moved = append(moved, old)
+		}
+	}
+	return moved, nil
+}
+
+func conflict(old, current string) {
+	logging.For("redis").Warn("legacy key left in place because its new name already exists", "key", old, "new_key", current)
+}
+
+func copyKey(ctx context.Context, rdb redis.UniversalClient, from, to string) (bool, error) {
+	dump, err := rdb.Dump(ctx, from).Result()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := rdb.Restore(ctx, to, 0, dump).Err(); err != nil {
+		if strings.HasPrefix(err.Error(), "BUSYKEY") {
+			conflict(from, to)
+			return false, nil
+		}
+		return false, err
+	}
+	return true, rdb.Del(ctx, from).Err()
+}
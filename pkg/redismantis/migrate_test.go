@@ -0,0 +1,40 @@
+package redismantis
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestMigrateKeys(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	ctx := context.Background()
+
+	s.HSet("portfolio:balance", "USD", "500")
+	s.HSet(HashPortfolioBalance, "USD", "1000") // already migrated; left alone
+	rdb.XAdd(ctx, &redis.XAddArgs{Stream: "trade:log", Values: map[string]interface{}{"action": "BUY"}})
+
+	moved, err := MigrateKeys(ctx, rdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moved) != 1 || moved[0] != "trade:log" {
+		t.Errorf("moved %v", moved)
+	}
+	if n, _ := rdb.XLen(ctx, HashTradeLog).Result(); n != 1 {
+		t.Errorf("trade log has %d entries", n)
+	}
+	if got := s.HGet(HashPortfolioBalance, "USD"); got != "1000" {
+		t.Errorf("current balance overwritten: %s", got)
+	}
+	if !s.Exists("portfolio:balance") {
+		t.Error("legacy balance dropped although it was not migrated")
+	}
+
+	if moved, err := MigrateKeys(ctx, rdb); err != nil || len(moved) != 0 {
+		t.Errorf("second run moved %v (%v)", moved, err)
+	}
+}
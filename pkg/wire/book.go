@@ -0,0 +1,152 @@
+package wire
+
+import (
+	"encoding/json"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+// MarketState is an asset's top of book as the executor fills against it.
+type MarketState struct {
+	BestAsk     float64
+	BestBid     float64
+	LastUpdated int64
+}
+
+// OrderbookUpdate is one CLOB event as stored in the orderbook streams.
+type OrderbookUpdate struct {
+	EventType   string       `json:"event_type"`
+	AssetID     string       `json:"asset_id"`
+	NewTickSize string       `json:"new_tick_size"`
+	AssetsIDs   []string     `json:"assets_ids"`
+	Price       string       `json:"price"`
+	Size        string       `json:"size"`
+	Side        string       `json:"side"`
+	Bids        []PriceLevel `json:"bids"`
+	Asks        []PriceLevel `json:"asks"`
+	// WinningAssetID is set on market_resolved events.
+	WinningAssetID string `json:"winning_asset_id,omitempty"`
+}
+
+type PriceLevel struct {
+	Price string `json:"price"`
+	Size  string `json:"size"`
+}
+
+// Depth is an asset's current book in CLOB order (bids ascending, asks
+// descending, best last).
+type Depth struct {
+	Bids []PriceLevel `json:"bids"`
+	Asks []PriceLevel `json:"asks"`
+}
+
+// DecodeUpdates parses a CLOB frame, which is either one event or a batch.
+func DecodeUpdates(rawMsg []byte) ([]OrderbookUpdate, error) {
+	if len(rawMsg) > 0 && rawMsg[0] == '[' {
+		var updates []OrderbookUpdate
+		err := json.Unmarshal(rawMsg, &updates)
+		return updates, err
+	}
+	var single OrderbookUpdate
+	if err := json.Unmarshal(rawMsg, &single); err != nil {
+		return nil, err
+	}
+	return []OrderbookUpdate{single}, nil
+}
+
+// L2Book is a level-2 book rebuilt from snapshots and price changes, keyed by
+// price. A zero size removes the level. It is not safe for concurrent use.
+type L2Book struct {
+	bids, asks map[float64]float64
+}
+
+func NewL2Book() *L2Book {
+	return &L2Book{bids: make(map[float64]float64), asks: make(map[float64]float64)}
+}
+
+// Apply folds an update into the book: a "book" event replaces it, anything
+// else updates the levels it carries.
+func (b *L2Book) Apply(u OrderbookUpdate) {
+	if u.EventType == "book" {
+		clear(b.bids)
+		clear(b.asks)
+	}
+	applyLevels(b.bids, u.Bids)
+	applyLevels(b.asks, u.Asks)
+}
+
+func applyLevels(side map[float64]float64, levels []PriceLevel) {
+	for _, l := range levels {
+		price, err1 := strconv.ParseFloat(l.Price, 64)
+		size, err2 := strconv.ParseFloat(l.Size, 64)
+		switch {
+		case err1 != nil || err2 != nil:
+		case size == 0:
+			delete(side, price)
+		default:
+			side[price] = size
+		}
+	}
+}
+
+// Best returns the highest bid and lowest ask with their sizes; a price is
+// zero when its side is empty.
+func (b *L2Book) Best() (bid, bidSize, ask, askSize float64) {
+	for p, sz := range b.bids {
+		if p > bid {
+			bid, bidSize = p, sz
+		}
+	}
+	for p, sz := range b.asks {
+		if ask == 0 || p < ask {
+			ask, askSize = p, sz
+		}
+	}
+	return bid, bidSize, ask, askSize
+}
+
+// TopSize sums the size of the n best levels of each side.
+func (b *L2Book) TopSize(n int) (bid, ask float64) {
+	return topSize(b.bids, n, true), topSize(b.asks, n, false)
+}
+
+// Depth lists the book in CLOB order: bids ascending, asks descending, best
+// last.
+func (b *L2Book) Depth() Depth {
+	return Depth{Bids: sortedLevels(b.bids, false), Asks: sortedLevels(b.asks, true)}
+}
+
+func sortedLevels(side map[float64]float64, desc bool) []PriceLevel {
+	prices := make([]float64, 0, len(side))
+	for p := range side {
+		prices = append(prices, p)
+	}
+	sort.Float64s(prices)
+	if desc {
+		slices.Reverse(prices)
+	}
+	out := make([]PriceLevel, len(prices))
+	for i, p := range prices {
+		out[i] = PriceLevel{Price: strconv.FormatFloat(p, 'f', -1, 64), Size: strconv.FormatFloat(side[p], 'f', -1, 64)}
+	}
+	return out
+}
+
+func topSize(side map[float64]float64, n int, bids bool) float64 {
+	prices := make([]float64, 0, len(side))
+	for p := range side {
+		prices = append(prices, p)
+	}
+	sort.Float64s(prices)
+	if bids {
+		prices = prices[max(len(prices)-n, 0):]
+	} else {
+		prices = prices[:min(n, len(prices))]
+	}
+	var sum float64
+	for _, p := range prices {
+		sum += side[p]
+	}
+	return sum
+}
@@ -0,0 +1,35 @@
+package wire
+
+import (
+	"fmt"
+	"time"
+)
+
+// Candle is one OHLCV bar. Open, high, low and close track the mid price; a
+// bar opened by a trade before any two-sided book uses the trade price.
+// Volume, Trades, VWAP and Last come from last_trade_price events.
+type Candle struct {
+	AssetID  string  `json:"asset_id"`
+	Interval string  `json:"interval"`
+	Start    int64   `json:"start"` // unix milliseconds
+	Open     float64 `json:"open"`
+	High     float64 `json:"high"`
+	Low      float64 `json:"low"`
+	Close    float64 `json:"close"`
+	Volume   float64 `json:"volume"`
+	Trades   int     `json:"trades"`
+	VWAP     float64 `json:"vwap"`
+	Last     float64 `json:"last"`
+}
+
+// IntervalLabel names an interval the way candle streams do: 1s, 5m, 1h.
+func IntervalLabel(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	default:
+		return fmt.Sprintf("%ds", d/time.Second)
+	}
+}
@@ -0,0 +1,86 @@
+package wire
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"github.com/arjunprakash027/Mantis/pkg/redismantis"
+	"github.com/redis/go-redis/v9"
+)
+
+// Portfolio is the paper balance with every position marked to the cached
+// book.
+type Portfolio struct {
+	Cash      float64            `json:"cash"`
+	Equity    float64            `json:"equity"`
+	PnL       float64            `json:"pnl"`
+	Positions map[string]float64 `json:"-"`
+	Holdings  []Holding          `json:"positions"`
+}
+
+// Holding is one position. CostBasis is what is still invested after partial
+// sells; positions opened before cost tracking existed report zero.
+type Holding struct {
+	Asset     string  `json:"asset_id"`
+	Quantity  float64 `json:"quantity"`
+	Mark      float64 `json:"mark"`
+	Value     float64 `json:"value"`
+	CostBasis float64 `json:"cost_basis"`
+	AvgPrice  float64 `json:"avg_price"`
+	PnL       float64 `json:"pnl"`
+}
+
+// ReadPortfolio loads the paper account and marks each position with prices.
+// Assets prices does not know are marked at zero.
+func ReadPortfolio(ctx context.Context, rdb redis.UniversalClient, prices func(asset string) (MarketState, bool)) (Portfolio, error) {
+	balances, err := rdb.HGetAll(ctx, redismantis.HashPortfolioBalance).Result()
+	if err != nil {
+		return Portfolio{}, err
+	}
+	costs, err := rdb.HGetAll(ctx, redismantis.HashPortfolioCost).Result()
+	if err != nil {
+		return Portfolio{}, err
+	}
+
+	p := Portfolio{Positions: make(map[string]float64), Holdings: []Holding{}}
+	for asset, v := range balances {
+		qty, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			continue
+		}
+		if asset == "USD" {
+			p.Cash = qty
+			p.Equity += qty
+			continue
+		}
+		p.Positions[asset] = qty
+		if qty == 0 {
+			continue
+		}
+
+		state, _ := prices(asset)
+		h := Holding{Asset: asset, Quantity: qty, Mark: Mark(state)}
+		h.Value = qty * h.Mark
+		h.CostBasis, _ = strconv.ParseFloat(costs[asset], 64)
+		h.AvgPrice = h.CostBasis / qty
+		h.PnL = h.Value - h.CostBasis
+		p.Equity += h.Value
+		p.PnL += h.PnL
+		p.Holdings = append(p.Holdings, h)
+	}
+	sort.Slice(p.Holdings, func(i, j int) bool { return p.Holdings[i].Asset < p.Holdings[j].Asset })
+	return p, nil
+}
+
+// Mark values a position at the mid, or the one-sided quote.
+func Mark(s MarketState) float64 {
+	switch {
+	case s.BestBid > 0 && s.BestAsk > 0:
+		return (s.BestBid + s.BestAsk) / 2
+	case s.BestBid > 0:
+		return s.BestBid
+	default:
+		return s.BestAsk
+	}
+}
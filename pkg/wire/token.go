@@ -0,0 +1,40 @@
+package wire
+
+type MarketStatus string
+
+const (
+	StatusActive   MarketStatus = "active"
+	StatusPaused   MarketStatus = "paused"
+	StatusClosed   MarketStatus = "closed"
+	StatusResolved MarketStatus = "resolved"
+)
+
+// Tradable reports whether orders may be filled. An unknown status is treated
+// as tradable so assets registered before status tracking keep working.
+func (s MarketStatus) Tradable() bool {
+	return s == "" || s == StatusActive
+}
+
+// Final reports whether the market can never trade again.
+func (s MarketStatus) Final() bool {
+	return s == StatusClosed || s == StatusResolved
+}
+
+// Token is an outcome token as stored in its token:meta hash.
+type Token struct {
+	TokenID string `json:"token_id"`
+	Outcome string `json:"outcome"`
+	Market  string `json:"market"`
+
+	MarketSlug   string  `json:"market_slug"`
+	ConditionID  string  `json:"condition_id"`
+	EventID      string  `json:"event_id"`
+	EventTitle   string  `json:"event_title"`
+	NegRisk      bool    `json:"neg_risk"`
+	TickSize     float64 `json:"minimum_tick_size"`
+	MinOrderSize float64 `json:"minimum_order_size"`
+	EndDate      string  `json:"end_date"`
+	Sibling      string  `json:"sibling"`
+
+	Status MarketStatus `json:"status"`
+}
@@ -0,0 +1,25 @@
+// Package wire holds the payloads Mantis exchanges with strategies over
+// Redis: signals and execution results, books and their updates, candles,
+// token metadata and the paper portfolio. The engine and the client SDK both
+// use it, so bots can import the SDK without the engine.
+package wire
+
+// Signal is an order request. ID is echoed as signal_id on signals:outbound;
+// signals read from signals:inbound without one use their stream entry ID.
+type Signal struct {
+	ID         string  `json:"id,omitempty"`
+	Action     string  `json:"action"`
+	Asset      string  `json:"asset"`
+	Amount     float64 `json:"amount"`
+	StrategyID string  `json:"strategy_id"`
+}
+
+type ExecutionResult struct {
+	Success      bool    `json:"success"`
+	FilledPrice  float64 `json:"filled_price"`
+	FilledAmount float64 `json:"filled_amount"`
+	Fee          float64 `json:"fee"`
+	ErrorCode    string  `json:"error_code,omitempty"`
+	ErrorMsg     string  `json:"error_msg,omitempty"`
+	Timestamp    int64   `json:"timestamp"`
+}
@@ -0,0 +1,178 @@
+// Package recorder captures raw market-data frames to rotating gzip files and
+// replays them back through the streamer engine.
+package recorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/arjunprakash027/Mantis/pkg/logging"
+)
+
+// Record is one captured frame. Files hold one JSON record per line.
+type Record struct {
+	ReceivedAt int64  `json:"ts"` // unix nanoseconds
+	Namespace  string `json:"ns"`
+	Data       string `json:"data"`
+}
+
+// Options configures a Recorder. Buffered frames are flushed to the file
+// every FlushEvery (default one second), so a crash loses at most that much.
+type Options struct {
+	Dir          string
+	RotateEvery  time.Duration
+	MaxFileBytes int64
+	FlushEvery   time.Duration
+}
+
+// Recorder appends frames to the current file and starts a new file when the
+// rotation interval or size limit is reached. Closed files are never touched
+// again.
+type Recorder struct {
+	opts Options
+
+	mu      sync.Mutex
+	file    *os.File
+	gz      *gzip.Writer
+	buf     *bufio.Writer
+	opened  time.Time
+	written int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func New(opts Options) (*Recorder, error) {
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("recorder: empty directory")
+	}
+	if opts.RotateEvery <= 0 {
+		opts.RotateEvery = time.Hour
+	}
+	if opts.FlushEvery <= 0 {
+		opts.FlushEvery = time.Second
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	r := &Recorder{opts: opts, stop: make(chan struct{}), done: make(chan struct{})}
+	go r.flushLoop()
+	return r, nil
+}
+
+func (r *Recorder) flushLoop() {
+	defer close(r.done)
+	t := time.NewTicker(r.opts.FlushEvery)
+	defer t.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-t.C:
+			r.mu.Lock()
+			err := r.flush()
+			r.mu.Unlock()
+			if err != nil {
+				logging.For("recorder").Error("capture flush failed", "err", err)
+			}
+		}
+	}
+}
+
+// flush pushes buffered frames through gzip to the file. The gzip stream
+// stays open, so readers see every frame up to here.
+func (r *Recorder) flush() error {
+	if r.file == nil {
+		return nil
+	}
+	if err := r.buf.Flush(); err != nil {
+		return err
+	}
+	return r.gz.Flush()
+}
+
+// Record writes a frame stamped with the current time. Errors are logged
+// rather than returned so a full disk never stalls ingestion.
+func (r *Recorder) Record(namespace string, data []byte) {
+	line, err := json.Marshal(Record{
+		ReceivedAt: time.Now().UnixNano(),
+		Namespace:  namespace,
+		Data:       string(data),
+	})
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.rotateIfNeeded(); err != nil {
+		logging.For("recorder").Error("capture rotate failed", "dir", r.opts.Dir, "err", err)
+		return
+	}
+	n, err := r.buf.Write(append(line, '\n'))
+	if err != nil {
+		logging.For("recorder").Error("capture write failed", "err", err)
+		return
+	}
+	r.written += int64(n)
+}
+
+func (r *Recorder) Close() error {
+	select {
+	case <-r.stop:
+	default:
+		close(r.stop)
+	}
+	<-r.done
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closeFile()
+}
+
+func (r *Recorder) rotateIfNeeded() error {
+	if r.file != nil {
+		expired := time.Since(r.opened) >= r.opts.RotateEvery
+		full := r.opts.MaxFileBytes > 0 && r.written >= r.opts.MaxFileBytes
+		if !expired && !full {
+			return nil
+		}
+		if err := r.closeFile(); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().UTC()
+	name := filepath.Join(r.opts.Dir, fmt.Sprintf("mantis-%s.jsonl.gz", now.Format("20060102-150405.000000000")))
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	r.file = f
+	r.gz = gzip.NewWriter(f)
+	r.buf = bufio.NewWriterSize(r.gz, 64*1024)
+	r.opened = time.Now()
+	r.written = 0
+	return nil
+}
+
+func (r *Recorder) closeFile() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.buf.Flush()
+	if cerr := r.gz.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+	r.file, r.gz, r.buf = nil, nil, nil
+	return err
+}
@@ -0,0 +1,130 @@
+package recorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/arjunprakash027/Mantis/streamer"
+)
+
+// Files lists the capture files in dir in recording order.
+func Files(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "mantis-*.jsonl.gz"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Player reads capture files back in order. Speed 1 replays at the recorded
+// pace, N replays N times faster and 0 replays as fast as possible.
+type Player struct {
+	Files []string
+	Speed float64
+}
+
+// Play calls emit for every record, sleeping between records to honour Speed.
+func (p *Player) Play(ctx context.Context, emit func(Record) error) error {
+	var first int64
+	start := time.Now()
+
+	for _, name := range p.Files {
+		err := readFile(name, func(rec Record) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if p.Speed > 0 {
+				if first == 0 {
+					first = rec.ReceivedAt
+				}
+				offset := time.Duration(float64(rec.ReceivedAt-first) / p.Speed)
+				if wait := time.Until(start.Add(offset)); wait > 0 {
+					t := time.NewTimer(wait)
+					select {
+					case <-ctx.Done():
+						t.Stop()
+						return ctx.Err()
+					case <-t.C:
+					}
+				}
+			}
+			return emit(rec)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReplayInto feeds the capture through engine.ProcessStream, one channel per
+// namespace just like the live pipelines, and returns once every frame has
+// been processed.
+func ReplayInto(ctx context.Context, engine *streamer.Engine, p *Player) error {
+	chans := make(map[string]chan []byte)
+	var done []<-chan struct{}
+
+	err := p.Play(ctx, func(rec Record) error {
+		ch, ok := chans[rec.Namespace]
+		if !ok {
+			ch = make(chan []byte)
+			chans[rec.Namespace] = ch
+			done = append(done, engine.ProcessStream(rec.Namespace, ch))
+		}
+		select {
+		case ch <- []byte(rec.Data):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
+	for _, ch := range chans {
+		close(ch)
+	}
+	for _, d := range done {
+		<-d
+	}
+	return err
+}
+
+func readFile(name string, fn func(Record) error) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	defer gz.Close()
+
+	sc := bufio.NewScanner(gz)
+	sc.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for sc.Scan() {
+		var rec Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	// A file cut short by a crash still replays up to the damaged tail.
+	if err := sc.Err(); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
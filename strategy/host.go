@@ -0,0 +1,268 @@
+package strategy
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/arjunprakash027/Mantis/executor"
+	"github.com/arjunprakash027/Mantis/pkg/metrics"
+	"github.com/arjunprakash027/Mantis/streamer"
+)
+
+// DefaultQueueSize is how many events each strategy may have pending before
+// the host starts dropping them.
+const DefaultQueueSize = 1024
+
+// Submitter executes an order. *executor.Executor satisfies it live; the
+// backtest runner satisfies it to record its trade log.
+type Submitter interface {
+	Submit(sig executor.Signal) executor.ExecutionResult
+}
+
+type eventKind int
+
+const (
+	eventBook eventKind = iota
+	eventTrade
+	eventTimer
+)
+
+type event struct {
+	kind  eventKind
+	book  Book
+	trade Trade
+	now   time.Time
+}
+
+type registered struct {
+	s        Strategy
+	assets   map[string]bool
+	interval time.Duration
+	nextTick time.Time
+
+	// mu serialises inline delivery; queue is nil when the host is inline.
+	mu    sync.Mutex
+	queue chan event
+}
+
+// Host fans engine events out to strategies. Each strategy sees its callbacks
+// one at a time. Live, every strategy runs on its own goroutine behind a
+// bounded queue, so a slow strategy (or a blocking Buy) never stalls the
+// engine's ingest goroutines; events that overflow the queue are dropped and
+// counted. The backtest harness sets the queue size to zero so callbacks run
+// inline and results stay deterministic.
+type Host struct {
+	engine    *streamer.Engine
+	submit    Submitter
+	queueSize int
+
+	mu         sync.Mutex
+	strategies []*registered
+}
+
+func NewHost(engine *streamer.Engine, submit Submitter) *Host {
+	h := &Host{engine: engine, submit: submit, queueSize: DefaultQueueSize}
+	engine.AddListener(h)
+	return h
+}
+
+// SetQueueSize sets the per-strategy event queue. Zero delivers callbacks
+// inline on the calling goroutine. It must be called before Register.
+func (h *Host) SetQueueSize(n int) {
+	h.queueSize = n
+}
+
+func (h *Host) Register(s Strategy) {
+	r := &registered{s: s, interval: s.TimerInterval()}
+	if ids := s.Assets(); ids != nil {
+		r.assets = make(map[string]bool, len(ids))
+		for _, id := range ids {
+			r.assets[id] = true
+		}
+	}
+	if h.queueSize > 0 {
+		r.queue = make(chan event, h.queueSize)
+	}
+	h.mu.Lock()
+	h.strategies = append(h.strategies, r)
+	h.mu.Unlock()
+}
+
+// Start runs each registered strategy's goroutine and drives OnTimer from the
+// engine clock until ctx is cancelled. Strategies must be registered first;
+// events that arrive before Start wait in the queue. The backtest harness
+// calls Tick directly instead.
+func (h *Host) Start(ctx context.Context) {
+	h.mu.Lock()
+	for _, r := range h.strategies {
+		if r.queue != nil {
+			go h.run(ctx, r)
+		}
+	}
+	h.mu.Unlock()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.Tick(h.engine.Clock().Now())
+		}
+	}
+}
+
+func (h *Host) run(ctx context.Context, r *registered) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-r.queue:
+			h.dispatch(r, ev)
+		}
+	}
+}
+
+// deliver hands ev to r, inline or through its queue.
+func (h *Host) deliver(r *registered, ev event) {
+	if r.queue == nil {
+		r.mu.Lock()
+		h.dispatch(r, ev)
+		r.mu.Unlock()
+		return
+	}
+	select {
+	case r.queue <- ev:
+	default:
+		metrics.StrategyDropped.WithLabelValues(r.s.ID()).Inc()
+	}
+}
+
+func (h *Host) dispatch(r *registered, ev event) {
+	ctx := h.ctxFor(r)
+	switch ev.kind {
+	case eventBook:
+		r.s.OnBook(ctx, ev.book)
+	case eventTrade:
+		r.s.OnTrade(ctx, ev.trade)
+	case eventTimer:
+		r.s.OnTimer(ctx, ev.now)
+	}
+}
+
+// Tick fires every timer that is due at now.
+func (h *Host) Tick(now time.Time) {
+	for _, r := range h.snapshot() {
+		if h.due(r, now) {
+			h.deliver(r, event{kind: eventTimer, now: now})
+		}
+	}
+}
+
+func (h *Host) due(r *registered, now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if r.interval <= 0 {
+		return false
+	}
+	if r.nextTick.IsZero() {
+		r.nextTick = now.Add(r.interval)
+		return false
+	}
+	if now.Before(r.nextTick) {
+		return false
+	}
+	for !r.nextTick.After(now) {
+		r.nextTick = r.nextTick.Add(r.interval)
+	}
+	return true
+}
+
+func (h *Host) snapshot() []*registered {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]*registered(nil), h.strategies...)
+}
+
+// OnBook implements streamer.Listener.
+func (h *Host) OnBook(u streamer.OrderbookUpdate) {
+	// u may be a price change carrying only the levels that moved; the
+	// engine has already folded it into the full book.
+	depth, ok := h.engine.GetDepth(u.AssetID)
+	if !ok {
+		return
+	}
+	book := Book{
+		AssetID: u.AssetID,
+		Bids:    parseLevels(depth.Bids),
+		Asks:    parseLevels(depth.Asks),
+		Time:    h.engine.Clock().Now(),
+	}
+	if n := len(book.Bids); n > 0 {
+		book.BestBid = book.Bids[n-1].Price
+	}
+	if n := len(book.Asks); n > 0 {
+		book.BestAsk = book.Asks[n-1].Price
+	}
+
+	for _, r := range h.snapshot() {
+		if r.wants(u.AssetID) {
+			h.deliver(r, event{kind: eventBook, book: book})
+		}
+	}
+}
+
+// OnTrade implements streamer.Listener.
+func (h *Host) OnTrade(u streamer.OrderbookUpdate) {
+	price, err := strconv.ParseFloat(u.Price, 64)
+	if err != nil {
+		return
+	}
+	size, _ := strconv.ParseFloat(u.Size, 64)
+	trade := Trade{AssetID: u.AssetID, Price: price, Size: size, Side: u.Side, Time: h.engine.Clock().Now()}
+
+	for _, r := range h.snapshot() {
+		if r.wants(u.AssetID) {
+			h.deliver(r, event{kind: eventTrade, trade: trade})
+		}
+	}
+}
+
+func (r *registered) wants(assetID string) bool {
+	return r.assets == nil || r.assets[assetID]
+}
+
+func (h *Host) ctxFor(r *registered) Context {
+	return &callbackCtx{h: h, r: r}
+}
+
+// callbackCtx is only used by the goroutine currently delivering to r, so
+// OnFill is delivered inline without re-locking.
+type callbackCtx struct {
+	h *Host
+	r *registered
+}
+
+func (c *callbackCtx) Now() time.Time { return c.h.engine.Clock().Now() }
+
+func (c *callbackCtx) Price(assetID string) (streamer.MarketState, bool) {
+	return c.h.engine.GetPrice(assetID)
+}
+
+func (c *callbackCtx) Buy(assetID string, amount float64) executor.ExecutionResult {
+	return c.order("BUY", assetID, amount)
+}
+
+func (c *callbackCtx) Sell(assetID string, amount float64) executor.ExecutionResult {
+	return c.order("SELL", assetID, amount)
+}
+
+func (c *callbackCtx) order(action, assetID string, amount float64) executor.ExecutionResult {
+	sig := executor.Signal{Action: action, Asset: assetID, Amount: amount, StrategyID: c.r.s.ID()}
+	res := c.h.submit.Submit(sig)
+	c.r.s.OnFill(c, Fill{Signal: sig, Result: res})
+	return res
+}
@@ -0,0 +1,198 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/arjunprakash027/Mantis/executor"
+	"github.com/arjunprakash027/Mantis/pkg/metrics"
+	"github.com/arjunprakash027/Mantis/streamer"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+)
+
+type recordingSubmitter struct {
+	signals []executor.Signal
+}
+
+func (s *recordingSubmitter) Submit(sig executor.Signal) executor.ExecutionResult {
+	s.signals = append(s.signals, sig)
+	return executor.ExecutionResult{Success: true, FilledAmount: sig.Amount}
+}
+
+func newTestEngine(t *testing.T) *streamer.Engine {
+	t.Helper()
+	s := miniredis.RunT(t)
+	return streamer.NewEngine(context.Background(), redis.NewClient(&redis.Options{Addr: s.Addr()}))
+}
+
+func bookFrame(asset, bid, ask string) []byte {
+	return []byte(fmt.Sprintf(`{"event_type":"book","asset_id":%q,"bids":[{"price":%q,"size":"10"}],"asks":[{"price":%q,"size":"10"}]}`, asset, bid, ask))
+}
+
+type bookStrategy struct {
+	Base
+	books []Book
+}
+
+func (b *bookStrategy) ID() string { return "books" }
+
+func (b *bookStrategy) OnBook(_ Context, book Book) { b.books = append(b.books, book) }
+
+func TestHostDeliversFullBook(t *testing.T) {
+	engine := newTestEngine(t)
+	host := NewHost(engine, &recordingSubmitter{})
+	host.SetQueueSize(0)
+	s := &bookStrategy{}
+	host.Register(s)
+
+	engine.Process("orderbook", []byte(`{"event_type":"book","asset_id":"A","bids":[{"price":"0.40","size":"10"},{"price":"0.42","size":"5"}],"asks":[{"price":"0.50","size":"8"},{"price":"0.48","size":"3"}]}`))
+	// A delta that moves one level on each side.
+	engine.Process("orderbook", []byte(`{"event_type":"price_change","asset_id":"A","bids":[{"price":"0.44","size":"2"}],"asks":[{"price":"0.48","size":"0"}]}`))
+
+	if len(s.books) != 2 {
+		t.Fatalf("got %d books", len(s.books))
+	}
+	b := s.books[1]
+	if len(b.Bids) != 3 || b.Bids[2] != (Level{0.44, 2}) || b.Bids[0] != (Level{0.40, 10}) ||
+		len(b.Asks) != 1 || b.Asks[0] != (Level{0.50, 8}) || b.BestBid != 0.44 || b.BestAsk != 0.50 {
+		t.Errorf("book after delta = %+v", b)
+	}
+}
+
+type blockingStrategy struct {
+	Base
+	entered chan struct{}
+	release chan struct{}
+	books   chan Book
+}
+
+func (b *blockingStrategy) ID() string { return "slow" }
+
+func (b *blockingStrategy) OnBook(_ Context, book Book) {
+	b.entered <- struct{}{}
+	<-b.release
+	b.books <- book
+}
+
+func TestHostDoesNotBlockIngest(t *testing.T) {
+	engine := newTestEngine(t)
+	host := NewHost(engine, &recordingSubmitter{})
+	host.SetQueueSize(2)
+	slow := &blockingStrategy{entered: make(chan struct{}, 10), release: make(chan struct{}), books: make(chan Book, 10)}
+	host.Register(slow)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go host.Start(ctx)
+
+	before := testutil.ToFloat64(metrics.StrategyDropped.WithLabelValues("slow"))
+	engine.Process("orderbook", bookFrame("A", "0.40", "0.60"))
+	<-slow.entered
+
+	done := make(chan struct{})
+	go func() {
+		// The first book is held by the blocked callback; two more fill the
+		// queue and the rest are dropped.
+		for i := 0; i < 4; i++ {
+			engine.Process("orderbook", bookFrame("A", "0.40", "0.60"))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("a blocked strategy stalled the engine")
+	}
+	if got := testutil.ToFloat64(metrics.StrategyDropped.WithLabelValues("slow")) - before; got != 2 {
+		t.Errorf("dropped %v events, want 2", got)
+	}
+
+	close(slow.release)
+	for i := 0; i < 3; i++ {
+		select {
+		case b := <-slow.books:
+			if b.BestBid != 0.40 || b.BestAsk != 0.60 {
+				t.Errorf("book %d = %+v", i, b)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("only %d queued books delivered", i)
+		}
+	}
+}
+
+func TestThresholdStrategy(t *testing.T) {
+	engine := newTestEngine(t)
+	sub := &recordingSubmitter{}
+	host := NewHost(engine, sub)
+	host.SetQueueSize(0)
+
+	s, err := New("threshold", "th", map[string]string{"assets": "A", "buy_below": "0.40", "sell_above": "0.60", "amount": "5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	host.Register(s)
+
+	engine.Process("orderbook", bookFrame("A", "0.30", "0.45")) // ask above buy_below
+	engine.Process("orderbook", bookFrame("A", "0.30", "0.38")) // buy
+	engine.Process("orderbook", bookFrame("A", "0.30", "0.35")) // already holding
+	engine.Process("orderbook", bookFrame("B", "0.70", "0.10")) // not subscribed
+	engine.Process("orderbook", bookFrame("A", "0.62", "0.70")) // sell
+
+	if len(sub.signals) != 2 {
+		t.Fatalf("got signals %+v", sub.signals)
+	}
+	if sig := sub.signals[0]; sig.Action != "BUY" || sig.Asset != "A" || sig.Amount != 5 || sig.StrategyID != "th" {
+		t.Errorf("buy = %+v", sig)
+	}
+	if sig := sub.signals[1]; sig.Action != "SELL" || sig.Amount != 5 {
+		t.Errorf("sell = %+v", sig)
+	}
+}
+
+func TestThresholdParams(t *testing.T) {
+	for _, params := range []map[string]string{
+		{"buy_below": "0.4", "sell_above": "0.6"},
+		{"assets": "A", "buy_below": "x", "sell_above": "0.6"},
+		{"assets": "A", "buy_below": "0.6", "sell_above": "0.4"},
+		{"assets": "A", "buy_below": "0.4", "sell_above": "0.6", "amount": "-1"},
+	} {
+		if _, err := New("threshold", "th", params); err == nil {
+			t.Errorf("params %v accepted", params)
+		}
+	}
+	if _, err := New("nope", "x", nil); err == nil {
+		t.Error("unknown type accepted")
+	}
+}
+
+type timerStrategy struct {
+	Base
+	ticks []time.Time
+}
+
+func (s *timerStrategy) ID() string                   { return "timer" }
+func (s *timerStrategy) TimerInterval() time.Duration { return time.Minute }
+func (s *timerStrategy) OnTimer(_ Context, now time.Time) {
+	s.ticks = append(s.ticks, now)
+}
+
+func TestHostTick(t *testing.T) {
+	host := NewHost(newTestEngine(t), &recordingSubmitter{})
+	host.SetQueueSize(0)
+	s := &timerStrategy{}
+	host.Register(s)
+
+	start := time.Unix(1700000000, 0)
+	host.Tick(start)                       // arms the timer
+	host.Tick(start.Add(30 * time.Second)) // not due
+	host.Tick(start.Add(61 * time.Second))
+	host.Tick(start.Add(5 * time.Minute)) // missed ticks collapse into one
+
+	if len(s.ticks) != 2 {
+		t.Fatalf("got ticks %v", s.ticks)
+	}
+}
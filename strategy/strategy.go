@@ -0,0 +1,129 @@
+// Package strategy runs Go trading strategies inside the Mantis process. A
+// strategy is fed directly by the streamer engine and submits straight into
+// the executor, skipping the signals:inbound round trip. The same Host drives
+// strategies in live trading and in the backtest harness.
+package strategy
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/arjunprakash027/Mantis/executor"
+	"github.com/arjunprakash027/Mantis/streamer"
+)
+
+type Level struct {
+	Price float64
+	Size  float64
+}
+
+// Book is an asset's full book after an update, in CLOB order: bids
+// ascending, asks descending, best last.
+type Book struct {
+	AssetID string
+	Bids    []Level
+	Asks    []Level
+	BestBid float64
+	BestAsk float64
+	Time    time.Time
+}
+
+type Trade struct {
+	AssetID string
+	Price   float64
+	Size    float64
+	Side    string
+	Time    time.Time
+}
+
+// Fill is the executor's answer to an order, including rejections.
+type Fill struct {
+	Signal executor.Signal
+	Result executor.ExecutionResult
+}
+
+// Context is handed to every callback. It is only valid for the duration of
+// the callback.
+type Context interface {
+	Now() time.Time
+	Price(assetID string) (streamer.MarketState, bool)
+	Buy(assetID string, amount float64) executor.ExecutionResult
+	Sell(assetID string, amount float64) executor.ExecutionResult
+}
+
+type Strategy interface {
+	ID() string
+	// Assets limits the updates delivered; nil means every streamed asset.
+	Assets() []string
+	// TimerInterval is how often OnTimer fires; zero disables it.
+	TimerInterval() time.Duration
+
+	OnBook(ctx Context, b Book)
+	OnTrade(ctx Context, t Trade)
+	OnFill(ctx Context, f Fill)
+	OnTimer(ctx Context, now time.Time)
+}
+
+// Base provides no-op callbacks so strategies only implement what they use.
+type Base struct{}
+
+func (Base) Assets() []string             { return nil }
+func (Base) TimerInterval() time.Duration { return 0 }
+func (Base) OnBook(Context, Book)         {}
+func (Base) OnTrade(Context, Trade)       {}
+func (Base) OnFill(Context, Fill)         {}
+func (Base) OnTimer(Context, time.Time)   {}
+
+// Factory builds a strategy from its config params.
+type Factory func(id string, params map[string]string) (Strategy, error)
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a strategy type available to config. It is meant to be
+// called from init functions.
+func Register(kind string, f Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[kind] = f
+}
+
+func New(kind, id string, params map[string]string) (Strategy, error) {
+	registryMu.Lock()
+	f, ok := registry[kind]
+	registryMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy type %q", kind)
+	}
+	return f(id, params)
+}
+
+// Kinds lists the registered strategy types.
+func Kinds() []string {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	out := make([]string, 0, len(registry))
+	for k := range registry {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func parseLevels(raw []streamer.PriceLevel) []Level {
+	out := make([]Level, 0, len(raw))
+	for _, l := range raw {
+		p, err := strconv.ParseFloat(l.Price, 64)
+		if err != nil {
+			continue
+		}
+		sz, _ := strconv.ParseFloat(l.Size, 64)
+		out = append(out, Level{Price: p, Size: sz})
+	}
+	return out
+}
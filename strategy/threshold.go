@@ -0,0 +1,90 @@
+package strategy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	Register("threshold", newThreshold)
+}
+
+// threshold buys a fixed number of shares when an asset's best ask falls to
+// buy_below and sells them again once its best bid reaches sell_above. It
+// holds at most one lot per asset.
+//
+//	params:
+//	  assets: "tokA,tokB"   # required
+//	  buy_below: "0.40"     # required
+//	  sell_above: "0.60"    # required, above buy_below
+//	  amount: "10"          # shares per lot, default 1
+type threshold struct {
+	Base
+	id        string
+	assets    []string
+	buyBelow  float64
+	sellAbove float64
+	amount    float64
+	held      map[string]float64
+}
+
+func newThreshold(id string, params map[string]string) (Strategy, error) {
+	t := &threshold{id: id, amount: 1, held: make(map[string]float64)}
+	for _, a := range strings.Split(params["assets"], ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			t.assets = append(t.assets, a)
+		}
+	}
+	if len(t.assets) == 0 {
+		return nil, fmt.Errorf("threshold: assets is required")
+	}
+	var err error
+	if t.buyBelow, err = floatParam(params, "buy_below"); err != nil {
+		return nil, err
+	}
+	if t.sellAbove, err = floatParam(params, "sell_above"); err != nil {
+		return nil, err
+	}
+	if t.sellAbove <= t.buyBelow {
+		return nil, fmt.Errorf("threshold: sell_above must be above buy_below")
+	}
+	if _, ok := params["amount"]; ok {
+		if t.amount, err = floatParam(params, "amount"); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func floatParam(params map[string]string, key string) (float64, error) {
+	v, err := strconv.ParseFloat(params[key], 64)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("threshold: %s must be a positive number, got %q", key, params[key])
+	}
+	return v, nil
+}
+
+func (t *threshold) ID() string       { return t.id }
+func (t *threshold) Assets() []string { return t.assets }
+
+func (t *threshold) OnBook(ctx Context, b Book) {
+	held := t.held[b.AssetID]
+	switch {
+	case held == 0 && b.BestAsk > 0 && b.BestAsk <= t.buyBelow:
+		ctx.Buy(b.AssetID, t.amount)
+	case held > 0 && b.BestBid >= t.sellAbove:
+		ctx.Sell(b.AssetID, held)
+	}
+}
+
+func (t *threshold) OnFill(_ Context, f Fill) {
+	if !f.Result.Success {
+		return
+	}
+	if f.Signal.Action == "BUY" {
+		t.held[f.Signal.Asset] += f.Result.FilledAmount
+	} else {
+		t.held[f.Signal.Asset] -= f.Result.FilledAmount
+	}
+}
@@ -0,0 +1,226 @@
+package streamer
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/arjunprakash027/Mantis/market"
+	"github.com/arjunprakash027/Mantis/pkg/metrics"
+	"github.com/arjunprakash027/Mantis/pkg/redismantis"
+)
+
+// Arb directions: buying one share of every outcome pays exactly $1, as does
+// selling one share of every outcome after minting the set.
+const (
+	ArbBuyAll  = "buy_all"
+	ArbSellAll = "sell_all"
+)
+
+// ArbOptions configures the cross-outcome monitor. An opportunity is
+// published when its edge, net of fees, exceeds MinEdge per $1 payout.
+// FeeRate is charged on the notional of every leg (0.002 is 20 bps).
+type ArbOptions struct {
+	MinEdge float64
+	FeeRate float64
+}
+
+// ArbLeg is one outcome of an opportunity at its best price.
+type ArbLeg struct {
+	AssetID string  `json:"asset_id"`
+	Outcome string  `json:"outcome"`
+	Market  string  `json:"market,omitempty"`
+	Price   float64 `json:"price"`
+	Size    float64 `json:"size"`
+}
+
+// ArbOpportunity is one entry of arb:opportunities. Group is the market's
+// condition ID for a binary pair and the event ID for the Yes tokens of a
+// neg-risk event. Edge is per $1 payout, Size the number of sets available at
+// the legs' best prices.
+type ArbOpportunity struct {
+	Group     string   `json:"group"`
+	Kind      string   `json:"kind"` // "binary" or "neg_risk"
+	Direction string   `json:"direction"`
+	PriceSum  float64  `json:"price_sum"`
+	Fee       float64  `json:"fee"`
+	Edge      float64  `json:"edge"`
+	Size      float64  `json:"size"`
+	Profit    float64  `json:"profit"`
+	Legs      []ArbLeg `json:"legs"`
+	TS        int64    `json:"ts"` // unix milliseconds
+}
+
+type arbGroup struct {
+	id, kind string
+	legs     []market.Token
+}
+
+// arb re-prices every group an updated asset belongs to. An opportunity is
+// written when it opens and again whenever its edge or size changes. edges
+// holds every priced group's edge by direction, for the best-edge gauge, and
+// groups the groups each asset was last priced in.
+type arb struct {
+	e    *Engine
+	opts ArbOptions
+
+	mu     sync.Mutex
+	open   map[string]ArbOpportunity
+	edges  map[string]map[string]float64
+	groups map[string]map[string]bool
+}
+
+// StartArb enables the cross-outcome monitor. It must be called before any
+// stream is started.
+func (e *Engine) StartArb(opts ArbOptions) {
+	e.AddListener(&arb{e: e, opts: opts, open: make(map[string]ArbOpportunity), groups: make(map[string]map[string]bool),
+		edges: map[string]map[string]float64{
+			ArbBuyAll:  make(map[string]float64),
+			ArbSellAll: make(map[string]float64),
+		}})
+}
+
+func (a *arb) OnTrade(OrderbookUpdate) {}
+
+func (a *arb) OnBook(u OrderbookUpdate) {
+	now := a.e.clock.Now()
+	var out []ArbOpportunity
+
+	a.mu.Lock()
+	groups := a.e.arbGroups(u.AssetID)
+	priced := make(map[string]bool, len(groups))
+	for _, g := range groups {
+		priced[g.id] = true
+		for _, dir := range []string{ArbBuyAll, ArbSellAll} {
+			key := g.id + "/" + dir
+			opp, ok := a.evaluate(g, dir)
+			if !ok {
+				delete(a.open, key)
+				delete(a.edges[dir], g.id)
+				continue
+			}
+			a.edges[dir][g.id] = opp.Edge
+			if opp.Edge <= a.opts.MinEdge || opp.Size <= 0 {
+				delete(a.open, key)
+				continue
+			}
+			prev, was := a.open[key]
+			if was && prev.Edge == opp.Edge && prev.Size == opp.Size {
+				continue
+			}
+			if !was {
+				metrics.ArbOpportunities.WithLabelValues(dir).Inc()
+			}
+			opp.TS = now.UnixMilli()
+			a.open[key] = opp
+			out = append(out, opp)
+		}
+	}
+	// A group that stopped pricing, e.g. after a status change, is closed.
+	for id := range a.groups[u.AssetID] {
+		if !priced[id] {
+			for _, dir := range []string{ArbBuyAll, ArbSellAll} {
+				delete(a.open, id+"/"+dir)
+				delete(a.edges[dir], id)
+			}
+		}
+	}
+	a.groups[u.AssetID] = priced
+	a.setBestEdges()
+	a.mu.Unlock()
+
+	// Opportunities span markets, so the stream keeps the arb namespace's
+	// retention rather than one leg's market.
+	for _, opp := range out {
+		data, _ := json.Marshal(opp)
+		a.e.xadd("arb", redismantis.StreamArbOpportunities, "", data)
+	}
+}
+
+// setBestEdges exports the best edge of each direction across the priced
+// groups. A per-group series would grow with every market ever subscribed.
+func (a *arb) setBestEdges() {
+	for dir, edges := range a.edges {
+		if len(edges) == 0 {
+			metrics.ArbEdge.DeleteLabelValues(dir)
+			continue
+		}
+		best := math.Inf(-1)
+		for _, edge := range edges {
+			best = max(best, edge)
+		}
+		metrics.ArbEdge.WithLabelValues(dir).Set(best)
+	}
+}
+
+// evaluate prices a group at its best asks (buy_all) or bids (sell_all). It
+// reports false while any leg lacks that side.
+func (a *arb) evaluate(g arbGroup, dir string) (ArbOpportunity, bool) {
+	opp := ArbOpportunity{Group: g.id, Kind: g.kind, Direction: dir, Size: math.Inf(1)}
+	for _, t := range g.legs {
+		s, ok := a.e.top(t.TokenID, 1)
+		price, size := s.ask, s.askSize
+		if dir == ArbSellAll {
+			price, size = s.bid, s.bidSize
+		}
+		if !ok || price <= 0 {
+			return opp, false
+		}
+		opp.Legs = append(opp.Legs, ArbLeg{AssetID: t.TokenID, Outcome: t.Outcome, Market: t.Market, Price: price, Size: size})
+		opp.PriceSum += price
+		opp.Size = min(opp.Size, size)
+	}
+	opp.Fee = opp.PriceSum * a.opts.FeeRate
+	if dir == ArbBuyAll {
+		opp.Edge = 1 - opp.PriceSum - opp.Fee
+	} else {
+		opp.Edge = opp.PriceSum - 1 - opp.Fee
+	}
+	opp.Profit = opp.Edge * opp.Size
+	return opp, true
+}
+
+// arbGroups returns the complementary sets asset belongs to: its binary
+// market, and for a Yes token of a neg-risk event subscribed by its event
+// slug, the Yes tokens of all the event's markets. A set with any outcome
+// that cannot trade is left out, since the rest no longer pays $1.
+func (e *Engine) arbGroups(asset string) []arbGroup {
+	e.metaMu.RLock()
+	defer e.metaMu.RUnlock()
+	t, ok := e.meta[asset]
+	if !ok || !t.Status.Tradable() {
+		return nil
+	}
+
+	var groups []arbGroup
+	if sib, ok := e.meta[t.Sibling]; ok && sib.Status.Tradable() {
+		legs := []market.Token{t, sib}
+		sort.Slice(legs, func(i, j int) bool { return legs[i].TokenID < legs[j].TokenID })
+		groups = append(groups, arbGroup{id: t.ConditionID, kind: "binary", legs: legs})
+	}
+
+	if !t.NegRisk || t.EventID == "" || !strings.EqualFold(t.Outcome, "yes") {
+		return groups
+	}
+	// Only tokens registered under the same slug: a partly subscribed event
+	// would look like a free buy_all.
+	slug := e.slugs[asset]
+	var legs []market.Token
+	for id := range e.events[t.EventID] {
+		o := e.meta[id]
+		if e.slugs[id] != slug || !strings.EqualFold(o.Outcome, "yes") {
+			continue
+		}
+		if !o.Status.Tradable() {
+			return groups
+		}
+		legs = append(legs, o)
+	}
+	if len(legs) > 1 {
+		sort.Slice(legs, func(i, j int) bool { return legs[i].TokenID < legs[j].TokenID })
+		groups = append(groups, arbGroup{id: t.EventID, kind: "neg_risk", legs: legs})
+	}
+	return groups
+}
@@ -0,0 +1,107 @@
+package streamer
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/arjunprakash027/Mantis/market"
+	"github.com/arjunprakash027/Mantis/pkg/metrics"
+	"github.com/arjunprakash027/Mantis/pkg/redismantis"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestArb(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	ctx := context.Background()
+	engine := NewEngine(ctx, rdb)
+	engine.StartArb(ArbOptions{MinEdge: 0.01, FeeRate: 0.01})
+
+	engine.RegisterMetadata("m1", []market.Token{
+		{TokenID: "Y1", Outcome: "Yes", ConditionID: "C1", Sibling: "N1"},
+		{TokenID: "N1", Outcome: "No", ConditionID: "C1", Sibling: "Y1"},
+	})
+	var ev []market.Token
+	for _, id := range []string{"A", "B", "C"} {
+		ev = append(ev,
+			market.Token{TokenID: id, Outcome: "Yes", ConditionID: "C" + id, EventID: "E", NegRisk: true, Sibling: id + "n"},
+			market.Token{TokenID: id + "n", Outcome: "No", ConditionID: "C" + id, EventID: "E", NegRisk: true, Sibling: id})
+	}
+	engine.RegisterMetadata("ev", ev)
+	// The same event subscribed by one market's slug is not part of the set.
+	engine.RegisterMetadata("d", []market.Token{{TokenID: "D", Outcome: "Yes", EventID: "E", NegRisk: true}})
+
+	book := func(asset, bid, bidSize, ask, askSize string) {
+		data, _ := json.Marshal(OrderbookUpdate{EventType: "book", AssetID: asset,
+			Bids: []PriceLevel{{Price: bid, Size: bidSize}}, Asks: []PriceLevel{{Price: ask, Size: askSize}}})
+		engine.Process("orderbook", data)
+	}
+	entries := func() []ArbOpportunity {
+		msgs, _ := rdb.XRange(ctx, redismantis.StreamArbOpportunities, "-", "+").Result()
+		out := make([]ArbOpportunity, len(msgs))
+		for i, m := range msgs {
+			json.Unmarshal([]byte(m.Values["data"].(string)), &out[i])
+		}
+		return out
+	}
+
+	// Asks sum to 0.97: 0.03 gross, 0.0203 after 1% fees, on 30 sets.
+	book("Y1", "0.40", "100", "0.45", "50")
+	book("N1", "0.50", "80", "0.52", "30")
+	got := entries()
+	if len(got) != 1 {
+		t.Fatalf("got %d opportunities: %+v", len(got), got)
+	}
+	opp := got[0]
+	if opp.Group != "C1" || opp.Kind != "binary" || opp.Direction != ArbBuyAll || opp.Size != 30 ||
+		math.Abs(opp.Edge-0.0203) > 1e-9 || math.Abs(opp.Profit-0.609) > 1e-9 || len(opp.Legs) != 2 {
+		t.Errorf("opportunity = %+v", opp)
+	}
+	if edge := testutil.ToFloat64(metrics.ArbEdge.WithLabelValues(ArbSellAll)); math.Abs(edge-(-0.109)) > 1e-9 {
+		t.Errorf("sell_all edge gauge = %v", edge)
+	}
+
+	// A size change is republished, an unchanged quote is not, and a closed
+	// edge stops the updates.
+	engine.Process("orderbook", []byte(`{"event_type":"price_change","asset_id":"N1","asks":[{"price":"0.52","size":"20"}]}`))
+	engine.Process("orderbook", []byte(`{"event_type":"price_change","asset_id":"Y1","bids":[{"price":"0.40","size":"90"}]}`))
+	engine.Process("orderbook", []byte(`{"event_type":"price_change","asset_id":"N1","asks":[{"price":"0.56","size":"20"}]}`))
+	if got := entries(); len(got) != 2 || got[1].Size != 20 {
+		t.Errorf("after updates: %+v", got)
+	}
+
+	// Yes bids across the event sum to 1.05: sell every outcome.
+	book("A", "0.40", "10", "0.60", "10")
+	book("B", "0.35", "5", "0.60", "10")
+	book("D", "0.90", "5", "0.95", "10")
+	book("C", "0.30", "8", "0.60", "10")
+	got = entries()
+	if len(got) != 3 {
+		t.Fatalf("got %d opportunities: %+v", len(got), got)
+	}
+	opp = got[2]
+	if opp.Group != "E" || opp.Kind != "neg_risk" || opp.Direction != ArbSellAll || len(opp.Legs) != 3 ||
+		opp.Size != 5 || math.Abs(opp.Edge-0.0395) > 1e-9 {
+		t.Errorf("event opportunity = %+v", opp)
+	}
+	// The gauge reports the best group, not the last one priced.
+	if edge := testutil.ToFloat64(metrics.ArbEdge.WithLabelValues(ArbSellAll)); math.Abs(edge-0.0395) > 1e-9 {
+		t.Errorf("sell_all edge gauge = %v, want the event's edge", edge)
+	}
+
+	// A resolved market takes its set with it: the remaining Yes asks sum
+	// below $1 but are not a free buy_all, and the set's edge is cleared.
+	engine.SetStatus("C", market.StatusResolved)
+	book("A", "0.30", "10", "0.35", "10")
+	book("B", "0.30", "10", "0.35", "10")
+	if got := entries(); len(got) != 3 {
+		t.Errorf("set with a resolved market still priced: %+v", got[3:])
+	}
+	if edge := testutil.ToFloat64(metrics.ArbEdge.WithLabelValues(ArbSellAll)); math.Abs(edge-(-0.109)) > 1e-9 {
+		t.Errorf("sell_all edge gauge = %v, want C1's edge after the event closed", edge)
+	}
+}
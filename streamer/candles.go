@@ -0,0 +1,196 @@
+package streamer
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/arjunprakash027/Mantis/pkg/redismantis"
+	"github.com/arjunprakash027/Mantis/pkg/wire"
+)
+
+// Candle is shared with the client SDK.
+type Candle = wire.Candle
+
+// CandleOptions configures the aggregator. Window is how many closed bars
+// per asset and interval are kept in memory for Candles.
+type CandleOptions struct {
+	Intervals []time.Duration
+	Window    int
+}
+
+type candleKey struct {
+	asset    string
+	interval time.Duration
+}
+
+// candles builds bars from the engine's events. A bar is closed and written
+// to candles:<interval>:<asset> by the first event after its end, or by the
+// ticker if the asset goes quiet. Empty intervals produce no bar.
+type candles struct {
+	e    *Engine
+	opts CandleOptions
+
+	mu     sync.Mutex
+	open   map[candleKey]*Candle
+	closed map[candleKey][]Candle
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// StartCandles enables OHLCV aggregation. It must be called before any stream
+// is started; Drain stops it.
+func (e *Engine) StartCandles(opts CandleOptions) {
+	opts.Window = max(opts.Window, 1)
+	sort.Slice(opts.Intervals, func(i, j int) bool { return opts.Intervals[i] < opts.Intervals[j] })
+	c := &candles{
+		e:      e,
+		opts:   opts,
+		open:   make(map[candleKey]*Candle),
+		closed: make(map[candleKey][]Candle),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	e.candles = c
+	e.AddListener(c)
+	go c.run()
+}
+
+// Candles returns up to n of the most recent closed bars, oldest first.
+func (e *Engine) Candles(asset string, interval time.Duration, n int) []Candle {
+	if e.candles == nil {
+		return nil
+	}
+	return e.candles.last(asset, interval, n)
+}
+
+// CandleIntervals lists the aggregated intervals, shortest first.
+func (e *Engine) CandleIntervals() []time.Duration {
+	if e.candles == nil {
+		return nil
+	}
+	return e.candles.opts.Intervals
+}
+
+func (c *candles) OnBook(u OrderbookUpdate) {
+	s, ok := c.e.GetPrice(u.AssetID)
+	if !ok || s.BestBid <= 0 || s.BestAsk <= 0 {
+		return
+	}
+	mid := (s.BestBid + s.BestAsk) / 2
+	c.update(u.AssetID, func(k *Candle, opened bool) {
+		if opened {
+			k.Open, k.High, k.Low = mid, mid, mid
+		}
+		k.High, k.Low, k.Close = max(k.High, mid), min(k.Low, mid), mid
+	})
+}
+
+func (c *candles) OnTrade(u OrderbookUpdate) {
+	price, err1 := strconv.ParseFloat(u.Price, 64)
+	size, err2 := strconv.ParseFloat(u.Size, 64)
+	if err1 != nil || err2 != nil || u.AssetID == "" {
+		return
+	}
+	mid := price
+	if s, ok := c.e.GetPrice(u.AssetID); ok && s.BestBid > 0 && s.BestAsk > 0 {
+		mid = (s.BestBid + s.BestAsk) / 2
+	}
+	c.update(u.AssetID, func(k *Candle, opened bool) {
+		if opened {
+			k.Open, k.High, k.Low, k.Close = mid, mid, mid, mid
+		}
+		k.Volume += size
+		k.Trades++
+		if k.Volume > 0 {
+			k.VWAP += (price - k.VWAP) * size / k.Volume
+		}
+		k.Last = price
+	})
+}
+
+func (c *candles) update(asset string, apply func(k *Candle, opened bool)) {
+	now := c.e.clock.Now()
+	var done []Candle
+	c.mu.Lock()
+	for _, iv := range c.opts.Intervals {
+		key := candleKey{asset, iv}
+		start := now.Truncate(iv).UnixMilli()
+		k := c.open[key]
+		if k != nil && k.Start != start {
+			done = append(done, c.closeLocked(key, k))
+			k = nil
+		}
+		opened := k == nil
+		if opened {
+			k = &Candle{AssetID: asset, Interval: wire.IntervalLabel(iv), Start: start}
+			c.open[key] = k
+		}
+		apply(k, opened)
+	}
+	c.mu.Unlock()
+	c.publish(done)
+}
+
+// flush closes every bar that ended before now.
+func (c *candles) flush(now time.Time) {
+	var done []Candle
+	c.mu.Lock()
+	for key, k := range c.open {
+		if k.Start+key.interval.Milliseconds() <= now.UnixMilli() {
+			done = append(done, c.closeLocked(key, k))
+		}
+	}
+	c.mu.Unlock()
+	c.publish(done)
+}
+
+func (c *candles) closeLocked(key candleKey, k *Candle) Candle {
+	delete(c.open, key)
+	bars := append(c.closed[key], *k)
+	if len(bars) > c.opts.Window {
+		bars = bars[len(bars)-c.opts.Window:]
+	}
+	c.closed[key] = bars
+	return *k
+}
+
+func (c *candles) publish(bars []Candle) {
+	for _, k := range bars {
+		data, _ := json.Marshal(k)
+		c.e.xadd("candles", redismantis.StreamCandles(k.Interval, k.AssetID), k.AssetID, data)
+	}
+}
+
+func (c *candles) last(asset string, interval time.Duration, n int) []Candle {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	bars := c.closed[candleKey{asset, interval}]
+	if n > 0 && len(bars) > n {
+		bars = bars[len(bars)-n:]
+	}
+	return append([]Candle(nil), bars...)
+}
+
+func (c *candles) run() {
+	defer close(c.done)
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-t.C:
+			c.flush(c.e.clock.Now())
+		}
+	}
+}
+
+// close stops the ticker. Bars still open are incomplete and not written.
+func (c *candles) close() {
+	close(c.stop)
+	<-c.done
+}
@@ -0,0 +1,74 @@
+package streamer
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/arjunprakash027/Mantis/pkg/clock"
+	"github.com/arjunprakash027/Mantis/pkg/wire"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestCandles(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	ctx := context.Background()
+	sim := clock.NewSim(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	engine := NewEngine(ctx, rdb)
+	engine.SetClock(sim)
+	engine.StartCandles(CandleOptions{Intervals: []time.Duration{time.Minute, time.Second}, Window: 2})
+	defer engine.Drain(ctx)
+
+	at := func(ms int, frame string) {
+		sim.Set(time.Date(2025, 1, 1, 12, 0, 0, ms*int(time.Millisecond), time.UTC))
+		engine.Process("orderbook", []byte(frame))
+	}
+	at(0, `{"event_type":"book","asset_id":"A","bids":[{"price":"0.40","size":"10"}],"asks":[{"price":"0.50","size":"10"}]}`)
+	at(200, `{"event_type":"price_change","asset_id":"A","bids":[{"price":"0.48","size":"1"}]}`)
+	at(300, `{"event_type":"last_trade_price","asset_id":"A","price":"0.50","size":"4","side":"BUY"}`)
+	at(400, `{"event_type":"last_trade_price","asset_id":"A","price":"0.48","size":"6","side":"SELL"}`)
+	at(600, `{"event_type":"price_change","asset_id":"A","asks":[{"price":"0.46","size":"1"}]}`)
+	// The next second's first event closes the first bar.
+	at(1500, `{"event_type":"price_change","asset_id":"A","bids":[{"price":"0.30","size":"1"}]}`)
+
+	bars := engine.Candles("A", time.Second, 10)
+	want := Candle{AssetID: "A", Interval: "1s", Start: sim.Now().Truncate(time.Minute).UnixMilli(),
+		Open: 0.45, High: 0.49, Low: 0.45, Close: 0.47, Volume: 10, Trades: 2, VWAP: 0.488, Last: 0.48}
+	if len(bars) != 1 {
+		t.Fatalf("got %d closed 1s bars", len(bars))
+	}
+	got := bars[0]
+	if got.VWAP-want.VWAP > 1e-9 || want.VWAP-got.VWAP > 1e-9 {
+		t.Errorf("vwap = %v", got.VWAP)
+	}
+	got.VWAP = want.VWAP
+	if got != want {
+		t.Errorf("bar = %+v\nwant  %+v", got, want)
+	}
+
+	msgs, _ := rdb.XRange(ctx, "candles:1s:A", "-", "+").Result()
+	if len(msgs) != 1 {
+		t.Fatalf("candles:1s:A has %d entries", len(msgs))
+	}
+	var streamed Candle
+	json.Unmarshal([]byte(msgs[0].Values["data"].(string)), &streamed)
+	if streamed.Close != 0.47 || streamed.Volume != 10 {
+		t.Errorf("streamed bar = %+v", streamed)
+	}
+
+	// A quiet asset's bar is closed by the ticker; the minute bar is still open.
+	sim.Set(sim.Now().Add(3 * time.Second))
+	engine.candles.flush(sim.Now())
+	if bars := engine.Candles("A", time.Second, 10); len(bars) != 2 || bars[1].Open != 0.47 {
+		t.Errorf("after flush: %+v", bars)
+	}
+	if bars := engine.Candles("A", time.Minute, 10); len(bars) != 0 {
+		t.Errorf("minute bar closed early: %+v", bars)
+	}
+	if got := wire.IntervalLabel(5 * time.Minute); got != "5m" {
+		t.Errorf("label = %q", got)
+	}
+}
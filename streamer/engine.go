@@ -3,53 +3,155 @@ package streamer
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"strconv"
 	"sync"
 	"time"
 
 	"github.com/arjunprakash027/Mantis/market"
+	"github.com/arjunprakash027/Mantis/pkg/clock"
+	"github.com/arjunprakash027/Mantis/pkg/logging"
+	"github.com/arjunprakash027/Mantis/pkg/metrics"
 	"github.com/arjunprakash027/Mantis/pkg/redismantis"
+	"github.com/arjunprakash027/Mantis/pkg/wire"
 	"github.com/redis/go-redis/v9"
 )
 
-type MarketState struct {
-	BestAsk     float64
-	BestBid     float64
-	LastUpdated int64
-}
+// The book and update types are shared with the client SDK.
+type (
+	MarketState     = wire.MarketState
+	OrderbookUpdate = wire.OrderbookUpdate
+	PriceLevel      = wire.PriceLevel
+	Depth           = wire.Depth
+)
 
 type Engine struct {
-	rdb    *redis.Client
+	rdb    redis.UniversalClient
 	prices map[string]MarketState
+	books  l2Books
 	mu     sync.RWMutex
 	ctx    context.Context
+
+	meta   map[string]market.Token
+	slugs  map[string]string
+	events map[string]map[string]bool // event ID -> token IDs
+	metaMu sync.RWMutex
+
+	retention   RetentionPolicy
+	retentionMu sync.RWMutex
+
+	recorder      FrameRecorder
+	settler       Settler
+	marketStreams bool
+	writer        *writer
+	candles       *candles
+	features      *features
+	clock         clock.Clock
+	listeners     []Listener
+	logger        *slog.Logger
+
+	streams sync.WaitGroup
+}
+
+// Listener receives decoded book and trade events in-process, after the
+// price cache has been updated. Calls come from the stream goroutines, so
+// implementations must be safe for concurrent use.
+type Listener interface {
+	OnBook(u OrderbookUpdate)
+	OnTrade(u OrderbookUpdate)
+}
+
+// FrameRecorder receives every raw frame before the engine processes it.
+type FrameRecorder interface {
+	Record(namespace string, data []byte)
 }
 
-type OrderbookUpdate struct {
-	AssetID string `json:"asset_id"`
-	Bids    []struct {
-		Price string `json:"price"`
-		Size  string `json:"size"`
-	} `json:"bids"`
-	Asks []struct {
-		Price string `json:"price"`
-		Size  string `json:"size"`
-	} `json:"asks"`
+// Settler is told when a market resolves, with its outcome tokens and the
+// one that won.
+type Settler interface {
+	Settle(assets []string, winner string)
 }
 
-func NewEngine(ctx context.Context, rdb *redis.Client) *Engine {
+func NewEngine(ctx context.Context, rdb redis.UniversalClient) *Engine {
 	return &Engine{
-		rdb:    rdb,
-		prices: make(map[string]MarketState),
-		ctx:    ctx,
+		rdb:       rdb,
+		prices:    make(map[string]MarketState),
+		books:     make(l2Books),
+		ctx:       ctx,
+		meta:      make(map[string]market.Token),
+		slugs:     make(map[string]string),
+		events:    make(map[string]map[string]bool),
+		retention: DefaultRetention,
+		clock:     clock.Real{},
+		logger:    logging.For("streamer"),
 	}
 }
 
+// SetClock replaces the wall clock, e.g. with a simulated one for backtests.
+// It must be called before any stream is started.
+func (e *Engine) SetClock(c clock.Clock) {
+	e.clock = c
+}
+
+func (e *Engine) Clock() clock.Clock {
+	return e.clock
+}
+
+// AddListener registers an in-process consumer. It must be called before any
+// stream is started.
+func (e *Engine) AddListener(l Listener) {
+	e.listeners = append(e.listeners, l)
+}
+
 func (e *Engine) GetContext() context.Context {
 	return e.ctx
 }
 
+// LastUpdates returns when each cached asset last had a price change.
+func (e *Engine) LastUpdates() map[string]time.Time {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	out := make(map[string]time.Time, len(e.prices))
+	for id, s := range e.prices {
+		out[id] = time.Unix(s.LastUpdated, 0)
+	}
+	return out
+}
+
+// GetDepth returns an asset's book: the last snapshot with every later price
+// change applied.
+func (e *Engine) GetDepth(assetID string) (Depth, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	b, ok := e.books[assetID]
+	if !ok {
+		return Depth{}, false
+	}
+	return b.Depth(), true
+}
+
+// bookTop is the best level of each side of a book, with the summed size of
+// the top levels of each side.
+type bookTop struct {
+	bid, bidSize, ask, askSize float64
+	bidDepth, askDepth         float64
+}
+
+// top reads an asset's best levels from the engine's book, summing the size of
+// the best n levels of each side.
+func (e *Engine) top(assetID string, n int) (bookTop, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	b, ok := e.books[assetID]
+	if !ok {
+		return bookTop{}, false
+	}
+	var t bookTop
+	t.bid, t.bidSize, t.ask, t.askSize = b.Best()
+	t.bidDepth, t.askDepth = b.TopSize(n)
+	return t, true
+}
+
 func (e *Engine) GetPrice(assetID string) (MarketState, bool) {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
@@ -57,47 +159,257 @@ func (e *Engine) GetPrice(assetID string) (MarketState, bool) {
 	return data, ok
 }
 
-func (e *Engine) ProcessStream(namespace string, msgChan <-chan []byte) {
-	for rawMsg := range msgChan {
-		if namespace == "orderbook" {
-			e.updateCache(rawMsg)
+// SetRecorder installs a capture hook. It must be called before any stream is
+// started.
+func (e *Engine) SetRecorder(r FrameRecorder) {
+	e.recorder = r
+}
+
+// SetSettler installs the resolution hook. It must be called before any
+// stream is started.
+func (e *Engine) SetSettler(s Settler) {
+	e.settler = s
+}
+
+// SetMarketStreams enables the combined per-market streams. It must be called
+// before any stream is started.
+func (e *Engine) SetMarketStreams(enabled bool) {
+	e.marketStreams = enabled
+}
+
+// StartWriter moves stream writes to an async, pipelined writer so a slow
+// Redis does not stall ingest. It must be called before any stream is
+// started; Drain flushes it.
+func (e *Engine) StartWriter(opts WriterOptions) {
+	opts.QueueSize = max(opts.QueueSize, 1)
+	opts.BatchSize = max(opts.BatchSize, 1)
+	e.writer = newWriter(e.ctx, e.rdb, opts, e.logger)
+	go e.writer.run()
+}
+
+// ProcessStream handles frames on a new goroutine until msgChan is closed.
+// The stream counts towards Drain from the moment ProcessStream returns. The
+// returned channel is closed once the last frame has been processed.
+func (e *Engine) ProcessStream(namespace string, msgChan <-chan []byte) <-chan struct{} {
+	done := make(chan struct{})
+	e.streams.Add(1)
+	go func() {
+		defer e.streams.Done()
+		defer close(done)
+		for rawMsg := range msgChan {
+			e.Process(namespace, rawMsg)
 		}
-		e.pushToRedis(namespace, rawMsg)
+	}()
+	return done
+}
+
+// Drain waits until every ProcessStream has consumed its closed channel,
+// stops candle aggregation and waits for the writer to flush, so all frames
+// received before shutdown are written to Redis. It gives up when ctx is
+// done.
+func (e *Engine) Drain(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		e.streams.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if e.candles != nil {
+		e.candles.close()
+	}
+	if e.writer != nil {
+		return e.writer.close(ctx)
 	}
+	return nil
+}
+
+// Process handles a single frame synchronously.
+func (e *Engine) Process(namespace string, rawMsg []byte) {
+	if e.recorder != nil {
+		e.recorder.Record(namespace, rawMsg)
+	}
+	if namespace == "orderbook" {
+		updates := e.updateCache(rawMsg)
+		e.notify(updates)
+	}
+	e.pushToRedis(namespace, rawMsg)
+}
+
+func (e *Engine) notify(updates []OrderbookUpdate) {
+	if len(e.listeners) == 0 {
+		return
+	}
+	for _, u := range updates {
+		for _, l := range e.listeners {
+			switch {
+			case u.EventType == "last_trade_price":
+				l.OnTrade(u)
+			case len(u.Bids) > 0 || len(u.Asks) > 0:
+				l.OnBook(u)
+			}
+		}
+	}
+}
+
+// GetMetadata returns the registry entry for an asset registered by this
+// process.
+func (e *Engine) GetMetadata(assetID string) (market.Token, bool) {
+	e.metaMu.RLock()
+	defer e.metaMu.RUnlock()
+	t, ok := e.meta[assetID]
+	return t, ok
 }
 
 func (e *Engine) RegisterMetadata(slug string, tokens []market.Token) error {
 	pipe := e.rdb.Pipeline()
 	slugKey := redismantis.SetSlugAssets(slug)
 
+	e.metaMu.Lock()
 	for _, t := range tokens {
+		if prev, ok := e.meta[t.TokenID]; ok {
+			// A resolution seen on the stream outranks a lagging REST snapshot.
+			if prev.Status.Final() && !t.Status.Final() {
+				t.Status = prev.Status
+			}
+			delete(e.events[prev.EventID], t.TokenID)
+		}
+		e.meta[t.TokenID] = t
+		e.slugs[t.TokenID] = slug
+		if t.EventID != "" {
+			if e.events[t.EventID] == nil {
+				e.events[t.EventID] = make(map[string]bool)
+			}
+			e.events[t.EventID][t.TokenID] = true
+		}
+
 		key := redismantis.HashTokenMeta(t.TokenID)
 		pipe.HSet(e.ctx, key, map[string]interface{}{
-			"id":      t.TokenID,
-			"outcome": t.Outcome,
-			"market":  t.Market,
-			"slug":    slug,
+			"id":                 t.TokenID,
+			"outcome":            t.Outcome,
+			"market":             t.Market,
+			"slug":               slug,
+			"market_slug":        t.MarketSlug,
+			"condition_id":       t.ConditionID,
+			"event_id":           t.EventID,
+			"event_title":        t.EventTitle,
+			"neg_risk":           t.NegRisk,
+			"minimum_tick_size":  t.TickSize,
+			"minimum_order_size": t.MinOrderSize,
+			"end_date":           t.EndDate,
+			"sibling":            t.Sibling,
+			"status":             string(t.Status),
 		})
 		pipe.SAdd(e.ctx, slugKey, t.TokenID)
+		if t.EventID != "" {
+			pipe.SAdd(e.ctx, redismantis.SetEventAssets(t.EventID), t.TokenID)
+		}
 	}
+	e.metaMu.Unlock()
+	// The slug index lets readers list markets without a keyspace SCAN,
+	// which on Cluster only sees one node.
+	pipe.SAdd(e.ctx, redismantis.SetSlugs, slug)
+
 	_, err := pipe.Exec(e.ctx)
 	return err
 }
 
-func (e *Engine) updateCache(rawMsg []byte) {
-	if len(rawMsg) == 0 {
+// updateTickSize applies a tick_size_change event to the registry.
+func (e *Engine) updateTickSize(assetID string, tick float64) {
+	e.metaMu.Lock()
+	if t, ok := e.meta[assetID]; ok {
+		t.TickSize = tick
+		e.meta[assetID] = t
+	}
+	e.metaMu.Unlock()
+
+	key := redismantis.HashTokenMeta(assetID)
+	e.logger.Info("tick size changed", "asset_id", assetID, "tick_size", tick)
+	if err := e.rdb.HSet(e.ctx, key, "minimum_tick_size", tick).Err(); err != nil {
+		e.logger.Error("metadata write failed", "key", key, "err", err)
+	}
+}
+
+// Status returns the last known market status of an asset.
+func (e *Engine) Status(assetID string) market.MarketStatus {
+	e.metaMu.RLock()
+	defer e.metaMu.RUnlock()
+	return e.meta[assetID].Status
+}
+
+// SetStatus records a status change seen on the stream.
+func (e *Engine) SetStatus(assetID string, status market.MarketStatus) {
+	e.metaMu.Lock()
+	t, ok := e.meta[assetID]
+	if !ok {
+		t = market.Token{TokenID: assetID}
+	}
+	changed := t.Status != status
+	t.Status = status
+	e.meta[assetID] = t
+	e.metaMu.Unlock()
+
+	if !changed {
 		return
 	}
+	e.logger.Info("market status changed", "asset_id", assetID, "status", status)
+	key := redismantis.HashTokenMeta(assetID)
+	if err := e.rdb.HSet(e.ctx, key, "status", string(status)).Err(); err != nil {
+		e.logger.Error("metadata write failed", "key", key, "err", err)
+	}
+}
 
-	var updates []OrderbookUpdate
-	if rawMsg[0] == '[' {
-		if err := json.Unmarshal(rawMsg, &updates); err != nil {
-			return
-		}
-	} else {
-		var single OrderbookUpdate
-		if err := json.Unmarshal(rawMsg, &single); err == nil {
-			updates = append(updates, single)
+// RegisterSeriesAlias points the stable series keys at the instance that is
+// currently live, so bots can follow a rolling market without knowing its slug.
+func (e *Engine) RegisterSeriesAlias(name string, inst market.SeriesInstance, tokens []market.Token) error {
+	setKey := redismantis.SetSeriesAssets(name)
+	ids := make([]interface{}, len(tokens))
+	for i, t := range tokens {
+		ids[i] = t.TokenID
+	}
+
+	pipe := e.rdb.TxPipeline()
+	pipe.Del(e.ctx, setKey)
+	if len(ids) > 0 {
+		pipe.SAdd(e.ctx, setKey, ids...)
+	}
+	pipe.HSet(e.ctx, redismantis.HashSeriesAlias(name), map[string]interface{}{
+		"slug":  inst.Slug,
+		"start": inst.Start.Unix(),
+		"end":   inst.End.Unix(),
+	})
+	_, err := pipe.Exec(e.ctx)
+	return err
+}
+
+func (e *Engine) updateCache(rawMsg []byte) []OrderbookUpdate {
+	if len(rawMsg) == 0 {
+		return nil
+	}
+
+	updates, _ := wire.DecodeUpdates(rawMsg)
+
+	for i := range updates {
+		u := &updates[i]
+		switch u.EventType {
+		case "tick_size_change":
+			if tick, err := strconv.ParseFloat(u.NewTickSize, 64); err == nil && u.AssetID != "" {
+				e.updateTickSize(u.AssetID, tick)
+			}
+		case "market_resolved":
+			for _, id := range u.AssetsIDs {
+				e.SetStatus(id, market.StatusResolved)
+			}
+			switch {
+			case e.settler == nil:
+			case u.WinningAssetID == "":
+				e.logger.Warn("market resolved without a winner, positions not settled", "assets", u.AssetsIDs)
+			default:
+				e.settler.Settle(u.AssetsIDs, u.WinningAssetID)
+			}
 		}
 	}
 
@@ -110,71 +422,158 @@ func (e *Engine) updateCache(rawMsg []byte) {
 			continue
 		}
 
-		state := e.prices[u.AssetID]
-		updated := false
-
-		if n := len(u.Bids); n > 0 {
-			if price, err := strconv.ParseFloat(u.Bids[n-1].Price, 64); err == nil {
-				state.BestBid = price
-				updated = true
-			}
-		}
-
-		if n := len(u.Asks); n > 0 {
-			if price, err := strconv.ParseFloat(u.Asks[n-1].Price, 64); err == nil {
-				state.BestAsk = price
-				updated = true
-			}
-		}
-
-		if updated {
-			state.LastUpdated = time.Now().Unix()
-			e.prices[u.AssetID] = state
+		if u.EventType != "book" && len(u.Bids) == 0 && len(u.Asks) == 0 {
+			continue
 		}
+		state := e.prices[u.AssetID]
+		state.BestBid, _, state.BestAsk, _ = e.books.apply(*u).Best()
+		state.LastUpdated = e.clock.Now().Unix()
+		e.prices[u.AssetID] = state
 	}
+	return updates
 }
 
+// pushToRedis routes a frame to the streams of the assets it mentions. Each
+// event of a batch goes only to its own asset's stream; with market streams
+// enabled, the events of one market are also written together to
+// market:stream:<condition_id>.
 func (e *Engine) pushToRedis(namespace string, rawMsg []byte) {
-
-	type RouterMsg struct {
+	type routerMsg struct {
 		AssetID string `json:"asset_id"`
+		Market  string `json:"market"`
 	}
 
 	if namespace == "discovery" {
-		e.streamAdd(namespace, "all", rawMsg)
+		e.pushDiscovery(rawMsg)
 		return
 	}
 
+	var events []json.RawMessage
+	var err error
 	if len(rawMsg) > 0 && rawMsg[0] == '[' {
-		var batch []RouterMsg
-		if json.Unmarshal(rawMsg, &batch) == nil {
-			for _, m := range batch {
-				e.streamAdd(namespace, m.AssetID, rawMsg)
-			}
-		}
+		err = json.Unmarshal(rawMsg, &events)
 	} else {
-		var m RouterMsg
-		if json.Unmarshal(rawMsg, &m) == nil {
-			e.streamAdd(namespace, m.AssetID, rawMsg)
+		events = []json.RawMessage{rawMsg}
+	}
+	routes := make([]routerMsg, len(events))
+	for i := 0; err == nil && i < len(events); i++ {
+		err = json.Unmarshal(events[i], &routes[i])
+	}
+	if err != nil {
+		// The CLOB answers keepalive PINGs with a bare PONG.
+		if string(rawMsg) != "PONG" {
+			metrics.DecodeFailures.WithLabelValues(namespace).Inc()
+			e.logger.Warn("frame decode failed", "namespace", namespace, "err", err)
+		}
+		return
+	}
+
+	var markets []string
+	firstAsset := make(map[string]string)
+	byMarket := make(map[string][]json.RawMessage)
+	for i, m := range routes {
+		if m.AssetID == "" {
+			continue
+		}
+		metrics.MessagesReceived.WithLabelValues(namespace, m.AssetID).Inc()
+		e.logger.Debug("frame routed", "namespace", namespace, "asset_id", m.AssetID)
+		e.streamAdd(namespace, m.AssetID, m.AssetID, events[i])
+
+		if !e.marketStreams {
+			continue
+		}
+		id := m.Market
+		if id == "" {
+			meta, _ := e.GetMetadata(m.AssetID)
+			id = meta.ConditionID
 		}
+		if id == "" {
+			continue
+		}
+		if _, ok := byMarket[id]; !ok {
+			markets = append(markets, id)
+			firstAsset[id] = m.AssetID
+		}
+		byMarket[id] = append(byMarket[id], events[i])
+	}
+
+	for _, id := range markets {
+		data, _ := json.Marshal(byMarket[id])
+		e.streamAdd("market", id, firstAsset[id], data)
 	}
 }
 
-func (e *Engine) streamAdd(namespace string, identifier string, data []byte) {
+// pushDiscovery writes a scan to discovery:stream:all. data stays the JSON
+// array of markets consumers have always read; the scan's completeness, time,
+// error and closed IDs go in their own fields. Bare arrays, as in recordings
+// made before scans carried metadata, are written as they are.
+func (e *Engine) pushDiscovery(rawMsg []byte) {
+	var scan struct {
+		Complete  bool            `json:"complete"`
+		ScannedAt int64           `json:"scanned_at"`
+		Error     string          `json:"error"`
+		Markets   json.RawMessage `json:"markets"`
+		Closed    []string        `json:"closed"`
+	}
+	if len(rawMsg) == 0 || rawMsg[0] == '[' || json.Unmarshal(rawMsg, &scan) != nil {
+		e.streamAdd("discovery", "all", "", rawMsg)
+		return
+	}
+	closed, _ := json.Marshal(append([]string{}, scan.Closed...))
+	values := map[string]interface{}{
+		"data":       []byte(scan.Markets),
+		"complete":   scan.Complete,
+		"scanned_at": scan.ScannedAt,
+		"closed":     closed,
+	}
+	if scan.Error != "" {
+		values["error"] = scan.Error
+	}
+	e.write(streamWrite{
+		namespace: "discovery",
+		args:      redis.XAddArgs{Stream: redismantis.StreamDiscovery, Values: values},
+	}, "")
+}
+
+// streamAdd writes data to <namespace>:stream:<identifier>, trimmed by the
+// retention of assetID's market when one is known.
+func (e *Engine) streamAdd(namespace, identifier, assetID string, data []byte) {
 	if identifier == "" {
 		return
 	}
+	e.xadd(namespace, redismantis.StreamNamespaceDynamic(namespace, identifier), assetID, data)
+}
 
-	streamKey := redismantis.StreamNamespaceDynamic(namespace, identifier)
+func (e *Engine) xadd(namespace, stream, assetID string, data []byte) {
+	e.write(streamWrite{
+		namespace: namespace,
+		args:      redis.XAddArgs{Stream: stream, Values: map[string]interface{}{"data": data}},
+	}, assetID)
+}
+
+// write sends sw through the async writer if there is one, else directly.
+func (e *Engine) write(sw streamWrite, assetID string) {
+	args := &sw.args
+	e.retentionFor(sw.namespace, assetID).trim(args, time.Now())
+	if e.writer != nil && e.writer.enqueue(sw) {
+		return
+	}
 
-	err := e.rdb.XAdd(e.ctx, &redis.XAddArgs{
-		Stream: streamKey,
-		MaxLen: 1000,
-		Approx: true,
-		Values: map[string]interface{}{"data": data},
-	}).Err()
+	start := time.Now()
+	var err error
+	if sw.hash == "" {
+		err = e.rdb.XAdd(e.ctx, args).Err()
+	} else {
+		_, err = e.rdb.Pipelined(e.ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(e.ctx, sw.hash, sw.fields)
+			pipe.XAdd(e.ctx, args)
+			return nil
+		})
+	}
+	metrics.RedisXAddSeconds.WithLabelValues(sw.namespace).Observe(metrics.Since(start))
 
 	if err != nil {
-		log.Printf("Redis Stream Error [%s]: %v", streamKey, err)
+		metrics.RedisXAddErrors.WithLabelValues(sw.namespace).Inc()
+		e.logger.Error("stream write failed", "stream", args.Stream, "asset_id", assetID, "err", err)
 	}
-}
\ No newline at end of file
+}
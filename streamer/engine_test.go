@@ -7,6 +7,8 @@ import (
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/arjunprakash027/Mantis/market"
+	"github.com/arjunprakash027/Mantis/market/fakeserver"
+	"github.com/arjunprakash027/Mantis/pkg/wire"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -23,9 +25,24 @@ func TestFullSystemEndToEnd(t *testing.T) {
 	t.Log("System Initialized with Sandbox Redis")
 
 	slug := "will-trump-pardon-ghislaine-maxwell"
-	t.Logf("Connecting to real-world market: %s", slug)
+	poly := fakeserver.New()
+	defer poly.Close()
+	poly.AddMarket(fakeserver.Market{
+		ID:       "1",
+		Slug:     slug,
+		Question: "Will Trump pardon Ghislaine Maxwell?",
+		Tokens:   []market.Token{{TokenID: "yes_token", Outcome: "Yes"}, {TokenID: "no_token", Outcome: "No"}},
+	})
+	poly.Script(
+		fakeserver.Book("yes_token",
+			[]fakeserver.Level{{Price: "0.10", Size: "100"}, {Price: "0.12", Size: "50"}},
+			[]fakeserver.Level{{Price: "0.16", Size: "80"}, {Price: "0.14", Size: "20"}}),
+		fakeserver.Trade("yes_token", "0.14", "5", "BUY"),
+	)
+	client := poly.Client()
+	t.Logf("Connecting to fake market: %s", slug)
 
-	tokens, eventTitle, err := market.GetTokens(slug)
+	tokens, eventTitle, err := client.GetTokens(ctx, slug)
 	if err != nil {
 		t.Fatalf("LookUp Error: %v", err)
 	}
@@ -37,35 +54,168 @@ func TestFullSystemEndToEnd(t *testing.T) {
 	}
 
 	msgChan := make(chan []byte, 100)
-	err = market.StartOrderBookStream(ctx, assetIds, msgChan)
+	_, err = client.StartOrderBookStream(ctx, assetIds, msgChan)
 	if err != nil {
 		t.Fatalf("Stream Error: %v", err)
 	}
 
-	go engine.ProcessStream("orderbook", msgChan)
+	engine.ProcessStream("orderbook", msgChan)
 
-	ticker := time.NewTicker(1 * time.Second)
+	ticker := time.NewTicker(10 * time.Millisecond)
 	defer ticker.Stop()
 
-	foundData := false
 	for {
 		select {
 		case <-ctx.Done():
-			if !foundData {
-				t.Fatal("Timeout: Did not receive any live prices from Polymarket after 20s")
-			}
-			return
+			t.Fatal("Timeout: Did not receive any prices from the fake server")
 		case <-ticker.C:
-			engine.mu.RLock()
-			count := len(engine.prices)
-			engine.mu.RUnlock()
-
-			if count > 0 {
-				t.Logf("Success: Engine is receiving live data for %d assets!", count)
-				foundData = true
-				return
+			state, ok := engine.GetPrice("yes_token")
+			if !ok {
+				continue
+			}
+			if state.BestBid != 0.12 || state.BestAsk != 0.14 {
+				t.Fatalf("unexpected BBO %+v", state)
+			}
+			if n, _ := rdb.XLen(ctx, "orderbook:stream:yes_token").Result(); n == 0 {
+				continue
 			}
-			t.Log("... waiting for network packets ...")
+			return
 		}
 	}
 }
+
+func TestMetadataRegistry(t *testing.T) {
+	s, _ := miniredis.Run()
+	defer s.Close()
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	ctx := context.Background()
+
+	poly := fakeserver.New()
+	defer poly.Close()
+	poly.AddEvent(fakeserver.Event{
+		ID:      "ev1",
+		Slug:    "fed-decision",
+		Title:   "Fed decision",
+		NegRisk: true,
+		Markets: []fakeserver.Market{{
+			Slug:         "fed-cut",
+			Question:     "Will the Fed cut?",
+			ConditionID:  "0xcond",
+			TickSize:     0.01,
+			MinOrderSize: 5,
+			Tokens:       []market.Token{{TokenID: "cut_yes", Outcome: "Yes"}, {TokenID: "cut_no", Outcome: "No"}},
+		}},
+	})
+
+	tokens, _, err := poly.Client().GetTokens(ctx, "fed-decision")
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := NewEngine(ctx, rdb)
+	if err := engine.RegisterMetadata("fed-decision", tokens); err != nil {
+		t.Fatal(err)
+	}
+
+	meta, ok := engine.GetMetadata("cut_yes")
+	if !ok || meta.Sibling != "cut_no" || !meta.NegRisk || meta.EventTitle != "Fed decision" || meta.MinOrderSize != 5 {
+		t.Fatalf("unexpected metadata %+v", meta)
+	}
+	if cond := s.HGet("token:meta:cut_no", "condition_id"); cond != "0xcond" {
+		t.Errorf("condition_id not stored, got %q", cond)
+	}
+	if members, _ := s.SMembers("event:assets:ev1"); len(members) != 2 {
+		t.Errorf("event set has %d members", len(members))
+	}
+
+	engine.updateCache(fakeserver.TickSizeChange("cut_yes", "0.01", "0.001").Data)
+	if meta, _ := engine.GetMetadata("cut_yes"); meta.TickSize != 0.001 {
+		t.Errorf("tick size not refreshed: %v", meta.TickSize)
+	}
+	if tick := s.HGet("token:meta:cut_yes", "minimum_tick_size"); tick != "0.001" {
+		t.Errorf("redis tick size not refreshed: %s", tick)
+	}
+}
+
+func TestDrainWaitsForStreams(t *testing.T) {
+	s, _ := miniredis.Run()
+	defer s.Close()
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	engine := NewEngine(context.Background(), rdb)
+
+	msgChan := make(chan []byte, 1)
+	engine.ProcessStream("orderbook", msgChan)
+	msgChan <- []byte(`{"asset_id":"tok","bids":[{"price":"0.3","size":"1"}]}`)
+	time.Sleep(10 * time.Millisecond)
+
+	short, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := engine.Drain(short); err == nil {
+		t.Fatal("Drain returned while a stream was still open")
+	}
+
+	close(msgChan)
+	if err := engine.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	if n, _ := rdb.XLen(context.Background(), "orderbook:stream:tok").Result(); n != 1 {
+		t.Fatalf("expected the frame to be written before Drain returned, got %d", n)
+	}
+}
+
+func TestBatchSplitPerAsset(t *testing.T) {
+	s, _ := miniredis.Run()
+	defer s.Close()
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	ctx := context.Background()
+	engine := NewEngine(ctx, rdb)
+	engine.SetMarketStreams(true)
+	engine.RegisterMetadata("m", []market.Token{{TokenID: "yes", ConditionID: "0xc1"}, {TokenID: "no", ConditionID: "0xc1"}})
+
+	engine.Process("orderbook", []byte(`[`+
+		`{"event_type":"price_change","asset_id":"yes","bids":[{"price":"0.4","size":"1"}]},`+
+		`{"event_type":"price_change","asset_id":"no","market":"0xc1","asks":[{"price":"0.6","size":"1"}]},`+
+		`{"event_type":"price_change","asset_id":"other","market":"0xc2","asks":[{"price":"0.9","size":"1"}]}]`))
+
+	yes, _ := rdb.XRange(ctx, "orderbook:stream:yes", "-", "+").Result()
+	if len(yes) != 1 {
+		t.Fatalf("yes stream has %d entries", len(yes))
+	}
+	updates, err := wire.DecodeUpdates([]byte(yes[0].Values["data"].(string)))
+	if err != nil || len(updates) != 1 || updates[0].AssetID != "yes" {
+		t.Errorf("yes stream carries %+v (%v)", updates, err)
+	}
+
+	combined, _ := rdb.XRange(ctx, "market:stream:0xc1", "-", "+").Result()
+	if len(combined) != 1 {
+		t.Fatalf("market stream has %d entries", len(combined))
+	}
+	updates, _ = wire.DecodeUpdates([]byte(combined[0].Values["data"].(string)))
+	if len(updates) != 2 || updates[0].AssetID != "yes" || updates[1].AssetID != "no" {
+		t.Errorf("market stream carries %+v", updates)
+	}
+	if n, _ := rdb.XLen(ctx, "market:stream:0xc2").Result(); n != 1 {
+		t.Errorf("0xc2 market stream has %d entries", n)
+	}
+}
+
+func TestDiscoveryEntry(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	ctx := context.Background()
+	engine := NewEngine(ctx, rdb)
+
+	engine.Process("discovery", []byte(`{"complete":true,"scanned_at":1700000000,"markets":[{"id":"1"}],"closed":["7"]}`))
+	engine.Process("discovery", []byte(`[{"id":"2"}]`))
+
+	msgs, _ := rdb.XRange(ctx, "discovery:stream:all", "-", "+").Result()
+	if len(msgs) != 2 {
+		t.Fatalf("got %d entries", len(msgs))
+	}
+	v := msgs[0].Values
+	if v["data"] != `[{"id":"1"}]` || v["complete"] != "1" || v["scanned_at"] != "1700000000" || v["closed"] != `["7"]` {
+		t.Errorf("scan entry = %v", v)
+	}
+	if v := msgs[1].Values; v["data"] != `[{"id":"2"}]` || len(v) != 1 {
+		t.Errorf("legacy entry = %v", v)
+	}
+}
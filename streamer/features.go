@@ -0,0 +1,212 @@
+package streamer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/arjunprakash027/Mantis/pkg/redismantis"
+	"github.com/redis/go-redis/v9"
+)
+
+// Feature names, as used in config, the features:<asset_id> hash and the
+// stream payload.
+const (
+	// FeatureMid is (best bid + best ask) / 2.
+	FeatureMid = "mid"
+	// FeatureMicroprice weights each side's best price by the size on the
+	// other side: (bid*ask_size + ask*bid_size) / (bid_size + ask_size).
+	FeatureMicroprice = "microprice"
+	// FeatureSpreadTicks is (best ask - best bid) / tick size, rounded.
+	FeatureSpreadTicks = "spread_ticks"
+	// FeatureImbalance is (bid - ask) / (bid + ask) over the size of the
+	// top DepthLevels levels of each side, in [-1, 1].
+	FeatureImbalance = "imbalance"
+	// FeatureVolatility is the realized volatility of the mid over
+	// VolatilityWindow: the square root of the summed squared log returns,
+	// not annualized.
+	FeatureVolatility = "volatility"
+)
+
+// AllFeatures lists every feature the engine can compute.
+var AllFeatures = []string{FeatureMid, FeatureMicroprice, FeatureSpreadTicks, FeatureImbalance, FeatureVolatility}
+
+// defaultTick is the CLOB's usual tick, for assets without metadata.
+const defaultTick = 0.01
+
+// FeatureOptions selects the published features. An empty Set means all.
+type FeatureOptions struct {
+	Set              []string
+	DepthLevels      int
+	VolatilityWindow time.Duration
+}
+
+// Features is one asset's feature values at TS (unix milliseconds).
+type Features struct {
+	AssetID string
+	TS      int64
+	Values  map[string]float64
+}
+
+// MarshalJSON flattens Values next to asset_id and ts.
+func (f Features) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(f.Values)+2)
+	for k, v := range f.Values {
+		m[k] = v
+	}
+	m["asset_id"], m["ts"] = f.AssetID, f.TS
+	return json.Marshal(m)
+}
+
+type midSample struct {
+	at  time.Time
+	mid float64
+}
+
+// features computes the configured set on every book update of an asset with
+// a two-sided quote and publishes it to features:<asset_id> and
+// features:stream:<asset_id>.
+type features struct {
+	e    *Engine
+	opts FeatureOptions
+	want map[string]bool
+
+	mu     sync.Mutex
+	mids   map[string][]midSample
+	latest map[string]Features
+}
+
+// StartFeatures enables the microstructure features. It must be called before
+// any stream is started.
+func (e *Engine) StartFeatures(opts FeatureOptions) error {
+	if len(opts.Set) == 0 {
+		opts.Set = AllFeatures
+	}
+	opts.DepthLevels = max(opts.DepthLevels, 1)
+	if opts.VolatilityWindow <= 0 {
+		opts.VolatilityWindow = time.Minute
+	}
+	f := &features{
+		e:      e,
+		opts:   opts,
+		want:   make(map[string]bool),
+		mids:   make(map[string][]midSample),
+		latest: make(map[string]Features),
+	}
+	for _, name := range opts.Set {
+		if !isFeature(name) {
+			return fmt.Errorf("unknown feature %q", name)
+		}
+		f.want[name] = true
+	}
+	e.features = f
+	e.AddListener(f)
+	return nil
+}
+
+// Features returns the asset's latest published features.
+func (e *Engine) Features(asset string) (Features, bool) {
+	if e.features == nil {
+		return Features{}, false
+	}
+	e.features.mu.Lock()
+	defer e.features.mu.Unlock()
+	f, ok := e.features.latest[asset]
+	return f, ok
+}
+
+func isFeature(name string) bool {
+	for _, f := range AllFeatures {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *features) OnTrade(OrderbookUpdate) {}
+
+func (f *features) OnBook(u OrderbookUpdate) {
+	s, ok := f.e.top(u.AssetID, f.opts.DepthLevels)
+	if !ok || s.bid <= 0 || s.ask <= 0 {
+		return
+	}
+	now := f.e.clock.Now()
+	out := Features{AssetID: u.AssetID, TS: now.UnixMilli(), Values: make(map[string]float64, len(f.want))}
+	mid := (s.bid + s.ask) / 2
+	if f.want[FeatureMid] {
+		out.Values[FeatureMid] = mid
+	}
+	if f.want[FeatureMicroprice] {
+		out.Values[FeatureMicroprice] = mid
+		if s.bidSize+s.askSize > 0 {
+			out.Values[FeatureMicroprice] = (s.bid*s.askSize + s.ask*s.bidSize) / (s.bidSize + s.askSize)
+		}
+	}
+	if f.want[FeatureSpreadTicks] {
+		tick := defaultTick
+		if meta, ok := f.e.GetMetadata(u.AssetID); ok && meta.TickSize > 0 {
+			tick = meta.TickSize
+		}
+		out.Values[FeatureSpreadTicks] = math.Round((s.ask - s.bid) / tick)
+	}
+	if f.want[FeatureImbalance] {
+		out.Values[FeatureImbalance] = 0
+		if s.bidDepth+s.askDepth > 0 {
+			out.Values[FeatureImbalance] = (s.bidDepth - s.askDepth) / (s.bidDepth + s.askDepth)
+		}
+	}
+
+	f.mu.Lock()
+	if f.want[FeatureVolatility] {
+		out.Values[FeatureVolatility] = f.volatility(u.AssetID, now, mid)
+	}
+	f.latest[u.AssetID] = out
+	f.mu.Unlock()
+
+	f.publish(out)
+}
+
+// volatility records mid and returns the realized volatility of the samples
+// still inside the window. Only mid changes are kept; an unchanged mid adds a
+// zero return.
+func (f *features) volatility(asset string, now time.Time, mid float64) float64 {
+	samples := f.mids[asset]
+	cut := 0
+	for cut < len(samples) && now.Sub(samples[cut].at) > f.opts.VolatilityWindow {
+		cut++
+	}
+	// The last sample before the window anchors its first return.
+	samples = samples[max(cut-1, 0):]
+	if n := len(samples); n == 0 || samples[n-1].mid != mid {
+		samples = append(samples, midSample{now, mid})
+	}
+	f.mids[asset] = samples
+
+	var sum float64
+	for i := 1; i < len(samples); i++ {
+		r := math.Log(samples[i].mid / samples[i-1].mid)
+		sum += r * r
+	}
+	return math.Sqrt(sum)
+}
+
+func (f *features) publish(out Features) {
+	data, _ := json.Marshal(out)
+	fields := make(map[string]interface{}, len(out.Values)+1)
+	for k, v := range out.Values {
+		fields[k] = v
+	}
+	fields["ts"] = out.TS
+	f.e.write(streamWrite{
+		namespace: "features",
+		args: redis.XAddArgs{
+			Stream: redismantis.StreamNamespaceDynamic("features", out.AssetID),
+			Values: map[string]interface{}{"data": data},
+		},
+		hash:   redismantis.HashFeatures(out.AssetID),
+		fields: fields,
+	}, out.AssetID)
+}
@@ -0,0 +1,98 @@
+package streamer
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/arjunprakash027/Mantis/market"
+	"github.com/arjunprakash027/Mantis/pkg/clock"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestFeatures(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	ctx := context.Background()
+	sim := clock.NewSim(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	engine := NewEngine(ctx, rdb)
+	engine.SetClock(sim)
+	if err := engine.StartFeatures(FeatureOptions{DepthLevels: 2, VolatilityWindow: time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+	engine.RegisterMetadata("m", []market.Token{{TokenID: "A", TickSize: 0.001}})
+
+	engine.Process("orderbook", []byte(`{"event_type":"book","asset_id":"A","bids":[{"price":"0.30","size":"50"},{"price":"0.38","size":"10"},{"price":"0.40","size":"30"}],"asks":[{"price":"0.50","size":"20"},{"price":"0.44","size":"10"}]}`))
+	f, ok := engine.Features("A")
+	if !ok {
+		t.Fatal("no features after a two-sided book")
+	}
+	want := map[string]float64{
+		FeatureMid:         0.42,
+		FeatureMicroprice:  (0.40*10 + 0.44*30) / 40,
+		FeatureSpreadTicks: 40,
+		FeatureImbalance:   (40.0 - 30) / 70, // top two levels per side
+		FeatureVolatility:  0,
+	}
+	for name, v := range want {
+		if math.Abs(f.Values[name]-v) > 1e-9 {
+			t.Errorf("%s = %v, want %v", name, f.Values[name], v)
+		}
+	}
+
+	// The mid moves 0.42 -> 0.43 -> 0.42; the first sample then leaves the window.
+	sim.Advance(30 * time.Second)
+	engine.Process("orderbook", []byte(`{"event_type":"price_change","asset_id":"A","bids":[{"price":"0.42","size":"5"}]}`))
+	sim.Advance(20 * time.Second)
+	engine.Process("orderbook", []byte(`{"event_type":"price_change","asset_id":"A","bids":[{"price":"0.42","size":"0"}]}`))
+	f, _ = engine.Features("A")
+	r := math.Log(0.43 / 0.42)
+	if got := f.Values[FeatureVolatility]; math.Abs(got-math.Sqrt(2*r*r)) > 1e-9 {
+		t.Errorf("volatility = %v, want %v", got, math.Sqrt(2*r*r))
+	}
+	sim.Advance(45 * time.Second)
+	engine.Process("orderbook", []byte(`{"event_type":"price_change","asset_id":"A","asks":[{"price":"0.44","size":"15"}]}`))
+	f, _ = engine.Features("A")
+	if got := f.Values[FeatureVolatility]; math.Abs(got-math.Abs(r)) > 1e-9 {
+		t.Errorf("volatility after the window moved = %v, want %v", got, math.Abs(r))
+	}
+
+	if got := s.HGet("features:A", "spread_ticks"); got != "40" {
+		t.Errorf("features:A spread_ticks = %q", got)
+	}
+	msgs, _ := rdb.XRange(ctx, "features:stream:A", "-", "+").Result()
+	if len(msgs) != 4 {
+		t.Fatalf("features:stream:A has %d entries", len(msgs))
+	}
+	var last map[string]interface{}
+	json.Unmarshal([]byte(msgs[3].Values["data"].(string)), &last)
+	if mid, _ := last["mid"].(float64); last["asset_id"] != "A" || last["ts"] != float64(sim.Now().UnixMilli()) || math.Abs(mid-0.42) > 1e-9 {
+		t.Errorf("stream entry = %v", last)
+	}
+}
+
+func TestFeatureSubset(t *testing.T) {
+	engine := NewEngine(context.Background(), redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()}))
+	if err := engine.StartFeatures(FeatureOptions{Set: []string{"vwap"}}); err == nil {
+		t.Error("expected unknown feature error")
+	}
+	// Through the async writer, the hash goes out in the same pipeline.
+	s := miniredis.RunT(t)
+	engine = NewEngine(context.Background(), redis.NewClient(&redis.Options{Addr: s.Addr()}))
+	engine.StartWriter(WriterOptions{QueueSize: 10, BatchSize: 10})
+	engine.StartFeatures(FeatureOptions{Set: []string{FeatureMid}})
+	engine.Process("orderbook", []byte(`{"event_type":"book","asset_id":"A","bids":[{"price":"0.40","size":"1"}],"asks":[{"price":"0.60","size":"1"}]}`))
+	if f, _ := engine.Features("A"); len(f.Values) != 1 || f.Values[FeatureMid] != 0.5 {
+		t.Errorf("features = %+v", f)
+	}
+	engine.Drain(context.Background())
+	if got := s.HGet("features:A", "mid"); got != "0.5" {
+		t.Errorf("features:A mid = %q", got)
+	}
+	if keys, _ := s.HKeys("features:A"); len(keys) != 2 {
+		t.Errorf("features:A fields = %v", keys)
+	}
+}
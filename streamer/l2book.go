@@ -0,0 +1,16 @@
+package streamer
+
+import "github.com/arjunprakash027/Mantis/pkg/wire"
+
+// l2Books holds one book per asset. It is not safe for concurrent use.
+type l2Books map[string]*wire.L2Book
+
+func (bs l2Books) apply(u OrderbookUpdate) *wire.L2Book {
+	b := bs[u.AssetID]
+	if b == nil {
+		b = wire.NewL2Book()
+		bs[u.AssetID] = b
+	}
+	b.Apply(u)
+	return b
+}
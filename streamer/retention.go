@@ -0,0 +1,72 @@
+package streamer
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Retention bounds one stream, either by entry count or by age. Redis trims
+// approximately, so a stream may briefly hold a little more.
+type Retention struct {
+	MaxLen int64
+	MaxAge time.Duration
+}
+
+// RetentionPolicy picks the Retention for each stream write: a market's
+// entry (keyed by slug) wins over its namespace's, which wins over Default.
+type RetentionPolicy struct {
+	Default    Retention
+	Namespaces map[string]Retention
+	Markets    map[string]Retention
+}
+
+// DefaultRetention is what the engine applies until SetRetention is called.
+var DefaultRetention = RetentionPolicy{Default: Retention{MaxLen: 1000}}
+
+func (p *RetentionPolicy) lookup(namespace string, slugs ...string) Retention {
+	for _, s := range slugs {
+		if r, ok := p.Markets[s]; ok && s != "" {
+			return r
+		}
+	}
+	if r, ok := p.Namespaces[namespace]; ok {
+		return r
+	}
+	return p.Default
+}
+
+// trim sets the XADD trimming arguments. Stream IDs are Redis server
+// milliseconds, so the MINID cutoff uses the wall clock even in backtests.
+func (r Retention) trim(args *redis.XAddArgs, now time.Time) {
+	switch {
+	case r.MaxAge > 0:
+		args.MinID = strconv.FormatInt(now.Add(-r.MaxAge).UnixMilli(), 10)
+	case r.MaxLen > 0:
+		args.MaxLen = r.MaxLen
+	default:
+		return
+	}
+	args.Approx = true
+}
+
+// SetRetention replaces the stream retention policy; safe to call while
+// streams are running. Streams are trimmed on their next write.
+func (e *Engine) SetRetention(p RetentionPolicy) {
+	e.retentionMu.Lock()
+	defer e.retentionMu.Unlock()
+	e.retention = p
+}
+
+func (e *Engine) retentionFor(namespace, assetID string) Retention {
+	var slugs []string
+	if assetID != "" {
+		e.metaMu.RLock()
+		slugs = []string{e.slugs[assetID], e.meta[assetID].MarketSlug}
+		e.metaMu.RUnlock()
+	}
+	e.retentionMu.RLock()
+	defer e.retentionMu.RUnlock()
+	return e.retention.lookup(namespace, slugs...)
+}
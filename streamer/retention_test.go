@@ -0,0 +1,53 @@
+package streamer
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/arjunprakash027/Mantis/market"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRetention(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	ctx := context.Background()
+	engine := NewEngine(ctx, rdb)
+	engine.RegisterMetadata("busy-market", []market.Token{{TokenID: "busy"}})
+	engine.SetRetention(RetentionPolicy{
+		Default:    Retention{MaxLen: 5},
+		Namespaces: map[string]Retention{"orderbook": {MaxLen: 3}, "discovery": {MaxAge: time.Hour}},
+		Markets:    map[string]Retention{"busy-market": {MaxLen: 1}},
+	})
+
+	// A discovery entry from yesterday falls outside the one hour window.
+	old := strconv.FormatInt(time.Now().Add(-24*time.Hour).UnixMilli(), 10) + "-0"
+	rdb.XAdd(ctx, &redis.XAddArgs{Stream: "discovery:stream:all", ID: old, Values: map[string]interface{}{"data": "old"}})
+	for i := 0; i < 10; i++ {
+		engine.Process("orderbook", []byte(`{"asset_id":"quiet"}`))
+		engine.Process("orderbook", []byte(`{"asset_id":"busy"}`))
+	}
+	engine.Process("discovery", []byte(`[]`))
+
+	for stream, want := range map[string]int64{
+		"orderbook:stream:quiet": 3,
+		"orderbook:stream:busy":  1,
+		"discovery:stream:all":   1,
+	} {
+		if n := rdb.XLen(ctx, stream).Val(); n != want {
+			t.Errorf("%s has %d entries, want %d", stream, n, want)
+		}
+	}
+
+	// Policy changes apply on the next write.
+	engine.SetRetention(DefaultRetention)
+	for i := 0; i < 10; i++ {
+		engine.Process("orderbook", []byte(`{"asset_id":"busy"}`))
+	}
+	if n := rdb.XLen(ctx, "orderbook:stream:busy").Val(); n != 11 {
+		t.Errorf("busy stream has %d entries after reset, want 11", n)
+	}
+}
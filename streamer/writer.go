@@ -0,0 +1,196 @@
+package streamer
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/arjunprakash027/Mantis/pkg/metrics"
+	"github.com/redis/go-redis/v9"
+)
+
+// Overflow decides what the async writer does when its queue is full.
+type Overflow string
+
+const (
+	// OverflowBlock makes ingest wait, pushing back on the WebSocket reader.
+	OverflowBlock Overflow = "block"
+	// OverflowDrop discards the write and counts it, so reads never stall.
+	OverflowDrop Overflow = "drop"
+)
+
+// WriterOptions configures the async stream writer. A flush is sent when
+// BatchSize writes are queued or FlushInterval has passed since the first
+// one, whichever comes first; a zero interval flushes as soon as the queue is
+// momentarily empty.
+type WriterOptions struct {
+	QueueSize     int
+	BatchSize     int
+	FlushInterval time.Duration
+	Overflow      Overflow
+}
+
+// streamWrite is one XADD, optionally preceded by an HSET of the entry's
+// latest values so readers can fetch them without a range read.
+type streamWrite struct {
+	namespace string
+	args      redis.XAddArgs
+	hash      string
+	fields    map[string]interface{}
+}
+
+// writer moves XADDs off the ingest path: frames are queued and a single
+// goroutine sends them in pipelines, preserving their order.
+type writer struct {
+	rdb    redis.UniversalClient
+	ctx    context.Context
+	opts   WriterOptions
+	queue  chan streamWrite
+	done   chan struct{}
+	logger *slog.Logger
+
+	// mu guards closed; senders hold it shared so close cannot race a send.
+	mu     sync.RWMutex
+	closed bool
+}
+
+func newWriter(ctx context.Context, rdb redis.UniversalClient, opts WriterOptions, logger *slog.Logger) *writer {
+	return &writer{
+		rdb:    rdb,
+		ctx:    ctx,
+		opts:   opts,
+		queue:  make(chan streamWrite, opts.QueueSize),
+		done:   make(chan struct{}),
+		logger: logger,
+	}
+}
+
+// enqueue reports false if the writer is closed.
+func (w *writer) enqueue(sw streamWrite) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return false
+	}
+
+	select {
+	case w.queue <- sw:
+		metrics.WriterQueueDepth.Set(float64(len(w.queue)))
+		return true
+	default:
+	}
+	if w.opts.Overflow == OverflowDrop {
+		metrics.WriterDropped.WithLabelValues(sw.namespace).Inc()
+		return true
+	}
+	start := time.Now()
+	w.queue <- sw
+	metrics.WriterBlockedSeconds.Add(metrics.Since(start))
+	metrics.WriterQueueDepth.Set(float64(len(w.queue)))
+	return true
+}
+
+// close stops accepting writes and waits until everything queued has been
+// flushed, or ctx ends.
+func (w *writer) close(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *writer) run() {
+	defer close(w.done)
+	batch := make([]streamWrite, 0, w.opts.BatchSize)
+	for {
+		sw, ok := <-w.queue
+		if !ok {
+			return
+		}
+		batch = append(batch[:0], sw)
+		open := w.fill(&batch)
+		w.flush(batch)
+		if !open {
+			return
+		}
+	}
+}
+
+// fill adds queued writes to batch until it is full, the deadline passes or,
+// without a deadline, the queue is momentarily empty. It reports false once
+// the queue is closed and drained.
+func (w *writer) fill(batch *[]streamWrite) bool {
+	var deadline <-chan time.Time
+	if w.opts.FlushInterval > 0 {
+		t := time.NewTimer(w.opts.FlushInterval)
+		defer t.Stop()
+		deadline = t.C
+	}
+	for len(*batch) < w.opts.BatchSize {
+		var sw streamWrite
+		var ok bool
+		if deadline == nil {
+			select {
+			case sw, ok = <-w.queue:
+			default:
+				return true
+			}
+		} else {
+			select {
+			case sw, ok = <-w.queue:
+			case <-deadline:
+				return true
+			}
+		}
+		if !ok {
+			return false
+		}
+		*batch = append(*batch, sw)
+	}
+	return true
+}
+
+func (w *writer) flush(batch []streamWrite) {
+	metrics.WriterQueueDepth.Set(float64(len(w.queue)))
+	metrics.WriterBatchSize.Observe(float64(len(batch)))
+
+	start := time.Now()
+	pipe := w.rdb.Pipeline()
+	cmds := make([]redis.Cmder, 0, len(batch))
+	namespaces := make([]string, 0, len(batch))
+	for i := range batch {
+		if batch[i].hash != "" {
+			cmds = append(cmds, pipe.HSet(w.ctx, batch[i].hash, batch[i].fields))
+			namespaces = append(namespaces, batch[i].namespace)
+		}
+		cmds = append(cmds, pipe.XAdd(w.ctx, &batch[i].args))
+		namespaces = append(namespaces, batch[i].namespace)
+	}
+	pipe.Exec(w.ctx)
+	metrics.WriterFlushSeconds.Observe(metrics.Since(start))
+
+	failed := 0
+	var firstErr error
+	for i, cmd := range cmds {
+		if err := cmd.Err(); err != nil {
+			metrics.RedisXAddErrors.WithLabelValues(namespaces[i]).Inc()
+			if firstErr == nil {
+				firstErr = err
+			}
+			failed++
+		}
+	}
+	if failed > 0 {
+		w.logger.Error("stream writes failed", "failed", failed, "batch", len(cmds), "err", firstErr)
+	}
+}
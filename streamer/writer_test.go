@@ -0,0 +1,106 @@
+package streamer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/arjunprakash027/Mantis/pkg/logging"
+	"github.com/arjunprakash027/Mantis/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestWriterFlushesOnDrain(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	ctx := context.Background()
+	engine := NewEngine(ctx, rdb)
+	// Neither limit is reached, so only Drain can flush.
+	engine.StartWriter(WriterOptions{QueueSize: 100, BatchSize: 100, FlushInterval: time.Hour, Overflow: OverflowBlock})
+
+	msgChan := make(chan []byte)
+	engine.ProcessStream("orderbook", msgChan)
+	for i := 0; i < 10; i++ {
+		msgChan <- []byte(fmt.Sprintf(`{"asset_id":"tok","price":"%d"}`, i))
+	}
+	if n := rdb.XLen(ctx, "orderbook:stream:tok").Val(); n != 0 {
+		t.Fatalf("%d entries written before the flush", n)
+	}
+
+	close(msgChan)
+	if err := engine.Drain(ctx); err != nil {
+		t.Fatal(err)
+	}
+	msgs := rdb.XRange(ctx, "orderbook:stream:tok", "-", "+").Val()
+	if len(msgs) != 10 {
+		t.Fatalf("got %d entries after Drain, want 10", len(msgs))
+	}
+	for i, m := range msgs {
+		if want := fmt.Sprintf(`{"asset_id":"tok","price":"%d"}`, i); m.Values["data"] != want {
+			t.Errorf("entry %d = %v, want %s", i, m.Values["data"], want)
+		}
+	}
+
+	// After Drain, writes fall back to the synchronous path.
+	engine.Process("orderbook", []byte(`{"asset_id":"tok"}`))
+	if n := rdb.XLen(ctx, "orderbook:stream:tok").Val(); n != 11 {
+		t.Errorf("late write not stored, len %d", n)
+	}
+}
+
+func TestWriterDropPolicy(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	ctx := context.Background()
+	w := newWriter(ctx, rdb, WriterOptions{QueueSize: 1, BatchSize: 10, Overflow: OverflowDrop}, logging.For("test"))
+
+	write := func(v string) streamWrite {
+		return streamWrite{namespace: "droptest", args: redis.XAddArgs{Stream: "s", Values: map[string]interface{}{"data": v}}}
+	}
+	before := testutil.ToFloat64(metrics.WriterDropped.WithLabelValues("droptest"))
+	// The writer is not running yet, so the second write finds the queue full.
+	w.enqueue(write("kept"))
+	w.enqueue(write("dropped"))
+	if got := testutil.ToFloat64(metrics.WriterDropped.WithLabelValues("droptest")) - before; got != 1 {
+		t.Errorf("dropped counter rose by %v, want 1", got)
+	}
+
+	go w.run()
+	if err := w.close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if msgs := rdb.XRange(ctx, "s", "-", "+").Val(); len(msgs) != 1 || msgs[0].Values["data"] != "kept" {
+		t.Errorf("stream = %v", msgs)
+	}
+	if w.enqueue(write("late")) {
+		t.Error("enqueue accepted a write after close")
+	}
+}
+
+// The benchmarks write one frame per iteration to a local miniredis over TCP;
+// the async writer amortises the round trip across a pipeline.
+func benchmarkStreamAdd(b *testing.B, async bool) {
+	s := miniredis.RunT(b)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	ctx := context.Background()
+	engine := NewEngine(ctx, rdb)
+	if async {
+		engine.StartWriter(WriterOptions{QueueSize: 10000, BatchSize: 256, Overflow: OverflowBlock})
+	}
+	frame := []byte(`{"event_type":"price_change","asset_id":"tok","price":"0.5","size":"10","side":"BUY"}`)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		engine.pushToRedis("orderbook", frame)
+	}
+	if err := engine.Drain(ctx); err != nil {
+		b.Fatal(err)
+	}
+}
+
+func BenchmarkStreamAddSync(b *testing.B)  { benchmarkStreamAdd(b, false) }
+func BenchmarkStreamAddAsync(b *testing.B) { benchmarkStreamAdd(b, true) }
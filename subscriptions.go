@@ -0,0 +1,436 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/arjunprakash027/Mantis/admin"
+	"github.com/arjunprakash027/Mantis/config"
+	"github.com/arjunprakash027/Mantis/market"
+	"github.com/arjunprakash027/Mantis/pkg/logging"
+	"github.com/arjunprakash027/Mantis/streamer"
+)
+
+// subscriptions tracks the configured orderbook feeds so a config reload can
+// add or drop individual markets and series without touching the others.
+// Markets added or removed through the admin API are layered on top of the
+// config and survive reloads, but not a restart.
+type subscriptions struct {
+	ctx    context.Context
+	client *market.Client
+	engine *streamer.Engine
+
+	mu         sync.Mutex
+	cfg        *config.Config
+	markets    map[string]context.CancelFunc
+	series     map[string]seriesSub
+	extra      map[string]bool
+	suppressed map[string]bool
+
+	feedsMu sync.Mutex
+	feeds   map[string]*feed
+
+	logger *slog.Logger
+}
+
+var (
+	marketRetryBase = time.Second
+	marketRetryMax  = time.Minute
+)
+
+var errMarketClosed = errors.New("market is closed")
+
+// feed is one live orderbook stream, reported by /readyz.
+type feed struct {
+	slug   string
+	tokens []market.Token
+	status *market.StreamStatus
+}
+
+type seriesSub struct {
+	cfg    config.SeriesConfig
+	cancel context.CancelFunc
+}
+
+func newSubscriptions(ctx context.Context, client *market.Client, engine *streamer.Engine) *subscriptions {
+	return &subscriptions{
+		ctx:        ctx,
+		client:     client,
+		engine:     engine,
+		markets:    make(map[string]context.CancelFunc),
+		series:     make(map[string]seriesSub),
+		extra:      make(map[string]bool),
+		suppressed: make(map[string]bool),
+		feeds:      make(map[string]*feed),
+		logger:     logging.For("pipeline"),
+	}
+}
+
+// Feeds lists the streams that are currently subscribed, sorted by slug.
+func (s *subscriptions) Feeds() []*feed {
+	s.feedsMu.Lock()
+	defer s.feedsMu.Unlock()
+	out := make([]*feed, 0, len(s.feeds))
+	for _, f := range s.feeds {
+		out = append(out, f)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].slug < out[j].slug })
+	return out
+}
+
+// List reports every streamed market: configured, added through the API, or
+// a live series instance.
+func (s *subscriptions) List() []admin.Subscription {
+	s.mu.Lock()
+	source := make(map[string]string, len(s.markets))
+	for slug := range s.markets {
+		source[slug] = "config"
+		if s.extra[slug] {
+			source[slug] = "api"
+		}
+	}
+	s.mu.Unlock()
+
+	feeds := make(map[string]*feed)
+	for _, f := range s.Feeds() {
+		feeds[f.slug] = f
+		if _, ok := source[f.slug]; !ok {
+			source[f.slug] = "series"
+		}
+	}
+
+	out := make([]admin.Subscription, 0, len(source))
+	for slug, src := range source {
+		sub := admin.Subscription{Slug: slug, Source: src, Assets: []string{}}
+		if f, ok := feeds[slug]; ok {
+			sub.Streaming = true
+			sub.Connected, _, sub.LastError = f.status.Connected()
+			for _, t := range f.tokens {
+				sub.Assets = append(sub.Assets, t.TokenID)
+			}
+		}
+		out = append(out, sub)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Slug < out[j].Slug })
+	return out
+}
+
+// Add subscribes to slug after checking that the market exists.
+func (s *subscriptions) Add(ctx context.Context, slug string) error {
+	s.mu.Lock()
+	_, ok := s.markets[slug]
+	s.mu.Unlock()
+	if ok {
+		return admin.ErrAlreadySubscribed
+	}
+
+	tokens, _, err := s.client.GetTokens(ctx, slug)
+	if err != nil {
+		return fmt.Errorf("%w: %v", admin.ErrUnknownMarket, err)
+	}
+	if len(tokens) == 0 {
+		return fmt.Errorf("%w: %s has no tokens", admin.ErrUnknownMarket, slug)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.markets[slug]; ok {
+		return admin.ErrAlreadySubscribed
+	}
+	s.extra[slug] = true
+	delete(s.suppressed, slug)
+	s.apply()
+	return nil
+}
+
+// Remove unsubscribes from slug, including a configured market, until it is
+// added again. Series instances follow their schedule and cannot be removed.
+func (s *subscriptions) Remove(slug string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.markets[slug]; !ok {
+		return admin.ErrNotSubscribed
+	}
+	if s.extra[slug] {
+		delete(s.extra, slug)
+	} else {
+		s.suppressed[slug] = true
+	}
+	s.apply()
+	return nil
+}
+
+// Apply brings the running feeds in line with cfg.
+func (s *subscriptions) Apply(cfg *config.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg = cfg
+	// A removal only holds while the market stays in the config.
+	for slug := range s.suppressed {
+		if !slices.Contains(cfg.Pipelines.Orderbook.Markets, slug) {
+			delete(s.suppressed, slug)
+		}
+	}
+	s.apply()
+}
+
+func (s *subscriptions) apply() {
+	wantMarkets := make(map[string]bool)
+	wantSeries := make(map[string]config.SeriesConfig)
+	if s.cfg != nil {
+		if ob := s.cfg.Pipelines.Orderbook; ob.Enabled {
+			for _, slug := range ob.Markets {
+				wantMarkets[slug] = true
+			}
+			for _, sc := range ob.Series {
+				wantSeries[sc.Name] = sc
+			}
+		}
+	}
+	for slug := range s.extra {
+		wantMarkets[slug] = true
+	}
+	for slug := range s.suppressed {
+		delete(wantMarkets, slug)
+	}
+
+	for slug, cancel := range s.markets {
+		if !wantMarkets[slug] {
+			s.logger.Info("market removed, unsubscribing", "slug", slug)
+			cancel()
+			delete(s.markets, slug)
+		}
+	}
+	added := 0
+	for slug := range wantMarkets {
+		if _, ok := s.markets[slug]; ok {
+			continue
+		}
+		ctx, cancel := context.WithCancel(s.ctx)
+		s.markets[slug] = cancel
+		go s.runMarket(ctx, slug)
+		added++
+	}
+	if added > 0 {
+		s.logger.Info("starting orderbook pipelines", "markets", added)
+	}
+
+	for name, sub := range s.series {
+		if next, ok := wantSeries[name]; !ok || next != sub.cfg {
+			s.logger.Info("series removed or changed, stopping", "series", name)
+			sub.cancel()
+			delete(s.series, name)
+		}
+	}
+	for name, sc := range wantSeries {
+		if _, ok := s.series[name]; ok {
+			continue
+		}
+		series, err := market.NewSeries(sc.Name, sc.Template, sc.Timezone,
+			time.Duration(sc.IntervalMinutes)*time.Minute,
+			time.Duration(sc.LeadMinutes)*time.Minute,
+			time.Duration(sc.LingerMinutes)*time.Minute)
+		if err != nil {
+			s.logger.Error("invalid series config", "series", name, "err", err)
+			continue
+		}
+		ctx, cancel := context.WithCancel(s.ctx)
+		ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("series", name))
+		s.series[name] = seriesSub{cfg: sc, cancel: cancel}
+		s.logger.Info("starting series", "series", name, "template", series.Template, "interval", series.Interval)
+		go s.runSeries(ctx, series)
+	}
+}
+
+// runMarket starts slug's feed, retrying failed lookups and connects with
+// backoff until ctx is cancelled. A closed market is unsubscribed instead, so
+// List does not report a feed that will never start.
+func (s *subscriptions) runMarket(ctx context.Context, slug string) {
+	backoff := marketRetryBase
+	for {
+		_, err := s.startOrderbookForSlug(ctx, slug)
+		if err == nil {
+			return
+		}
+		if errors.Is(err, errMarketClosed) {
+			s.mu.Lock()
+			// A cancelled ctx means apply already removed or replaced the entry.
+			if ctx.Err() == nil {
+				s.markets[slug]()
+				delete(s.markets, slug)
+				delete(s.extra, slug)
+			}
+			s.mu.Unlock()
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, marketRetryMax)
+	}
+}
+
+func (s *subscriptions) startOrderbookForSlug(ctx context.Context, slug string) ([]market.Token, error) {
+	client, engine := s.client, s.engine
+	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("slug", slug))
+	logger := logging.FromContext(ctx).With("component", "pipeline")
+
+	// A. Fetch Tokens
+	tokens, eventTitle, err := client.GetTokens(ctx, slug)
+	if err != nil {
+		logger.Warn("market lookup failed", "err", err)
+		return nil, err
+	}
+
+	// B. Register Metadata
+	if err := engine.RegisterMetadata(slug, tokens); err != nil {
+		logger.Warn("metadata registration failed", "err", err)
+	}
+
+	if marketDead(engine, tokens) {
+		logger.Info("market is closed, skipping")
+		return nil, fmt.Errorf("%w: %s", errMarketClosed, slug)
+	}
+
+	// C. Start Stream
+	assetIds := make([]string, len(tokens))
+	for i, t := range tokens {
+		assetIds[i] = t.TokenID
+	}
+
+	subCtx, cancel := context.WithCancel(ctx)
+	msgChan := make(chan []byte)
+	status, err := client.StartOrderBookStream(subCtx, assetIds, msgChan)
+	if err != nil {
+		logger.Error("stream start failed", "err", err)
+		cancel()
+		return nil, err
+	}
+
+	logger.Info("streaming market", "event", eventTitle, "tokens", len(tokens))
+
+	f := &feed{slug: slug, tokens: tokens, status: status}
+	s.feedsMu.Lock()
+	s.feeds[slug] = f
+	s.feedsMu.Unlock()
+
+	engine.ProcessStream("orderbook", msgChan)
+	go s.watchMarket(subCtx, cancel, f, logger)
+	return tokens, nil
+}
+
+// watchMarket refreshes a subscription's metadata and drops the stream once
+// every token is closed or resolved. Paused markets stay subscribed.
+func (s *subscriptions) watchMarket(ctx context.Context, cancel context.CancelFunc, f *feed, logger *slog.Logger) {
+	client, engine, slug := s.client, s.engine, f.slug
+	defer func() {
+		s.feedsMu.Lock()
+		if s.feeds[slug] == f {
+			delete(s.feeds, slug)
+		}
+		s.feedsMu.Unlock()
+	}()
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		tokens, _, err := client.GetTokens(ctx, slug)
+		if err != nil {
+			continue
+		}
+		if err := engine.RegisterMetadata(slug, tokens); err != nil {
+			logger.Warn("metadata registration failed", "err", err)
+		}
+		if marketDead(engine, tokens) {
+			logger.Info("market closed, removing subscription")
+			cancel()
+			return
+		}
+	}
+}
+
+func marketDead(engine *streamer.Engine, tokens []market.Token) bool {
+	for _, t := range tokens {
+		if !engine.Status(t.TokenID).Final() {
+			return false
+		}
+	}
+	return true
+}
+
+// runSeries keeps a recurring market subscribed: each instance is streamed from
+// Lead before it opens until Linger after it closes, and the series alias is
+// moved to whichever instance is currently live.
+func (s *subscriptions) runSeries(ctx context.Context, series *market.Series) {
+	engine := s.engine
+	logger := logging.FromContext(ctx).With("component", "pipeline")
+	type subscription struct {
+		cancel context.CancelFunc
+		tokens []market.Token
+	}
+	active := make(map[string]*subscription)
+	alias := ""
+
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+		wanted := make(map[string]bool)
+
+		for _, inst := range series.Wanted(now) {
+			wanted[inst.Slug] = true
+			sub, ok := active[inst.Slug]
+			if !ok {
+				subCtx, subCancel := context.WithCancel(ctx)
+				tokens, err := s.startOrderbookForSlug(subCtx, inst.Slug)
+				if err != nil {
+					// Not listed yet; retry on the next tick.
+					subCancel()
+					continue
+				}
+				sub = &subscription{cancel: subCancel, tokens: tokens}
+				active[inst.Slug] = sub
+			}
+
+			live := !now.Before(inst.Start) && now.Before(inst.End)
+			if live && alias != inst.Slug {
+				if err := engine.RegisterSeriesAlias(series.Name, inst, sub.tokens); err != nil {
+					logger.Error("series alias update failed", "slug", inst.Slug, "err", err)
+				} else {
+					logger.Info("series alias moved", "slug", inst.Slug)
+					alias = inst.Slug
+				}
+			}
+		}
+
+		for slug, sub := range active {
+			if !wanted[slug] {
+				logger.Info("series instance ended, unsubscribing", "slug", slug)
+				sub.cancel()
+				delete(active, slug)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/arjunprakash027/Mantis/config"
+	"github.com/arjunprakash027/Mantis/market"
+	"github.com/arjunprakash027/Mantis/market/fakeserver"
+	"github.com/arjunprakash027/Mantis/streamer"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestSubscriptionRetriesFailedLookup(t *testing.T) {
+	prev := marketRetryBase
+	marketRetryBase = 5 * time.Millisecond
+	t.Cleanup(func() { marketRetryBase = prev })
+
+	s := miniredis.RunT(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	engine := streamer.NewEngine(ctx, redis.NewClient(&redis.Options{Addr: s.Addr()}))
+	poly := fakeserver.New()
+	defer poly.Close()
+
+	subs := newSubscriptions(ctx, poly.Client(), engine)
+	cfg := &config.Config{}
+	cfg.Pipelines.Orderbook.Enabled = true
+	cfg.Pipelines.Orderbook.Markets = []string{"not-listed-yet"}
+	subs.Apply(cfg)
+
+	// The lookup fails until the market is listed; the subscription stays
+	// and keeps retrying.
+	time.Sleep(20 * time.Millisecond)
+	if list := subs.List(); len(list) != 1 || list[0].Streaming {
+		t.Fatalf("subscriptions = %+v", list)
+	}
+	poly.AddMarket(fakeserver.Market{
+		ID:     "1",
+		Slug:   "not-listed-yet",
+		Tokens: []market.Token{{TokenID: "yes", Outcome: "Yes"}, {TokenID: "no", Outcome: "No"}},
+	})
+
+	deadline := time.After(5 * time.Second)
+	for len(subs.Feeds()) == 0 {
+		select {
+		case <-deadline:
+			t.Fatal("feed never started after the market was listed")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}